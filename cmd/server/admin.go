@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+const adminUsage = `usage: server admin <command>
+
+commands:
+  grant <nickname|email>         make a user an admin (use this for the first admin)
+  role <nickname|email> <role>   set a user's role: user, moderator or admin
+  users [nickname prefix]        list accounts with their email and role`
+
+// runAdmin implements the `admin` subcommand, for account management that
+// has to work before anyone can sign in as an admin.
+func runAdmin(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", adminUsage)
+	}
+
+	if err := repo.InitDB(dataSourceName); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer repo.CloseDB()
+
+	switch args[0] {
+	case "grant":
+		if len(args) != 2 {
+			return fmt.Errorf("%s", adminUsage)
+		}
+		return setRole(args[1], models.RoleAdmin)
+
+	case "role":
+		if len(args) != 3 {
+			return fmt.Errorf("%s", adminUsage)
+		}
+		return setRole(args[1], args[2])
+
+	case "users":
+		if len(args) > 2 {
+			return fmt.Errorf("%s", adminUsage)
+		}
+		prefix := ""
+		if len(args) == 2 {
+			prefix = args[1]
+		}
+		return listUsers(prefix)
+
+	default:
+		return fmt.Errorf("unknown admin command %q\n%s", args[0], adminUsage)
+	}
+}
+
+// setRole changes the role of the user with the given nickname or email.
+func setRole(identifier, role string) error {
+	if !auth.ValidRole(role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	user, err := repo.GetUserByEmailOrNickname(identifier)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("no user with nickname or email %q", identifier)
+	}
+	if user.Role == role {
+		fmt.Printf("%s (ID %d) is already %s\n", user.Nickname, user.ID, role)
+		return nil
+	}
+	if user.Role == models.RoleAdmin {
+		admins, err := repo.CountUsersWithRole(models.RoleAdmin)
+		if err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return fmt.Errorf("cannot demote the last admin")
+		}
+	}
+	if err := repo.SetUserRole(user.ID, role); err != nil {
+		return err
+	}
+	// Like PUT /api/users/{id}/role: tokens issued for the old role stop working
+	revoked, err := auth.RevokeOtherSessions(user.ID, 0)
+	if err != nil {
+		return fmt.Errorf("role changed but signing out the user failed: %w", err)
+	}
+
+	fmt.Printf("%s (ID %d) is now %s, %d sessions signed out\n", user.Nickname, user.ID, role, len(revoked))
+	if auth.RequiresTwoFactor(&models.User{Role: role}) && !user.TwoFactor {
+		fmt.Println("their privileges apply once they enable two-factor authentication")
+	}
+	return nil
+}
+
+// listUsers prints the accounts whose nickname starts with prefix, with their
+// email and role. It replaces the list the server used to print at startup.
+func listUsers(prefix string) error {
+	users, err := repo.GetAllUsers()
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, user := range users {
+		if !strings.HasPrefix(strings.ToLower(user.Nickname), strings.ToLower(prefix)) {
+			continue
+		}
+		fmt.Printf("%6d  %-20s  %-32s  %s\n", user.ID, user.Nickname, user.Email, user.Role)
+		count++
+	}
+	fmt.Printf("%d users\n", count)
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/repo"
+)
+
+// sessionJanitorInterval is how often expired sessions are purged.
+const sessionJanitorInterval = 15 * time.Minute
+
+// accountTokenRetention is how long used and expired account tokens are kept.
+const accountTokenRetention = 24 * time.Hour
+
+// runSessionJanitor purges expired sessions, stale account tokens and pending logins once at
+// startup and then periodically, logging how many rows each run removed. It never returns.
+func runSessionJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := auth.PurgeExpiredSessions()
+		if err != nil {
+			log.Printf("[janitor.go:runSessionJanitor] Failed to purge expired sessions: %v", err)
+		} else {
+			log.Printf("[janitor.go:runSessionJanitor] Purged %d expired sessions", removed)
+		}
+		removed, err = repo.PurgeAccountTokens(time.Now().Add(-accountTokenRetention))
+		if err != nil {
+			log.Printf("[janitor.go:runSessionJanitor] Failed to purge account tokens: %v", err)
+		} else if removed > 0 {
+			log.Printf("[janitor.go:runSessionJanitor] Purged %d account tokens", removed)
+		}
+		removed, err = repo.PurgeLoginChallenges(time.Now())
+		if err != nil {
+			log.Printf("[janitor.go:runSessionJanitor] Failed to purge login challenges: %v", err)
+		} else if removed > 0 {
+			log.Printf("[janitor.go:runSessionJanitor] Purged %d expired login challenges", removed)
+		}
+		<-ticker.C
+	}
+}
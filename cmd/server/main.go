@@ -1,41 +1,55 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"runtime"
 
 	router "real-time-forum/internal/http"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/ratelimit"
 	"real-time-forum/internal/repo"
 )
 
+// dataSourceName is the SQLite database used by the server and the migrate command.
+// The `?_foreign_keys=on` is important for SQLite to enforce foreign key constraints.
+const dataSourceName = "./forum.db?_foreign_keys=on"
+
 func main() {
-	// Initialize the database connection.
-	// The `?_foreign_keys=on` is important for SQLite to enforce foreign key constraints.
-	err := repo.InitDB("./forum.db?_foreign_keys=on")
+	// Subcommands: `server migrate ...` manages the schema and `server admin ...`
+	// manages accounts; both exit when done.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		exitOnError(runMigrate(os.Args[2:]))
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		exitOnError(runAdmin(os.Args[2:]))
+		return
+	}
+
+	// Initialize the database connection and apply pending migrations.
+	err := repo.InitDB(dataSourceName)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer repo.CloseDB()
 
-	// --- Print all users to the terminal for debugging ---
-	users, err := repo.GetAllUsers()
+	// Purge expired sessions in the background.
+	go runSessionJanitor(sessionJanitorInterval)
+
+	// Load the rate limits; RATE_LIMIT_CONFIG can point at a JSON file overriding the defaults.
+	limits, err := ratelimit.LoadConfig()
 	if err != nil {
-		pc, file, line, _ := runtime.Caller(0)
-		fn := runtime.FuncForPC(pc).Name()
-		log.Printf("[%s:%s:%d] Could not retrieve users for debug printing: %v", filepath.Base(file), fn, line, err)
-	} else {
-		log.Println("--- Registered Users ---")
-		if len(users) == 0 {
-			log.Println("No users found in the database.")
-		}
-		for _, user := range users {
-			fmt.Printf("  - ID: %d, Nickname: %s, Email: %s\n", user.ID, user.Nickname, user.Email)
-		}
-		log.Println("------------------------")
+		log.Fatalf("Failed to load rate limits: %v", err)
 	}
+	router.InitRateLimits(limits)
+
+	// Verification and reset mails go through SMTP when MAIL_SMTP_ADDR is set,
+	// otherwise they are written to MAIL_DIR or the log. APP_BASE_URL is the
+	// public address used in their links.
+	router.InitMailer(mail.FromEnvironment(), os.Getenv("APP_BASE_URL"))
 
 	// Create a new ServeMux to handle routes.
 	mux := http.NewServeMux()
@@ -50,7 +64,7 @@ func main() {
 	pc, file, line, _ := runtime.Caller(0)
 	fn := runtime.FuncForPC(pc).Name()
 	log.Printf("[%s:%s:%d] Starting server on http://localhost:8083", filepath.Base(file), fn, line)
-	if err := http.ListenAndServe(":8083", mux); err != nil {
+	if err := http.ListenAndServe(":8083", router.RateLimitMiddleware(mux)); err != nil {
 		pc, file, line, _ := runtime.Caller(0)
 		fn := runtime.FuncForPC(pc).Name()
 		log.Fatalf("[%s:%s:%d] Could not start server: %s\n", filepath.Base(file), fn, line, err)
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"real-time-forum/internal/repo"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  status       list migrations and whether they are applied
+  up           apply all pending migrations
+  down [N]     roll back to version N (default: roll back the latest migration)`
+
+// runMigrate implements the `migrate` subcommand. It opens the database
+// without applying migrations so the schema can be inspected or rolled back.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	if err := repo.OpenDB(dataSourceName); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer repo.CloseDB()
+
+	switch args[0] {
+	case "status":
+		statuses, err := repo.GetMigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Unavailable {
+				state = "unavailable (requires " + s.Requires + ")"
+			}
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s  %s\n", s.Version, s.Name, state)
+		}
+		return nil
+
+	case "up":
+		if err := repo.MigrateUp(); err != nil {
+			return err
+		}
+
+	case "down":
+		current, err := repo.CurrentSchemaVersion()
+		if err != nil {
+			return err
+		}
+		target := current - 1
+		if len(args) > 1 {
+			target, err = strconv.Atoi(args[1])
+			if err != nil || target < 0 {
+				return fmt.Errorf("invalid target version %q", args[1])
+			}
+		}
+		if target < 0 {
+			target = 0
+		}
+		if err := repo.MigrateDown(target); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+
+	version, err := repo.CurrentSchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema version: %d\n", version)
+	return nil
+}
+
+// exitOnError prints the error and exits with a non-zero status.
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
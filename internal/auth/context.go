@@ -10,3 +10,9 @@ func GetUserFromContext(ctx context.Context) (*models.User, bool) {
 	user, ok := ctx.Value(models.UserContextKey).(*models.User)
 	return user, ok
 }
+
+// GetSessionFromContext retrieves the session of the authenticated request from the context.
+func GetSessionFromContext(ctx context.Context) (*models.Session, bool) {
+	session, ok := ctx.Value(models.SessionContextKey).(*models.Session)
+	return session, ok
+}
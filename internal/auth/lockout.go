@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/repo"
+)
+
+// Brute-force protection. Failed sign-ins are counted per identifier (since its last
+// successful sign-in) and per IP within failureWindow. After a few free attempts each
+// further attempt must wait a doubling delay after the previous failure, and at the
+// lockout threshold every attempt is refused until lockoutDuration has passed.
+const (
+	failureWindow   = 15 * time.Minute
+	lockoutDuration = 15 * time.Minute
+	maxDelay        = 30 * time.Second
+
+	identifierFreeAttempts = 3
+	identifierLockout      = 10
+
+	// An IP may legitimately be shared by many users, so it gets more room
+	ipFreeAttempts = 20
+	ipLockout      = 100
+)
+
+// LoginRetryAfter reports how long a sign-in attempt for identifier from ip must
+// wait. Zero means the attempt may proceed.
+func LoginRetryAfter(identifier, ip string) (time.Duration, error) {
+	now := time.Now()
+	since := now.Add(-failureWindow)
+
+	count, last, err := repo.CountIdentifierFailures(NormalizeIdentifier(identifier), since)
+	if err != nil {
+		return 0, err
+	}
+	wait := throttleDelay(count, last, now, identifierFreeAttempts, identifierLockout)
+
+	count, last, err = repo.CountIPFailures(ip, since)
+	if err != nil {
+		return 0, err
+	}
+	if ipWait := throttleDelay(count, last, now, ipFreeAttempts, ipLockout); ipWait > wait {
+		wait = ipWait
+	}
+	return wait, nil
+}
+
+// throttleDelay returns the remaining wait after count failures, the latest at last
+func throttleDelay(count int, last, now time.Time, free, lockout int) time.Duration {
+	if count < free {
+		return 0
+	}
+
+	delay := lockoutDuration
+	if count < lockout {
+		delay = time.Second << uint(count-free)
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+
+	if remaining := last.Add(delay).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// NormalizeIdentifier folds a nickname or email so attempts with different
+// casing count against the same identifier.
+func NormalizeIdentifier(identifier string) string {
+	return strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// ClientIP returns the address of the remote end of the request.
+// Proxy headers such as X-Forwarded-For are not trusted.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
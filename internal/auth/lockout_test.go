@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottleDelay(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		count int
+		since time.Duration // time since the last failure
+		want  time.Duration
+	}{
+		{"no failures", 0, 0, 0},
+		{"free attempts", identifierFreeAttempts - 1, 0, 0},
+		{"first delay", identifierFreeAttempts, 0, time.Second},
+		{"delay doubles", identifierFreeAttempts + 2, 0, 4 * time.Second},
+		{"delay partly waited", identifierFreeAttempts + 2, 3 * time.Second, time.Second},
+		{"delay waited out", identifierFreeAttempts + 2, 5 * time.Second, 0},
+		{"delay capped", identifierLockout - 1, 0, maxDelay},
+		{"lockout", identifierLockout, 0, lockoutDuration},
+		{"lockout partly waited", identifierLockout + 5, 10 * time.Minute, lockoutDuration - 10*time.Minute},
+		{"lockout over", identifierLockout, lockoutDuration, 0},
+	}
+	for _, tt := range tests {
+		got := throttleDelay(tt.count, now.Add(-tt.since), now, identifierFreeAttempts, identifierLockout)
+		if got != tt.want {
+			t.Errorf("%s: throttleDelay(%d failures, %v ago) = %v, want %v", tt.name, tt.count, tt.since, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"Alice":                "alice",
+		"  BOB@Example.COM \n": "bob@example.com",
+		"":                     "",
+	}
+	for in, want := range tests {
+		if got := NormalizeIdentifier(in); got != want {
+			t.Errorf("NormalizeIdentifier(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
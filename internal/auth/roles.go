@@ -0,0 +1,62 @@
+package auth
+
+import "real-time-forum/internal/models"
+
+// rolePermissions lists what each role may do beyond managing its own content.
+var rolePermissions = map[string][]string{
+	models.RoleUser: {},
+	models.RoleModerator: {
+		models.PermPostEditAny,
+		models.PermPostDeleteAny,
+		models.PermCommentDeleteAny,
+		models.PermModerate,
+	},
+	models.RoleAdmin: {
+		models.PermPostEditAny,
+		models.PermPostDeleteAny,
+		models.PermCommentDeleteAny,
+		models.PermModerate,
+		models.PermCategoryManage,
+		models.PermUserAssignRole,
+	},
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Permissions returns the permissions the user holds right now. Roles that
+// require two-factor authentication grant nothing until it is enabled.
+func Permissions(user *models.User) []string {
+	if user == nil || (RequiresTwoFactor(user) && !user.TwoFactor) {
+		return []string{}
+	}
+	return append([]string{}, rolePermissions[user.Role]...)
+}
+
+// HasPermission reports whether the user holds a permission.
+func HasPermission(user *models.User, permission string) bool {
+	for _, p := range Permissions(user) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEditPost reports whether the user may edit a post by authorID.
+func CanEditPost(user *models.User, authorID int) bool {
+	return user != nil && (user.ID == authorID || HasPermission(user, models.PermPostEditAny))
+}
+
+// CanDeletePost reports whether the user may delete a post by authorID.
+func CanDeletePost(user *models.User, authorID int) bool {
+	return user != nil && (user.ID == authorID || HasPermission(user, models.PermPostDeleteAny))
+}
+
+// CanDeleteComment reports whether the user may delete a comment by authorID.
+func CanDeleteComment(user *models.User, authorID int) bool {
+	return user != nil && (user.ID == authorID || HasPermission(user, models.PermCommentDeleteAny))
+}
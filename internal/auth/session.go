@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"database/sql"
 	"net/http"
 	"real-time-forum/internal/models"
 	"real-time-forum/internal/repo"
@@ -9,47 +10,102 @@ import (
 	"github.com/gofrs/uuid"
 )
 
-// CreateSession generates a new session for a user and stores it in the database.
-func CreateSession(userID int) (string, error) {
-	// Generate a new UUID for the session token.
+// Session lifetimes. Every use of a session pushes its expiry to now plus the idle
+// timeout, but never past the absolute maximum counted from sign-in.
+const (
+	sessionIdleTimeout = 24 * time.Hour
+	sessionMaxLifetime = 7 * 24 * time.Hour
+
+	// "Remember me" sessions survive longer breaks and browser restarts
+	rememberIdleTimeout = 30 * 24 * time.Hour
+	rememberMaxLifetime = 90 * 24 * time.Hour
+
+	// sessionTouchInterval limits how often the last seen time and expiry of a session are written
+	sessionTouchInterval = time.Minute
+)
+
+// sessionLimits returns the idle timeout and maximum lifetime of a session.
+func sessionLimits(remember bool) (time.Duration, time.Duration) {
+	if remember {
+		return rememberIdleTimeout, rememberMaxLifetime
+	}
+	return sessionIdleTimeout, sessionMaxLifetime
+}
+
+// newSessionToken generates a random session token.
+func newSessionToken() (string, error) {
 	token, err := uuid.NewV4()
 	if err != nil {
 		return "", err
 	}
+	return token.String(), nil
+}
 
-	sessionToken := token.String()
-	expiry := time.Now().Add(24 * time.Hour) // Session expires in 24 hours
+// CreateSession generates a new session for a user and stores it in the database,
+// along with the IP and user agent it was created from.
+func CreateSession(userID int, ip, userAgent string, remember bool) (*models.Session, error) {
+	sessionToken, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	idle, lifetime := sessionLimits(remember)
+	session := &models.Session{
+		UserID:         userID,
+		Token:          sessionToken,
+		Expiry:         now.Add(idle),
+		AbsoluteExpiry: now.Add(lifetime),
+		Remember:       remember,
+		CreatedAt:      now,
+		LastSeenAt:     now,
+		IP:             ip,
+		UserAgent:      userAgent,
+	}
 
 	// Prepare the SQL statement to insert the new session.
 	stmt, err := repo.DB.Prepare(`
-		INSERT INTO sessions (user_id, token, expiry)
-		VALUES (?, ?, ?)
+		INSERT INTO sessions (user_id, token, expiry, absolute_expiry, remember, created_at, last_seen_at, ip, user_agent, role)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE((SELECT role FROM users WHERE id = ?), ''))
 	`)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer stmt.Close()
 
 	// Execute the statement.
-	_, err = stmt.Exec(userID, sessionToken, expiry)
+	res, err := stmt.Exec(userID, sessionToken, session.Expiry, session.AbsoluteExpiry, remember, now, now, ip, userAgent, userID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	session.ID = int(id)
+
+	// Signing in counts as activity on the profile
+	if _, err := repo.DB.Exec(`UPDATE users SET last_login = ?, last_seen_at = ? WHERE id = ?`, now, now, userID); err != nil {
+		return nil, err
 	}
 
-	return sessionToken, nil
+	return session, nil
 }
 
 // SetSessionCookie creates and sets the session cookie on the HTTP response.
-func SetSessionCookie(w http.ResponseWriter, token string) {
-	http.SetCookie(w, &http.Cookie{
+// "Remember me" sessions get a persistent cookie; others end with the browser session.
+func SetSessionCookie(w http.ResponseWriter, session *models.Session) {
+	cookie := &http.Cookie{
 		Name:     "session_token",
-		Value:    token,
+		Value:    session.Token,
 		Path:     "/",
-		Expires:  time.Now().Add(24 * time.Hour),
 		HttpOnly: true,
 		Secure:   false, // Set to true in production with HTTPS
 		SameSite: http.SameSiteLaxMode,
-	})
+	}
+	if session.Remember {
+		cookie.Expires = session.Expiry
+	}
+	http.SetCookie(w, cookie)
 }
 
 // ClearSessionCookie removes the session cookie from the client's browser.
@@ -77,20 +133,32 @@ func DeleteSession(token string) error {
 	return err
 }
 
-// GetSessionByToken retrieves a session from the database and checks its validity.
-func GetSessionByToken(token string) (*models.Session, error) {
-	stmt, err := repo.DB.Prepare(`
-		SELECT user_id, token, expiry, created_at
-		FROM sessions
-		WHERE token = ?
-	`)
+// sessionColumns are the columns scanned by scanSession.
+const sessionColumns = `id, user_id, token, expiry, absolute_expiry, remember, created_at, last_seen_at, ip, user_agent, role`
+
+// scanSession reads a row selected with sessionColumns.
+func scanSession(row interface{ Scan(...interface{}) error }) (*models.Session, error) {
+	session := &models.Session{}
+	var lastSeen, absoluteExpiry sql.NullTime
+	err := row.Scan(&session.ID, &session.UserID, &session.Token, &session.Expiry, &absoluteExpiry, &session.Remember,
+		&session.CreatedAt, &lastSeen, &session.IP, &session.UserAgent, &session.Role)
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
+	session.LastSeenAt = session.CreatedAt
+	if lastSeen.Valid {
+		session.LastSeenAt = lastSeen.Time
+	}
+	session.AbsoluteExpiry = session.Expiry
+	if absoluteExpiry.Valid {
+		session.AbsoluteExpiry = absoluteExpiry.Time
+	}
+	return session, nil
+}
 
-	session := &models.Session{}
-	err = stmt.QueryRow(token).Scan(&session.UserID, &session.Token, &session.Expiry, &session.CreatedAt)
+// GetSessionByToken retrieves a session from the database and checks its validity.
+func GetSessionByToken(token string) (*models.Session, error) {
+	session, err := scanSession(repo.DB.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE token = ?`, token))
 	if err != nil {
 		if err == repo.ErrNoRows {
 			return nil, nil // Session not found, not a server error
@@ -101,6 +169,114 @@ func GetSessionByToken(token string) (*models.Session, error) {
 	return session, nil
 }
 
+// RenewSession records that the session, and its user, was just used and slides its expiry
+// forward, capped at its absolute expiry. Writes are skipped while the last
+// recorded use is recent. Persistent cookies are refreshed to the new expiry.
+func RenewSession(w http.ResponseWriter, session *models.Session) error {
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < sessionTouchInterval {
+		return nil
+	}
+
+	idle, _ := sessionLimits(session.Remember)
+	expiry := now.Add(idle)
+	if expiry.After(session.AbsoluteExpiry) {
+		expiry = session.AbsoluteExpiry
+	}
+
+	if _, err := repo.DB.Exec(`UPDATE sessions SET last_seen_at = ?, expiry = ? WHERE id = ?`, now, expiry, session.ID); err != nil {
+		return err
+	}
+	if _, err := repo.DB.Exec(`UPDATE users SET last_seen_at = ? WHERE id = ?`, now, session.UserID); err != nil {
+		return err
+	}
+	session.LastSeenAt = now
+	session.Expiry = expiry
+	if session.Remember {
+		SetSessionCookie(w, session)
+	}
+	return nil
+}
+
+// PurgeExpiredSessions deletes every expired session and returns how many were removed.
+func PurgeExpiredSessions() (int64, error) {
+	res, err := repo.DB.Exec(`DELETE FROM sessions WHERE expiry <= ?`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetUserSessions lists the unexpired sessions of a user, most recently used first.
+func GetUserSessions(userID int) ([]*models.Session, error) {
+	rows, err := repo.DB.Query(`
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE user_id = ? AND expiry > ?
+		ORDER BY last_seen_at DESC, id DESC
+	`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession deletes one session of a user. It returns repo.ErrNoRows when
+// the user has no session with that ID.
+func RevokeSession(userID, sessionID int) error {
+	res, err := repo.DB.Exec(`DELETE FROM sessions WHERE id = ? AND user_id = ?`, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return repo.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeOtherSessions deletes every session of a user except keepSessionID and
+// returns the IDs of the deleted sessions.
+func RevokeOtherSessions(userID, keepSessionID int) ([]int, error) {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id FROM sessions WHERE user_id = ? AND id != ?`, userID, keepSessionID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = ? AND id != ?`, userID, keepSessionID); err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit()
+}
+
 // GetUserBySessionToken retrieves a user by their session token
 func GetUserBySessionToken(token string) (*models.User, error) {
 	session, err := GetSessionByToken(token)
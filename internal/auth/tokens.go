@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"real-time-forum/internal/repo"
+)
+
+// Lifetimes of the tokens mailed to users.
+const (
+	VerifyEmailTokenTTL   = 48 * time.Hour
+	ResetPasswordTokenTTL = time.Hour
+)
+
+// IssueAccountToken creates a single-use token for a user and returns it. Only
+// its hash is stored, so the returned value must go straight into the mail.
+// Earlier unused tokens of the same purpose stop working.
+func IssueAccountToken(userID int, purpose string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	if err := repo.CreateAccountToken(userID, purpose, HashAccountToken(token), time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// HashAccountToken returns the form in which a mailed token is stored.
+func HashAccountToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
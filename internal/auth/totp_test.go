@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890".
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// The SHA-1 vectors of RFC 6238 appendix B, cut to our six digits (the low digits of the eight-digit codes).
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},          // 94287082
+	{1111111109, "081804"},  // 07081804
+	{1111111111, "050471"},  // 14050471
+	{1234567890, "005924"},  // 89005924
+	{2000000000, "279037"},  // 69279037
+	{20000000000, "353130"}, // 65353130
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, v := range rfc6238Vectors {
+		if got := totpCode(key, v.unix/totpPeriod); got != v.code {
+			t.Errorf("totpCode at %d = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		now := time.Unix(v.unix, 0)
+		step, ok := MatchTOTP(rfc6238Secret, v.code, now)
+		if !ok || step != v.unix/totpPeriod {
+			t.Errorf("MatchTOTP(%s) at %d = %d, %v; want %d, true", v.code, v.unix, step, ok, v.unix/totpPeriod)
+		}
+	}
+
+	// 1111111111 is step 37037037, code 050471
+	base := time.Unix(1111111111, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		now    time.Time
+		want   bool
+	}{
+		{"spaces and lower-case secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", " 050 471 ", base, true},
+		{"one step early", rfc6238Secret, "050471", base.Add(-totpPeriod * time.Second), true},
+		{"one step late", rfc6238Secret, "050471", base.Add(totpPeriod * time.Second), true},
+		{"two steps late", rfc6238Secret, "050471", base.Add(2 * totpPeriod * time.Second), false},
+		{"wrong code", rfc6238Secret, "050472", base, false},
+		{"too short", rfc6238Secret, "50471", base, false},
+		{"eight digits", rfc6238Secret, "14050471", base, false},
+		{"invalid secret", "not base32!", "050471", base, false},
+	}
+	for _, tt := range tests {
+		if _, ok := MatchTOTP(tt.secret, tt.code, tt.now); ok != tt.want {
+			t.Errorf("%s: MatchTOTP = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret: %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q decodes to %d bytes (err %v), want 20", secret, len(key), err)
+	}
+	if other, _ := NewTOTPSecret(); other == secret {
+		t.Error("two secrets are equal")
+	}
+
+	uri, err := url.Parse(TOTPProvisioningURI(secret, "alice@example.com"))
+	if err != nil {
+		t.Fatalf("provisioning URI: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Query().Get("secret") != secret {
+		t.Errorf("provisioning URI %s does not carry the secret", uri)
+	}
+}
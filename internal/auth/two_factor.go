@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+const (
+	// LoginChallengeTTL is how long the pending token of the password step stays valid
+	LoginChallengeTTL = 5 * time.Minute
+	// MaxLoginChallengeAttempts is how many wrong codes end a pending login
+	MaxLoginChallengeAttempts = 5
+
+	recoveryCodeCount = 10
+)
+
+// RequiresTwoFactor reports whether a user's role needs two-factor
+// authentication before its privileges apply.
+func RequiresTwoFactor(user *models.User) bool {
+	return user != nil && (user.Role == models.RoleModerator || user.Role == models.RoleAdmin)
+}
+
+// NewRecoveryCodes returns fresh recovery codes, formatted for the user, and the hashes to store.
+func NewRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(totpEncoding.EncodeToString(buf))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, HashAccountToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes with any case, spacing or dashes.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// CheckSecondFactor verifies a TOTP code, or else a recovery code, of a user
+// with two-factor authentication enabled. Accepted codes are used up: a TOTP
+// code cannot be replayed and a recovery code works once.
+func CheckSecondFactor(userID int, code string) (bool, error) {
+	secret, enabled, err := repo.GetTOTPSecret(userID)
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	if step, ok := MatchTOTP(secret, code, time.Now()); ok {
+		return repo.UseTOTPStep(userID, step)
+	}
+	if normalized := normalizeRecoveryCode(code); len(normalized) == 10 {
+		return repo.UseRecoveryCode(userID, HashAccountToken(normalized))
+	}
+	return false, nil
+}
+
+// BeginLoginChallenge records a login that passed the password check and
+// returns the pending token the client sends along with the second factor.
+func BeginLoginChallenge(userID int, identifier string, remember bool) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := totpEncoding.EncodeToString(buf)
+
+	err := repo.CreateLoginChallenge(&models.LoginChallenge{
+		UserID:     userID,
+		TokenHash:  HashAccountToken(token),
+		Identifier: identifier,
+		Remember:   remember,
+		ExpiresAt:  time.Now().Add(LoginChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
@@ -24,12 +24,25 @@ func ValidateRegisterRequest(req *models.RegisterRequest) error {
 		return errors.New("invalid email format")
 	}
 
-	if len(req.Password) < 8 {
+	if err := ValidatePassword(req.Password); err != nil {
+		return err
+	}
+
+	if req.Age <= 13 || req.Age > 120 {
+		return errors.New("age must be between 14 and 120")
+	}
+
+	return nil
+}
+
+// ValidatePassword checks that a new password meets the strength rules.
+func ValidatePassword(password string) error {
+	if len(password) < 8 {
 		return errors.New("password must be at least 8 characters long")
 	}
 
 	hasUpper := false
-	for _, r := range req.Password {
+	for _, r := range password {
 		if unicode.IsUpper(r) {
 			hasUpper = true
 			break
@@ -38,10 +51,5 @@ func ValidateRegisterRequest(req *models.RegisterRequest) error {
 	if !hasUpper {
 		return errors.New("password must contain at least one uppercase letter")
 	}
-
-	if req.Age <= 13 || req.Age > 120 {
-		return errors.New("age must be between 14 and 120")
-	}
-
 	return nil
 }
@@ -0,0 +1,65 @@
+// Package diff computes line-based differences between two texts.
+package diff
+
+import "strings"
+
+// Operation kinds of a diff line.
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+// Line is a single line of a diff and what happened to it.
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns the line-by-line difference from a to b, using the longest common
+// subsequence of lines. Deleted lines come before inserted ones at each change.
+func Lines(a, b string) []Line {
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := make([]Line, 0, len(x)+len(y))
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, Line{Op: Equal, Text: x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Op: Delete, Text: x[i]})
+			i++
+		default:
+			lines = append(lines, Line{Op: Insert, Text: y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, Line{Op: Delete, Text: x[i]})
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, Line{Op: Insert, Text: y[j]})
+	}
+	return lines
+}
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+// mailer sends verification and password reset mails; appBaseURL is the public
+// address of the site, used to build the links in them.
+var (
+	mailer     mail.Mailer
+	appBaseURL = "http://localhost:8083"
+)
+
+// InitMailer sets the mailer and the base URL of links in mails.
+func InitMailer(m mail.Mailer, baseURL string) {
+	mailer = m
+	if baseURL != "" {
+		appBaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// sendMail sends a mail in the background so slow mail servers never delay a
+// response, and the timing of the reset endpoint does not reveal which
+// addresses have accounts. Failures are only logged.
+func sendMail(msg mail.Message) {
+	if mailer == nil {
+		log.Printf("[account.go:sendMail] No mailer configured, dropping mail to %s", msg.To)
+		return
+	}
+	go func() {
+		if err := mailer.Send(msg); err != nil {
+			log.Printf("[account.go:sendMail] Failed to send %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// sendVerificationEmail issues a new verification token for a user and mails the link.
+func sendVerificationEmail(user *models.User) error {
+	token, err := auth.IssueAccountToken(user.ID, models.TokenVerifyEmail, auth.VerifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+	link := appBaseURL + "/verify-email?token=" + url.QueryEscape(token)
+	sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\n"+
+			"The link expires in %d hours. If you did not create an account, you can ignore this mail.\n",
+			user.Nickname, link, int(auth.VerifyEmailTokenTTL.Hours())),
+	})
+	return nil
+}
+
+// ResendVerificationHandler mails a new verification link to the current user (POST).
+// Earlier links stop working.
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if user.EmailVerified {
+		RespondWithError(w, http.StatusConflict, "Email address is already verified")
+		return
+	}
+
+	if err := sendVerificationEmail(user); err != nil {
+		log.Printf("[account.go:ResendVerificationHandler] Error issuing verification token for user %d: %v", user.ID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent"})
+}
+
+// VerifyEmailHandler consumes the token of a verification link (GET /verify-email?token=...)
+// and redirects to the app, which reports the outcome from the email_verified parameter.
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	result := "1"
+	token := r.URL.Query().Get("token")
+	userID, err := repo.VerifyEmailWithToken(auth.HashAccountToken(token))
+	switch {
+	case err == sql.ErrNoRows:
+		result = "0"
+	case err != nil:
+		log.Printf("[account.go:VerifyEmailHandler] Error verifying email: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	default:
+		log.Printf("[account.go:VerifyEmailHandler] User %d verified their email address", userID)
+	}
+	http.Redirect(w, r, "/?email_verified="+result, http.StatusSeeOther)
+}
+
+// PasswordResetRequestHandler mails a password reset link to an address (POST).
+// The response is the same whether or not an account uses the address.
+func PasswordResetRequestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		RespondWithError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	user, err := repo.GetUserByEmail(email)
+	if err != nil {
+		log.Printf("[account.go:PasswordResetRequestHandler] Error looking up user: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if user != nil {
+		token, err := auth.IssueAccountToken(user.ID, models.TokenResetPassword, auth.ResetPasswordTokenTTL)
+		if err != nil {
+			log.Printf("[account.go:PasswordResetRequestHandler] Error issuing reset token for user %d: %v", user.ID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		link := appBaseURL + "/reset-password?token=" + url.QueryEscape(token)
+		sendMail(mail.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. To choose a new password, open this link:\n\n%s\n\n"+
+				"The link expires in %d minutes and works once. If you did not ask for this, you can ignore this mail.\n",
+				user.Nickname, link, int(auth.ResetPasswordTokenTTL.Minutes())),
+		})
+		log.Printf("[account.go:PasswordResetRequestHandler] Sent password reset link to user %d", user.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If an account uses this address, a reset link is on its way",
+	})
+}
+
+// PasswordResetHandler sets a new password with the token of a reset link (POST).
+// Every session of the user is signed out, and their sockets closed.
+func PasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.PasswordResetConfirm
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Token == "" {
+		RespondWithError(w, http.StatusBadRequest, "Reset token is required")
+		return
+	}
+	if err := auth.ValidatePassword(req.Password); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	userID, err := repo.ResetPasswordWithToken(auth.HashAccountToken(req.Token), hashedPassword)
+	if err == sql.ErrNoRows {
+		RespondWithError(w, http.StatusBadRequest, "This reset link is invalid or has expired")
+		return
+	}
+	if err != nil {
+		log.Printf("[account.go:PasswordResetHandler] Error resetting password: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	// Whoever knew the old password must not stay signed in
+	revoked, err := auth.RevokeOtherSessions(userID, 0)
+	if err != nil {
+		log.Printf("[account.go:PasswordResetHandler] Error revoking sessions of user %d: %v", userID, err)
+	} else if hub != nil {
+		hub.CloseSessions(revoked)
+	}
+	log.Printf("[account.go:PasswordResetHandler] User %d reset their password, %d sessions revoked", userID, len(revoked))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password updated, please log in"})
+}
@@ -1,11 +1,13 @@
 package handler
 
 import (
-	"bytes"
 	"encoding/json"
-	"io"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"real-time-forum/internal/auth"
 	"real-time-forum/internal/models"
@@ -89,10 +91,15 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Step 7: Mail the verification link; the account works without it, but cannot post yet
+	if err := sendVerificationEmail(user); err != nil {
+		log.Printf("[auth.go:RegisterHandler] Error sending verification email to user %d: %v", user.ID, err)
+	}
+
 	// Set the content type to application/json
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Registration successful!"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "Registration successful! Check your email to verify your address."})
 }
 
 // LoginHandler handles user login.
@@ -109,14 +116,8 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Println("--- LOGIN HANDLER: Request received ---")
 
-	// 1. Parse the request
+	// 1. Parse the request (never log the body, it holds the password)
 	var req models.LoginRequest
-
-	// Read the body and then create a new reader for decoding, so we can log the raw body
-	bodyBytes, _ := io.ReadAll(r.Body)
-	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes)) // Restore the body
-	log.Printf("LOGIN HANDLER: Raw request body: %s", string(bodyBytes))
-
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("[auth.go:LoginHandler] ERROR decoding JSON: %v", err)
 		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
@@ -124,7 +125,30 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("LOGIN HANDLER: Decoded request: Identifier=[%s]", req.Identifier)
 
-	// 2. Look up the user by email or nickname
+	event := &models.LoginEvent{
+		Identifier: auth.NormalizeIdentifier(req.Identifier),
+		IP:         auth.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+	}
+
+	// 2. Refuse the attempt while the identifier or IP is throttled after failures
+	wait, err := auth.LoginRetryAfter(req.Identifier, event.IP)
+	if err != nil {
+		log.Printf("[auth.go:LoginHandler] ERROR checking failed logins: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if wait > 0 {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		log.Printf("[auth.go:LoginHandler] Login throttled for identifier %s from %s (retry in %ds)", event.Identifier, event.IP, retryAfter)
+		event.Outcome = models.LoginLocked
+		recordLoginEvent(event)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		RespondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many failed attempts, try again in %d seconds", retryAfter))
+		return
+	}
+
+	// 3. Look up the user by email or nickname
 	user, err := repo.GetUserByEmailOrNickname(req.Identifier)
 	if err != nil {
 		log.Printf("[auth.go:LoginHandler] ERROR during user lookup: %v", err)
@@ -133,31 +157,81 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	if user == nil {
 		log.Printf("[auth.go:LoginHandler] User not found for identifier: %s", req.Identifier)
+		event.Outcome = models.LoginFailure
+		recordLoginEvent(event)
 		RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 	log.Printf("[auth.go:LoginHandler] User found: %s (ID: %d)", user.Nickname, user.ID)
+	event.UserID = user.ID
 
-	// 3. Verify the password
+	// 4. Verify the password
 	if !auth.CheckPasswordHash(req.Password, user.PasswordHash) {
 		log.Printf("[auth.go:LoginHandler] Invalid password for user: %s", user.Nickname)
+		event.Outcome = models.LoginFailure
+		recordLoginEvent(event)
 		RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
-	// 4. Create a new session
-	sessionToken, err := auth.CreateSession(user.ID)
+	// 5. Suspended accounts stop here, whatever the second factor
+	if refuseSuspendedLogin(w, user, event) {
+		return
+	}
+
+	// 6. With two-factor authentication the session waits for the code (see LoginTwoFactorHandler)
+	if user.TwoFactor {
+		pendingToken, err := auth.BeginLoginChallenge(user.ID, event.Identifier, req.RememberMe)
+		if err != nil {
+			log.Printf("[auth.go:LoginHandler] ERROR creating login challenge: %v", err)
+			RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		log.Printf("[auth.go:LoginHandler] Password accepted for user %s, waiting for the second factor", user.Nickname)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":             "Enter the code from your authenticator app",
+			"two_factor_required": true,
+			"pending_token":       pendingToken,
+			"expires_in":          int(auth.LoginChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	completeLogin(w, user, event, req.RememberMe)
+}
+
+// refuseSuspendedLogin answers 403 and records the attempt when a moderator has
+// suspended the user. It reports whether the login was refused.
+func refuseSuspendedLogin(w http.ResponseWriter, user *models.User, event *models.LoginEvent) bool {
+	if user.SuspendedUntil == nil || !user.SuspendedUntil.After(time.Now()) {
+		return false
+	}
+	log.Printf("[auth.go:refuseSuspendedLogin] Refused login of suspended user %s", user.Nickname)
+	event.Outcome = models.LoginSuspended
+	recordLoginEvent(event)
+	RespondWithError(w, http.StatusForbidden, "Your account is suspended until "+user.SuspendedUntil.UTC().Format(time.RFC1123))
+	return true
+}
+
+// completeLogin creates the session of a user who passed every login step,
+// sets its cookie and sends the user details.
+func completeLogin(w http.ResponseWriter, user *models.User, event *models.LoginEvent, remember bool) {
+	// 7. Create a new session
+	session, err := auth.CreateSession(user.ID, event.IP, event.UserAgent, remember)
 	if err != nil {
-		log.Printf("[auth.go:LoginHandler] ERROR creating session: %v", err)
+		log.Printf("[auth.go:completeLogin] ERROR creating session: %v", err)
 		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
-	// 5. Set the session cookie
-	auth.SetSessionCookie(w, sessionToken)
-	log.Printf("[auth.go:LoginHandler] Session created successfully for user: %s", user.Nickname)
+	// 8. Set the session cookie
+	auth.SetSessionCookie(w, session)
+	log.Printf("[auth.go:completeLogin] Session created successfully for user: %s", user.Nickname)
+	event.Outcome = models.LoginSuccess
+	recordLoginEvent(event)
 
-	// 6. Send a success response
+	// 9. Send a success response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
@@ -176,11 +250,51 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"message": "Login successful!",
 		"user":    userDetails,
+		// Moderators and admins have no privileges until they enable two-factor authentication
+		"two_factor_setup_required": auth.RequiresTwoFactor(user) && !user.TwoFactor,
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
+// recordLoginEvent stores a sign-in attempt; a failure to record it does not fail the login
+func recordLoginEvent(event *models.LoginEvent) {
+	if err := repo.RecordLoginEvent(event); err != nil {
+		log.Printf("[auth.go:recordLoginEvent] Failed to record %s login for %s: %v", event.Outcome, event.Identifier, err)
+	}
+}
+
+// GetLoginEventsHandler returns the current user's recent sign-in attempts, newest first
+func GetLoginEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit := 20 // default
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	events, err := repo.GetLoginEvents(user.ID, limit)
+	if err != nil {
+		log.Printf("[auth.go:GetLoginEventsHandler] Error getting login events: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve sign-in history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"logins": events,
+	})
+}
+
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("--- LOGOUT HANDLER: Request received ---")
 	// 1. Get the session cookie from the request
@@ -200,8 +314,11 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 2. Delete the session from the database
+	// 2. Delete the session from the database and close its WebSocket connections
 	sessionToken := cookie.Value
+	if session, err := auth.GetSessionByToken(sessionToken); err == nil && session != nil && hub != nil {
+		hub.CloseSessions([]int{session.ID})
+	}
 	err = auth.DeleteSession(sessionToken)
 	if err != nil {
 		// Log the error for debugging, but continue to ensure the client-side cookie is removed.
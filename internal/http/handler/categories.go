@@ -2,18 +2,276 @@ package handler
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
+	"regexp"
+	"strings"
+
+	"real-time-forum/internal/models"
 	"real-time-forum/internal/repo"
 )
 
-// GetAllCategoriesHandler retrieves all categories.
+// categoryOrderPath is the reorder endpoint under /api/categories/, so no
+// category may use it as its slug.
+const categoryOrderPath = "order"
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// slugify turns a category name into a slug: lowercase letters and digits,
+// with every other run of characters replaced by a single dash.
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// validateCategory checks the fields of a category about to be saved and
+// returns a message for the client, or "" when it is valid.
+func validateCategory(category *models.Category) string {
+	if category.Name == "" || len(category.Name) > 50 {
+		return "Category name must be 1 to 50 characters"
+	}
+	if !slugPattern.MatchString(category.Slug) || len(category.Slug) > 50 || category.Slug == categoryOrderPath {
+		return "Slug must be lowercase letters, digits and single dashes"
+	}
+	if len(category.Description) > 500 {
+		return "Description must be at most 500 characters"
+	}
+	return ""
+}
+
+// categorySlugFromPath returns the slug in /api/categories/{slug}[/suffix].
+func categorySlugFromPath(path, suffix string) string {
+	slug := strings.TrimPrefix(path, "/api/categories/")
+	slug = strings.TrimSuffix(slug, "/")
+	return strings.TrimSuffix(slug, suffix)
+}
+
+// loadCategory finds the category named in the path, sending a 404 or 500 when it can't.
+func loadCategory(w http.ResponseWriter, slug string) (*models.Category, bool) {
+	category, err := repo.GetCategoryBySlug(slug)
+	if err == repo.ErrNoRows {
+		RespondWithError(w, http.StatusNotFound, "Category not found")
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("[categories.go:loadCategory] Error loading category %q: %v", slug, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve category")
+		return nil, false
+	}
+	return category, true
+}
+
+// GetAllCategoriesHandler lists the categories in display order with their post
+// counts and last activity. Archived ones are included with ?include_archived=true.
 func GetAllCategoriesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	categories, err := repo.GetAllCategories()
+	includeArchived := r.URL.Query().Get("include_archived") == "true"
+	categories, err := repo.GetCategories(includeArchived)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve categories")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(categories)
+}
+
+// GetCategoryHandler handles GET /api/categories/{slug}.
+func GetCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	category, ok := loadCategory(w, categorySlugFromPath(r.URL.Path, ""))
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(category)
+}
+
+// GetCategoryPostsHandler handles GET /api/categories/{slug}/posts. It pages
+// like /api/posts and also works for archived categories.
+func GetCategoryPostsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	category, ok := loadCategory(w, categorySlugFromPath(r.URL.Path, "/posts"))
+	if !ok {
+		return
+	}
+	opts, ok := postListOptions(w, r)
+	if !ok {
+		return
+	}
+	opts.CategoryID = category.ID
+	respondWithPostPage(w, opts)
+}
+
+// CreateCategoryHandler handles POST /api/categories. The slug is derived from
+// the name unless given. Routes guard it with the category.manage permission.
+func CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	category := &models.Category{Name: strings.TrimSpace(*req.Name)}
+	category.Slug = slugify(category.Name)
+	if req.Slug != nil {
+		category.Slug = strings.TrimSpace(*req.Slug)
+	}
+	if req.Description != nil {
+		category.Description = strings.TrimSpace(*req.Description)
+	}
+	if msg := validateCategory(category); msg != "" {
+		RespondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	err := repo.CreateCategory(category)
+	if err == repo.ErrDuplicateEntry {
+		RespondWithError(w, http.StatusConflict, "Category name or slug already exists")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to create category")
+		return
+	}
+	log.Printf("[categories.go:CreateCategoryHandler] Created category %d %q", category.ID, category.Slug)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// UpdateCategoryHandler handles PATCH /api/categories/{slug}: rename, change
+// the slug or description, and archive or restore.
+func UpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	category, ok := loadCategory(w, categorySlugFromPath(r.URL.Path, ""))
+	if !ok {
+		return
+	}
+	if req.Name != nil {
+		category.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Slug != nil {
+		category.Slug = strings.TrimSpace(*req.Slug)
+	}
+	if req.Description != nil {
+		category.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Archived != nil {
+		category.Archived = *req.Archived
+	}
+	if msg := validateCategory(category); msg != "" {
+		RespondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	err := repo.UpdateCategory(category)
+	if err == repo.ErrDuplicateEntry {
+		RespondWithError(w, http.StatusConflict, "Category name or slug already exists")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to update category")
+		return
+	}
+	log.Printf("[categories.go:UpdateCategoryHandler] Updated category %d %q", category.ID, category.Slug)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(category)
+}
+
+// DeleteCategoryHandler handles DELETE /api/categories/{slug}. A category that
+// still has posts is only deleted with ?force=true; archiving is the safer option.
+func DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	category, ok := loadCategory(w, categorySlugFromPath(r.URL.Path, ""))
+	if !ok {
+		return
+	}
+	if category.PostCount > 0 && r.URL.Query().Get("force") != "true" {
+		RespondWithError(w, http.StatusConflict, "Category still has posts; archive it or delete with force=true")
+		return
+	}
+
+	if err := repo.DeleteCategory(category.ID); err != nil && err != repo.ErrNoRows {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+	log.Printf("[categories.go:DeleteCategoryHandler] Deleted category %d %q", category.ID, category.Slug)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderCategoriesHandler handles PUT /api/categories/order with
+// {"slugs": [...]}. Listed categories move to the front in that order.
+func ReorderCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		Slugs []string `json:"slugs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Slugs) == 0 {
+		RespondWithError(w, http.StatusBadRequest, "slugs must list the categories in their new order")
+		return
+	}
+
+	err := repo.ReorderCategories(req.Slugs)
+	if err == repo.ErrNoRows {
+		RespondWithError(w, http.StatusBadRequest, "Unknown category slug")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to reorder categories")
+		return
+	}
+
+	categories, err := repo.GetCategories(true)
 	if err != nil {
 		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve categories")
 		return
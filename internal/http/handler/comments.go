@@ -33,6 +33,27 @@ func CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("CreateCommentHandler: Authenticated user ID: %d, Nickname: %s", user.ID, user.Nickname)
+
+	// Deleted posts keep their thread readable but accept no new comments
+	post, err := repo.GetPostByID(int64(PostID))
+	if err == repo.ErrNoRows {
+		RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		log.Printf("CreateCommentHandler: repo.GetPostByID failed for post ID %d: %v", PostID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve post")
+		return
+	}
+	if post.Deleted {
+		RespondWithError(w, http.StatusGone, "Post has been deleted")
+		return
+	}
+	if post.Hidden {
+		RespondWithError(w, http.StatusGone, "Post has been hidden by a moderator")
+		return
+	}
+
 	var req models.CreateCommentRequest
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
@@ -42,19 +63,40 @@ func CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("CreateCommentHandler: Parsed comment data: PostID=%d, Content='%s'", PostID, req.Content)
 	comment := &models.Comment{
-		PostID:  PostID,
-		UserID:  user.ID,
-		Content: req.Content,
+		PostID:   PostID,
+		UserID:   user.ID,
+		ParentID: req.ParentID,
+		Content:  req.Content,
 	}
 
 	// Save the comment to the database
 	commentID, err := repo.CreateComment(comment)
+	if err == repo.ErrInvalidParent {
+		RespondWithError(w, http.StatusBadRequest, "Invalid parent comment")
+		return
+	}
+	if err == repo.ErrParentDeleted {
+		RespondWithError(w, http.StatusGone, "Parent comment has been deleted")
+		return
+	}
+	if err == repo.ErrParentHidden {
+		RespondWithError(w, http.StatusGone, "Parent comment has been hidden by a moderator")
+		return
+	}
 	if err != nil {
 		log.Printf("CreateCommentHandler: Error calling repo.CreateComment: %v", err)
 		RespondWithError(w, http.StatusInternalServerError, "Failed to create comment")
 		return
 	}
 	log.Printf("CreateCommentHandler: Comment created with ID: %d", commentID)
+
+	// Push the comment to everyone viewing this post
+	if created, err := repo.GetCommentByID(commentID); err != nil {
+		log.Printf("CreateCommentHandler: Loading comment %d for the live feed failed: %v", commentID, err)
+	} else if hub != nil {
+		hub.PublishCommentCreated(created)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -81,40 +123,61 @@ func GetCommentsByPostIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse pagination parameters
-	pageStr := r.URL.Query().Get("page")
+	// Parse pagination and threading parameters.
+	// format=flat (default) returns comments in thread order with depth and path,
+	// format=tree nests replies; depth limits how deep replies are loaded.
+	cursor := r.URL.Query().Get("cursor")
 	limitStr := r.URL.Query().Get("limit")
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "flat"
+	}
+	if format != "flat" && format != "tree" {
+		RespondWithError(w, http.StatusBadRequest, "format must be flat or tree")
+		return
+	}
 
-	page := 1
-	if pageStr != "" {
-		p, err := strconv.Atoi(pageStr)
-		if err == nil && p > 0 {
-			page = p
+	depth := 5
+	if depthStr := r.URL.Query().Get("depth"); depthStr != "" {
+		d, err := strconv.Atoi(depthStr)
+		if err != nil || d < 0 || d > 20 {
+			RespondWithError(w, http.StatusBadRequest, "depth must be between 0 and 20")
+			return
 		}
+		depth = d
 	}
 
 	limit := 5
 	if limitStr != "" {
 		l, err := strconv.Atoi(limitStr)
-		if err == nil && l > 0 {
+		if err == nil && l > 0 && l <= 50 {
 			limit = l
 		}
 	}
 
-	offset := (page - 1) * limit
+	log.Printf("GetCommentsByPostIDHandler: Fetching comments for post ID: %d, Limit: %d", postID, limit)
 
-	log.Printf("GetCommentsByPostIDHandler: Fetching comments for post ID: %d, Page: %d, Limit: %d", postID, page, limit)
+	// Comments by users a signed-in viewer blocked come back without content
+	viewerID := 0
+	if viewer, ok := auth.GetUserFromContext(r.Context()); ok {
+		viewerID = viewer.ID
+	}
 
-	comments, err := repo.GetCommentsByPostID(postID, limit, offset)
+	comments, nextCursor, err := repo.GetCommentsByPostID(postID, cursor, limit, depth, viewerID)
 	if err != nil {
+		if err == repo.ErrInvalidCursor {
+			RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
 		log.Printf("GetCommentsByPostIDHandler: repo.GetCommentsByPostID failed for post ID %d: %v", postID, err)
 		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve comments")
 		return
 	}
 
-	total, err := repo.CountCommentsByPostID(postID)
+	// Pages count threads, so total is the number of top-level comments
+	total, err := repo.CountTopLevelComments(postID)
 	if err != nil {
-		log.Printf("GetCommentsByPostIDHandler: repo.CountCommentsByPostID failed for post ID %d: %v", postID, err)
+		log.Printf("GetCommentsByPostIDHandler: repo.CountTopLevelComments failed for post ID %d: %v", postID, err)
 		// Don't fail the request if count fails, just assume unknown total or handle gracefully?
 		// For now, let's log and proceed, maybe set total to -1 or len(comments)
 		// But returning error is safer.
@@ -122,19 +185,109 @@ func GetCommentsByPostIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	allComments, err := repo.CountCommentsByPostID(postID)
+	if err != nil {
+		log.Printf("GetCommentsByPostIDHandler: repo.CountCommentsByPostID failed for post ID %d: %v", postID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to count comments")
+		return
+	}
+
+	if format == "tree" {
+		comments = nestComments(comments)
+	}
+
 	// Ensure comments is an empty slice instead of nil for JSON serialization
 	if comments == nil {
 		comments = []*models.Comment{}
 	}
 
 	response := map[string]interface{}{
-		"comments": comments,
-		"total":    total,
-		"page":     page,
-		"limit":    limit,
+		"comments":       comments,
+		"total":          total,
+		"total_comments": allComments,
+		"limit":          limit,
+		"format":         format,
+		"depth":          depth,
+		"next_cursor":    nextCursor,
+		"has_more":       nextCursor != "",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// nestComments turns a flat, path-ordered list of comments into trees of replies
+// and returns the top-level comments.
+func nestComments(flat []*models.Comment) []*models.Comment {
+	byID := make(map[int]*models.Comment, len(flat))
+	var roots []*models.Comment
+	for _, c := range flat {
+		byID[c.ID] = c
+		if c.ParentID == nil {
+			roots = append(roots, c)
+			continue
+		}
+		// Parents always come before their replies in path order
+		if parent, ok := byID[*c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		}
+	}
+	return roots
+}
+
+// DeleteCommentHandler handles DELETE /api/comments/{id}. Authors can delete
+// their own comments, and users with comment.delete.any anyone's. The comment is
+// soft-deleted so its replies stay in the thread.
+func DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok || user == nil {
+		RespondWithError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	commentID, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/comments/"), "/"))
+	if err != nil || commentID <= 0 {
+		RespondWithError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	comment, err := repo.GetCommentByID(int64(commentID))
+	if err == repo.ErrNoRows || (err == nil && comment.Deleted) {
+		RespondWithError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[comments.go:DeleteCommentHandler] repo.GetCommentByID failed for ID %d: %v", commentID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve comment")
+		return
+	}
+	if !auth.CanDeleteComment(user, comment.UserID) {
+		RespondWithError(w, http.StatusForbidden, "You cannot delete this comment")
+		return
+	}
+
+	if err := repo.SoftDeleteComment(commentID); err != nil {
+		if err == repo.ErrNoRows {
+			RespondWithError(w, http.StatusNotFound, "Comment not found")
+			return
+		}
+		RespondWithError(w, http.StatusInternalServerError, "Failed to delete comment")
+		return
+	}
+
+	log.Printf("[comments.go:DeleteCommentHandler] Comment %d deleted by user %d", commentID, user.ID)
+	if deleted, err := repo.GetCommentByID(int64(commentID)); err == nil && hub != nil {
+		hub.PublishCommentUpdated(deleted)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Comment deleted",
+		"comment_id": commentID,
+	})
+}
@@ -0,0 +1,405 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+// maxConversationNameLength caps the name of a group conversation
+const maxConversationNameLength = 100
+
+// GetConversationsHandler retrieves the direct and group conversations of the user,
+// most recently active first, with unread counts
+func GetConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limitStr := r.URL.Query().Get("limit")
+	limit := 20 // default
+	if limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 50 {
+			limit = l
+		}
+	}
+
+	conversations, err := repo.GetRecentConversations(user.ID, limit)
+	if err != nil {
+		log.Printf("[conversations.go:GetConversationsHandler] Error getting conversations: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve conversations")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"conversations": conversations,
+	})
+}
+
+// CreateConversationHandler creates a group conversation with the user as owner
+func CreateConversationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	name, msg := validateConversationName(req.Name)
+	if msg != "" {
+		RespondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	memberIDs, msg := validateInvitees(req.MemberIDs, user.ID)
+	if msg != "" {
+		RespondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	id, err := repo.CreateGroupConversation(user.ID, name, memberIDs)
+	if err != nil {
+		log.Printf("[conversations.go:CreateConversationHandler] Error creating conversation: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to create conversation")
+		return
+	}
+
+	conversation, err := repo.GetConversation(id, user.ID)
+	if err != nil {
+		log.Printf("[conversations.go:CreateConversationHandler] Error loading conversation %d: %v", id, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to load conversation")
+		return
+	}
+	notifyConversationMembers(id, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// ConversationHandler serves a single conversation: GET returns it with its members,
+// PATCH renames a group
+func ConversationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := conversationIDFromPath(r.URL.Path, "")
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		var req models.RenameConversationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		name, msg := validateConversationName(req.Name)
+		if msg != "" {
+			RespondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+		if err := repo.RenameConversation(id, user.ID, name); err != nil {
+			respondWithConversationError(w, "ConversationHandler", id, err)
+			return
+		}
+		notifyConversationMembers(id, nil)
+	default:
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	conversation, err := repo.GetConversation(id, user.ID)
+	if err != nil {
+		respondWithConversationError(w, "ConversationHandler", id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// InviteConversationMembersHandler adds users to a group the user belongs to
+func InviteConversationMembersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := conversationIDFromPath(r.URL.Path, "/members")
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	var req models.InviteMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	userIDs, msg := validateInvitees(req.UserIDs, user.ID)
+	if msg != "" {
+		RespondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if len(userIDs) == 0 {
+		RespondWithError(w, http.StatusBadRequest, "No users to invite")
+		return
+	}
+
+	if err := repo.AddConversationMembers(id, user.ID, userIDs); err != nil {
+		respondWithConversationError(w, "InviteConversationMembersHandler", id, err)
+		return
+	}
+	notifyConversationMembers(id, nil)
+
+	conversation, err := repo.GetConversation(id, user.ID)
+	if err != nil {
+		respondWithConversationError(w, "InviteConversationMembersHandler", id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// LeaveConversationHandler removes the user from a group
+func LeaveConversationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := conversationIDFromPath(r.URL.Path, "/leave")
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	if err := repo.LeaveConversation(id, user.ID); err != nil {
+		respondWithConversationError(w, "LeaveConversationHandler", id, err)
+		return
+	}
+	// The leaving user's other tabs drop the conversation too
+	notifyConversationMembers(id, []int{user.ID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Left conversation",
+	})
+}
+
+// GetConversationMessagesHandler retrieves one page of a conversation's messages,
+// paged like GetPrivateMessagesHandler, and marks the conversation as read
+func GetConversationMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := conversationIDFromPath(r.URL.Path, "/messages")
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	conversation, err := repo.GetConversation(id, user.ID)
+	if err != nil {
+		respondWithConversationError(w, "GetConversationMessagesHandler", id, err)
+		return
+	}
+
+	limit := 10
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l < limit {
+		limit = l
+	}
+
+	messages, nextCursor, err := repo.GetConversationMessages(id, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if err == repo.ErrInvalidCursor {
+			RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		log.Printf("[conversations.go:GetConversationMessagesHandler] Failed to get messages of conversation %d: %v", id, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve messages")
+		return
+	}
+
+	markConversationRead(conversation, user.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(struct {
+		Messages   interface{} `json:"messages"`
+		NextCursor string      `json:"next_cursor"`
+		HasMore    bool        `json:"has_more"`
+	}{messages, nextCursor, nextCursor != ""})
+}
+
+// MarkConversationReadHandler marks every message of a conversation as read for the user
+func MarkConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := conversationIDFromPath(r.URL.Path, "/read")
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	conversation, err := repo.GetConversation(id, user.ID)
+	if err != nil {
+		respondWithConversationError(w, "MarkConversationReadHandler", id, err)
+		return
+	}
+	markConversationRead(conversation, user.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Messages marked as read",
+	})
+}
+
+// markConversationRead marks a conversation as read and, for direct
+// conversations, sends a read receipt to the other user. The receipt also
+// refreshes the reader's sidebar; groups refresh it directly.
+func markConversationRead(conversation *models.Conversation, userID int) {
+	lastReadID, err := repo.MarkConversationRead(conversation.ID, userID)
+	if err != nil {
+		// Don't fail the request for this
+		log.Printf("[conversations.go:markConversationRead] Failed to mark conversation %d as read: %v", conversation.ID, err)
+		return
+	}
+	if hub == nil || lastReadID <= 0 {
+		return
+	}
+	if conversation.Kind == models.ConversationDirect {
+		hub.NotifyMessagesRead(userID, conversation.UserID, lastReadID)
+	} else {
+		hub.NotifySidebarChanged(conversation.ID, []int{userID})
+	}
+}
+
+// notifyConversationMembers pushes a conversation_updated event to the current
+// members of a conversation plus any extra users, such as one who just left
+func notifyConversationMembers(conversationID int, extra []int) {
+	if hub == nil {
+		return
+	}
+	members, err := repo.GetConversationMemberIDs(conversationID)
+	if err != nil {
+		log.Printf("[conversations.go:notifyConversationMembers] Failed to load members of conversation %d: %v", conversationID, err)
+		return
+	}
+	hub.NotifyConversationUpdated(conversationID, append(members, extra...))
+}
+
+// respondWithConversationError maps repository errors of conversation operations to HTTP errors
+func respondWithConversationError(w http.ResponseWriter, caller string, conversationID int, err error) {
+	switch err {
+	case repo.ErrNoRows:
+		RespondWithError(w, http.StatusNotFound, "Conversation not found")
+	case repo.ErrNotMember:
+		RespondWithError(w, http.StatusForbidden, "You are not a member of this conversation")
+	case repo.ErrNotGroup:
+		RespondWithError(w, http.StatusBadRequest, "Only group conversations support this operation")
+	default:
+		log.Printf("[conversations.go:%s] Error on conversation %d: %v", caller, conversationID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to process conversation")
+	}
+}
+
+// validateConversationName trims a group name and returns an error message when it is invalid
+func validateConversationName(name string) (string, string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "Conversation name is required"
+	}
+	if len([]rune(name)) > maxConversationNameLength {
+		return "", "Conversation name must be at most 100 characters"
+	}
+	return name, ""
+}
+
+// validateInvitees removes duplicates and the acting user from a list of user IDs
+// and returns an error message when one of them does not exist
+func validateInvitees(userIDs []int, actorID int) ([]int, string) {
+	seen := map[int]bool{actorID: true}
+	var valid []int
+	for _, id := range userIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if u, err := repo.GetUserByID(id); err != nil || u == nil {
+			return nil, "User " + strconv.Itoa(id) + " not found"
+		}
+		valid = append(valid, id)
+	}
+	return valid, ""
+}
+
+// conversationIDFromPath extracts the conversation ID from /api/conversations/{id}{suffix}.
+func conversationIDFromPath(urlPath, suffix string) (int, error) {
+	path := strings.TrimSuffix(urlPath, "/")
+	path = strings.TrimSuffix(path, suffix)
+	path = strings.TrimSuffix(path, "/")
+	return strconv.Atoi(strings.TrimPrefix(path, "/api/conversations/"))
+}
@@ -5,14 +5,16 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"time"
 
 	"real-time-forum/internal/auth"
 	"real-time-forum/internal/models"
 	"real-time-forum/internal/repo"
+	"real-time-forum/internal/ws"
 )
 
-// SendPrivateMessageHandler handles sending private messages
+// SendPrivateMessageHandler handles POST /api/messages/send. The message goes
+// through the hub like one sent on the socket: it is stored, pushed to the
+// recipient if online or queued, and shown on the sender's other connections.
 func SendPrivateMessageHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -40,32 +42,47 @@ func SendPrivateMessageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if receiver exists
-	_, err := repo.GetUserByID(req.ReceiverID)
+	if req.ReceiverID == user.ID {
+		RespondWithError(w, http.StatusBadRequest, "You cannot message yourself")
+		return
+	}
+
+	receiver, err := repo.GetUserByID(req.ReceiverID)
 	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if receiver == nil {
 		RespondWithError(w, http.StatusNotFound, "Receiver not found")
 		return
 	}
-
-	message := &models.PrivateMessage{
-		SenderID:   user.ID,
-		ReceiverID: req.ReceiverID,
-		Content:    req.Content,
-		CreatedAt:  time.Now(),
-		IsRead:     false,
+	if hub == nil {
+		RespondWithError(w, http.StatusServiceUnavailable, "Chat is not available")
+		return
 	}
 
-	err = repo.CreatePrivateMessage(message)
-	if err != nil {
-		log.Printf("[messages.go:SendPrivateMessageHandler] Error creating private message: %v", err)
+	result := hub.SendPrivateMessage(user.ID, user.Nickname, req.ReceiverID, req.Content)
+	if result.Err == ws.ErrBlockedPair || result.Err == repo.ErrBlocked {
+		RespondWithError(w, http.StatusForbidden, "You cannot message this user")
+		return
+	}
+	if result.Err != nil {
+		log.Printf("[messages.go:SendPrivateMessageHandler] Error sending private message: %v", result.Err)
 		RespondWithError(w, http.StatusInternalServerError, "Failed to send message")
 		return
 	}
 
+	status := models.DeliveryQueued
+	if result.Delivered {
+		status = models.DeliveryDelivered
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Message sent successfully",
+		"success":         true,
+		"message":         "Message sent successfully",
+		"message_id":      result.Message.MessageID,
+		"conversation_id": result.Message.ConversationID,
+		"status":          status,
 	})
 }
 func MarkMessageRead(w http.ResponseWriter, r *http.Request) {
@@ -92,12 +109,15 @@ func MarkMessageRead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = repo.MarkMessagesAsRead(otherUserID, user.ID)
+	lastReadID, err := repo.MarkMessagesAsRead(otherUserID, user.ID)
 	if err != nil {
 		log.Printf("[messages.go:MarkMessageRead] Failed to mark messages as read: %v", err)
 		RespondWithError(w, http.StatusInternalServerError, "Failed to mark messages as read")
 		return
 	}
+	if hub != nil {
+		hub.NotifyMessagesRead(user.ID, otherUserID, lastReadID)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -143,16 +163,15 @@ func GetPrivateMessagesHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	offsetStr := r.URL.Query().Get("offset")
-	offset := 0 // default
-	if offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
-	}
+	// cursor is the next_cursor of the previous (newer) page; empty loads the latest messages
+	cursor := r.URL.Query().Get("cursor")
 
-	messages, err := repo.GetPrivateMessagesBetweenUsers(user.ID, otherUserID, limit, offset)
+	messages, nextCursor, err := repo.GetPrivateMessagesBetweenUsers(user.ID, otherUserID, cursor, limit)
 	if err != nil {
+		if err == repo.ErrInvalidCursor {
+			RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
 		// Flow: Failed to retrieve messages
 		log.Printf("[messages.go:GetPrivateMessagesHandler] Failed to get messages: %v", err)
 		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve messages")
@@ -161,54 +180,24 @@ func GetPrivateMessagesHandler(w http.ResponseWriter, r *http.Request) {
 	// Flow: Retrieved messages successfully
 	log.Printf("Messages: Retrieved %d messages for user %d", len(messages), user.ID)
 
-	// Mark messages as read
-	err = repo.MarkMessagesAsRead(otherUserID, user.ID)
+	// Mark messages as read and let the sender know
+	lastReadID, err := repo.MarkMessagesAsRead(otherUserID, user.ID)
 	if err != nil {
 		log.Printf("[messages.go:GetPrivateMessagesHandler] Failed to mark messages as read: %v", err)
 		// Don't fail the request for this
+	} else if hub != nil {
+		hub.NotifyMessagesRead(user.ID, otherUserID, lastReadID)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store")
 	json.NewEncoder(w).Encode(struct {
-		Messages interface{} `json:"messages"`
-	}{messages})
+		Messages   interface{} `json:"messages"`
+		NextCursor string      `json:"next_cursor"`
+		HasMore    bool        `json:"has_more"`
+	}{messages, nextCursor, nextCursor != ""})
 
 }
 
-// GetConversationsHandler retrieves recent conversations for the user
-func GetConversationsHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	user, ok := auth.GetUserFromContext(r.Context())
-	if !ok {
-		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-
-	limitStr := r.URL.Query().Get("limit")
-	limit := 20 // default
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 50 {
-			limit = l
-		}
-	}
-
-	conversations, err := repo.GetRecentConversations(user.ID, limit)
-	if err != nil {
-		log.Printf("[messages.go:GetConversationsHandler] Error getting conversations: %v", err)
-		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve conversations")
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"conversations": conversations,
-	})
-}
-
 // GetUnreadCountHandler returns the count of unread messages for the user
 func GetUnreadCountHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
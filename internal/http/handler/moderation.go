@@ -0,0 +1,441 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+// maxSuspensionHours caps the length of a suspension at one year.
+const maxSuspensionHours = 24 * 365
+
+// reportableTarget reports whether users can report, and moderators hide, this kind of content.
+func reportableTarget(targetType string) bool {
+	return targetType == models.TargetPost || targetType == models.TargetComment || targetType == models.TargetMessage
+}
+
+// CreateReportHandler handles POST /api/reports: any signed-in user can report
+// a post, a comment or a private message they received, with a reason.
+func CreateReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok || user == nil {
+		RespondWithError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req models.ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if !reportableTarget(req.TargetType) || req.TargetID <= 0 {
+		RespondWithError(w, http.StatusBadRequest, "target_type must be post, comment or message, with a target_id")
+		return
+	}
+	if req.Reason == "" || len(req.Reason) > 500 {
+		RespondWithError(w, http.StatusBadRequest, "Reason must be 1 to 500 characters")
+		return
+	}
+
+	authorID, content, err := repo.ReportTarget(req.TargetType, req.TargetID, user.ID)
+	if err == repo.ErrNoRows {
+		RespondWithError(w, http.StatusNotFound, "Nothing to report with this ID")
+		return
+	}
+	if err != nil {
+		log.Printf("[moderation.go:CreateReportHandler] Error loading %s %d: %v", req.TargetType, req.TargetID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to create report")
+		return
+	}
+	if authorID == user.ID {
+		RespondWithError(w, http.StatusBadRequest, "You cannot report your own content")
+		return
+	}
+
+	report := &models.Report{
+		ReporterID:   user.ID,
+		TargetType:   req.TargetType,
+		TargetID:     req.TargetID,
+		TargetUserID: authorID,
+		Content:      content,
+		Reason:       req.Reason,
+	}
+	err = repo.CreateReport(report)
+	if err == repo.ErrDuplicateEntry {
+		RespondWithError(w, http.StatusConflict, "You already reported this and it is waiting for review")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to create report")
+		return
+	}
+	log.Printf("[moderation.go:CreateReportHandler] User %d reported %s %d", user.ID, report.TargetType, report.TargetID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "Thanks, a moderator will review your report",
+		"report_id": report.ID,
+	})
+}
+
+// GetReportsHandler handles GET /api/moderation/reports, the moderation queue.
+// It lists open reports oldest first; status (open, actioned, dismissed or all),
+// target_type and user_id (the reported user) filter it, and cursor and limit page it.
+func GetReportsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	opts := models.ReportListOptions{
+		Status:     q.Get("status"),
+		TargetType: q.Get("target_type"),
+		Cursor:     q.Get("cursor"),
+		Limit:      20,
+	}
+	switch opts.Status {
+	case "":
+		opts.Status = models.ReportOpen
+	case "all":
+		opts.Status = ""
+	case models.ReportOpen, models.ReportActioned, models.ReportDismissed:
+	default:
+		RespondWithError(w, http.StatusBadRequest, "status must be open, actioned, dismissed or all")
+		return
+	}
+	if opts.TargetType != "" && !reportableTarget(opts.TargetType) {
+		RespondWithError(w, http.StatusBadRequest, "target_type must be post, comment or message")
+		return
+	}
+	if userStr := q.Get("user_id"); userStr != "" {
+		id, err := strconv.Atoi(userStr)
+		if err != nil || id <= 0 {
+			RespondWithError(w, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+		opts.TargetUserID = id
+	}
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 100 {
+		opts.Limit = l
+	}
+
+	reports, nextCursor, err := repo.GetReports(opts)
+	if err == repo.ErrInvalidCursor {
+		RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve reports")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports":     reports,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
+	})
+}
+
+// ReportHandler handles GET and PATCH /api/moderation/reports/{id}. PATCH with
+// {"status": ..., "note": ...} dismisses, resolves or reopens the report.
+func ReportHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok || user == nil {
+		RespondWithError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	reportID, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/moderation/reports/"), "/"))
+	if err != nil || reportID <= 0 {
+		RespondWithError(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		var req models.ReportStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		req.Note = strings.TrimSpace(req.Note)
+		if req.Status != models.ReportOpen && req.Status != models.ReportActioned && req.Status != models.ReportDismissed {
+			RespondWithError(w, http.StatusBadRequest, "status must be open, actioned or dismissed")
+			return
+		}
+		if len(req.Note) > 500 {
+			RespondWithError(w, http.StatusBadRequest, "Note must be at most 500 characters")
+			return
+		}
+
+		err := repo.SetReportStatus(reportID, user.ID, req.Status, req.Note)
+		if err == repo.ErrNoRows {
+			RespondWithError(w, http.StatusNotFound, "Report not found")
+			return
+		}
+		if err == repo.ErrReportUnchanged {
+			RespondWithError(w, http.StatusConflict, "Report is already "+req.Status)
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to update report")
+			return
+		}
+		log.Printf("[moderation.go:ReportHandler] Moderator %d set report %d to %s", user.ID, reportID, req.Status)
+	default:
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	report, err := repo.GetReport(reportID)
+	if err == repo.ErrNoRows {
+		RespondWithError(w, http.StatusNotFound, "Report not found")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ModerationActionHandler handles POST /api/moderation/actions: hide or unhide
+// content, and warn, suspend or unsuspend a user. Every action needs a reason
+// and is written to the moderation log. With report_id, the target comes from
+// the report, an explicit target must match it, and the report is closed as actioned.
+func ModerationActionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok || user == nil {
+		RespondWithError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req models.ModerationActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || len(req.Reason) > 500 {
+		RespondWithError(w, http.StatusBadRequest, "Reason must be 1 to 500 characters")
+		return
+	}
+
+	if req.ReportID != 0 {
+		report, err := repo.GetReport(req.ReportID)
+		if err == repo.ErrNoRows {
+			RespondWithError(w, http.StatusNotFound, "Report not found")
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve report")
+			return
+		}
+		// Closing the report as actioned must mean the action hit what was reported
+		if (req.TargetType != "" || req.TargetID != 0) && (req.TargetType != report.TargetType || req.TargetID != report.TargetID) {
+			RespondWithError(w, http.StatusBadRequest, "target_type and target_id do not match the report")
+			return
+		}
+		if req.UserID != 0 && req.UserID != report.TargetUserID {
+			RespondWithError(w, http.StatusBadRequest, "user_id does not match the report")
+			return
+		}
+		req.TargetType, req.TargetID = report.TargetType, report.TargetID
+		req.UserID = report.TargetUserID
+	}
+
+	entry := &models.ModerationLogEntry{
+		ModeratorID:       user.ID,
+		ModeratorNickname: user.Nickname,
+		Action:            req.Action,
+		ReportID:          req.ReportID,
+		Reason:            req.Reason,
+	}
+	var target *models.User
+	switch req.Action {
+	case models.ActionHide, models.ActionUnhide:
+		if !reportableTarget(req.TargetType) || req.TargetID <= 0 {
+			RespondWithError(w, http.StatusBadRequest, "target_type must be post, comment or message, with a target_id")
+			return
+		}
+		entry.TargetType, entry.TargetID = req.TargetType, req.TargetID
+	case models.ActionWarn, models.ActionSuspend, models.ActionUnsuspend:
+		var status int
+		var msg string
+		target, status, msg = moderatedUser(user, req.UserID)
+		if target == nil {
+			RespondWithError(w, status, msg)
+			return
+		}
+		entry.TargetType, entry.TargetID, entry.TargetUserID = models.TargetUser, target.ID, target.ID
+		if req.Action == models.ActionSuspend {
+			if req.DurationHours <= 0 || req.DurationHours > maxSuspensionHours {
+				RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("duration_hours must be 1 to %d", maxSuspensionHours))
+				return
+			}
+			until := time.Now().Add(time.Duration(req.DurationHours) * time.Hour)
+			entry.ExpiresAt = &until
+		}
+	default:
+		RespondWithError(w, http.StatusBadRequest, "action must be hide, unhide, warn, suspend or unsuspend")
+		return
+	}
+
+	err := repo.ApplyModerationAction(entry)
+	if err == repo.ErrNoRows {
+		if req.Action == models.ActionUnsuspend {
+			RespondWithError(w, http.StatusConflict, "User is not suspended")
+		} else {
+			RespondWithError(w, http.StatusNotFound, "Target not found")
+		}
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to apply moderation action")
+		return
+	}
+	log.Printf("[moderation.go:ModerationActionHandler] Moderator %d: %s %s %d", user.ID, entry.Action, entry.TargetType, entry.TargetID)
+
+	applyModerationEffects(entry, target)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// moderatedUser loads the user a warning or suspension is about. Moderators
+// cannot act on themselves, and only admins can act on other staff. It returns
+// nil with a status and message for the client when the action is not allowed.
+func moderatedUser(moderator *models.User, userID int) (*models.User, int, string) {
+	if userID <= 0 {
+		return nil, http.StatusBadRequest, "user_id or report_id is required"
+	}
+	if userID == moderator.ID {
+		return nil, http.StatusBadRequest, "You cannot moderate yourself"
+	}
+	target, err := repo.GetUserByID(userID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to retrieve user"
+	}
+	if target == nil {
+		return nil, http.StatusNotFound, "User not found"
+	}
+	if target.Role != models.RoleUser && moderator.Role != models.RoleAdmin {
+		return nil, http.StatusForbidden, "Only admins can warn or suspend moderators and admins"
+	}
+	return target, 0, ""
+}
+
+// applyModerationEffects tells clients about an action that was just logged:
+// feed updates for hidden content, a notice to the user concerned, and for
+// suspensions, closing every session of the user.
+func applyModerationEffects(entry *models.ModerationLogEntry, target *models.User) {
+	if hub != nil {
+		switch entry.TargetType {
+		case models.TargetPost:
+			if post, err := repo.GetPostByID(int64(entry.TargetID)); err == nil {
+				hub.PublishPostUpdated(post)
+			}
+		case models.TargetComment:
+			if comment, err := repo.GetCommentByID(int64(entry.TargetID)); err == nil {
+				hub.PublishCommentUpdated(comment)
+			}
+		}
+		if entry.Action != models.ActionSuspend && entry.TargetUserID != 0 {
+			hub.NotifyModeration(entry.TargetUserID, entry)
+		}
+	}
+
+	switch entry.Action {
+	case models.ActionWarn:
+		sendMail(mail.Message{
+			To:      target.Email,
+			Subject: "A warning from the moderators",
+			Body: fmt.Sprintf("Hi %s,\n\nA moderator sent you a warning:\n\n%s\n\n"+
+				"Please keep to the forum rules; repeated problems can lead to a suspension.\n", target.Nickname, entry.Reason),
+		})
+	case models.ActionSuspend:
+		revoked, err := auth.RevokeOtherSessions(target.ID, 0)
+		if err != nil {
+			log.Printf("[moderation.go:applyModerationEffects] Error revoking sessions of user %d: %v", target.ID, err)
+		} else if hub != nil {
+			hub.CloseSessions(revoked)
+		}
+		sendMail(mail.Message{
+			To:      target.Email,
+			Subject: "Your account is suspended",
+			Body: fmt.Sprintf("Hi %s,\n\nA moderator suspended your account until %s:\n\n%s\n",
+				target.Nickname, entry.ExpiresAt.UTC().Format(time.RFC1123), entry.Reason),
+		})
+	}
+}
+
+// GetModerationLogHandler handles GET /api/moderation/log, newest entry first.
+// action, user_id (the user concerned) and moderator_id filter it; cursor and limit page it.
+func GetModerationLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	opts := models.ModerationLogOptions{
+		Action: q.Get("action"),
+		Cursor: q.Get("cursor"),
+		Limit:  50,
+	}
+	for param, dest := range map[string]*int{"user_id": &opts.TargetUserID, "moderator_id": &opts.ModeratorID} {
+		if value := q.Get(param); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil || id <= 0 {
+				RespondWithError(w, http.StatusBadRequest, "Invalid "+param)
+				return
+			}
+			*dest = id
+		}
+	}
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 100 {
+		opts.Limit = l
+	}
+
+	entries, nextCursor, err := repo.GetModerationLog(opts)
+	if err == repo.ErrInvalidCursor {
+		RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve moderation log")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries":     entries,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"real-time-forum/internal/auth"
+	"real-time-forum/internal/diff"
 	"real-time-forum/internal/models"
 	"real-time-forum/internal/repo"
 )
@@ -32,6 +33,12 @@ func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("[posts.go:CreatePostHandler] Authenticated user ID: %d, Nickname: %s", user.ID, user.Nickname)
 
+	// Only verified accounts can start threads; unverified ones can still comment and chat
+	if !user.EmailVerified {
+		RespondWithError(w, http.StatusForbidden, "Verify your email address to create posts")
+		return
+	}
+
 	// 3. Parse the JSON request body
 	var req models.CreatePostRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -48,6 +55,15 @@ func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 		Content: req.Content,
 	}
 
+	// Posts can only go into existing categories that are not archived
+	if ok, err := repo.CategoriesAcceptPosts(0, req.CategoryIDs); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to check categories")
+		return
+	} else if !ok {
+		RespondWithError(w, http.StatusBadRequest, "Unknown or archived category")
+		return
+	}
+
 	// --- DEBUG: Log the parsed data ---
 	log.Printf("[posts.go:CreatePostHandler] Parsed post data: Title='%s', Content='%s', CategoryIDs=%v", post.Title, post.Content, req.CategoryIDs)
 
@@ -60,6 +76,14 @@ func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("CreatePostHandler: Successfully created post with ID: %d", postID)
+
+	// Push the new post to every open feed
+	if created, err := repo.GetPostByID(postID); err != nil {
+		log.Printf("[posts.go:CreatePostHandler] Loading post %d for the live feed failed: %v", postID, err)
+	} else if hub != nil {
+		hub.PublishPostCreated(created)
+	}
+
 	// 6. Send a success response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -69,17 +93,64 @@ func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetAllPostsHandler retrieves all posts for the main feed.
+// GetAllPostsHandler retrieves one page of posts for the main feed.
+// Query parameters: limit (default 20, max 50), sort (new or top) and cursor
+// (the next_cursor of the previous page, only valid with the same sort).
 func GetAllPostsHandler(w http.ResponseWriter, r *http.Request) {
-	posts, err := repo.GetAllPosts()
+	opts, ok := postListOptions(w, r)
+	if !ok {
+		return
+	}
+	respondWithPostPage(w, opts)
+}
+
+// postListOptions reads the feed query parameters shared by every post list.
+// A signed-in viewer does not see the posts of users they blocked.
+// It answers 400 and returns false when they are invalid.
+func postListOptions(w http.ResponseWriter, r *http.Request) (models.PostListOptions, bool) {
+	opts := models.PostListOptions{
+		Cursor: r.URL.Query().Get("cursor"),
+		Limit:  20,
+		Sort:   r.URL.Query().Get("sort"),
+	}
+	if viewer, ok := auth.GetUserFromContext(r.Context()); ok {
+		opts.ViewerID = viewer.ID
+	}
+	if opts.Sort == "" {
+		opts.Sort = models.PostSortNew
+	}
+	if opts.Sort != models.PostSortNew && opts.Sort != models.PostSortTop {
+		RespondWithError(w, http.StatusBadRequest, "sort must be new or top")
+		return opts, false
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 50 {
+			opts.Limit = l
+		}
+	}
+	return opts, true
+}
+
+// respondWithPostPage loads one page of posts and sends it with its cursor.
+func respondWithPostPage(w http.ResponseWriter, opts models.PostListOptions) {
+	posts, nextCursor, err := repo.GetPosts(opts)
 	if err != nil {
+		if err == repo.ErrInvalidCursor {
+			RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		log.Printf("[posts.go:respondWithPostPage] repo.GetPosts failed: %v", err)
 		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve posts")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(posts)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"posts":       posts,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
+	})
 }
 
 // GetPostByIDHandler retrieves a single post by its ID.
@@ -99,6 +170,10 @@ func GetPostByIDHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("GetPostByIDHandler: Fetching post with ID: %d", postID)
 
 	post, err := repo.GetPostByID(postID)
+	if err == repo.ErrNoRows {
+		RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
 	if err != nil {
 		// The repo layer will log the specific DB error. This log is for the handler context.
 		log.Printf("[posts.go:GetPostByIDHandler] repo.GetPostByID failed for ID %d: %v", postID, err)
@@ -111,3 +186,232 @@ func GetPostByIDHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("GetPostByIDHandler: Successfully retrieved and sent post ID: %d", postID)
 	json.NewEncoder(w).Encode(post)
 }
+
+// UpdatePostHandler handles PUT and PATCH /api/posts/{id}.
+// Only the author or a moderator may edit a post. Every edit is stored as a revision.
+func UpdatePostHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok || user == nil {
+		RespondWithError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	postID, err := postIDFromPath(r.URL.Path, "")
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var req models.UpdatePostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if r.Method == http.MethodPut && (req.Title == nil || req.Content == nil) {
+		RespondWithError(w, http.StatusBadRequest, "title and content are required")
+		return
+	}
+
+	post, err := repo.GetPostByID(int64(postID))
+	if err == repo.ErrNoRows || (err == nil && (post.Deleted || post.Hidden)) {
+		RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[posts.go:UpdatePostHandler] repo.GetPostByID failed for ID %d: %v", postID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve post")
+		return
+	}
+	if !auth.CanEditPost(user, post.UserID) {
+		RespondWithError(w, http.StatusForbidden, "You cannot edit this post")
+		return
+	}
+
+	title, content := post.Title, post.Content
+	if req.Title != nil {
+		title = strings.TrimSpace(*req.Title)
+	}
+	if req.Content != nil {
+		content = strings.TrimSpace(*req.Content)
+	}
+	if title == "" || content == "" {
+		RespondWithError(w, http.StatusBadRequest, "title and content cannot be empty")
+		return
+	}
+	var categoryIDs []int
+	if req.CategoryIDs != nil {
+		categoryIDs = *req.CategoryIDs
+		if categoryIDs == nil {
+			categoryIDs = []int{}
+		}
+		if ok, err := repo.CategoriesAcceptPosts(postID, categoryIDs); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to check categories")
+			return
+		} else if !ok {
+			RespondWithError(w, http.StatusBadRequest, "Unknown or archived category")
+			return
+		}
+	}
+
+	if err := repo.UpdatePost(postID, user.ID, title, content, categoryIDs); err != nil {
+		log.Printf("[posts.go:UpdatePostHandler] repo.UpdatePost failed for ID %d: %v", postID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to update post")
+		return
+	}
+
+	updated, err := repo.GetPostByID(int64(postID))
+	if err != nil {
+		log.Printf("[posts.go:UpdatePostHandler] Reloading post %d failed: %v", postID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve post")
+		return
+	}
+
+	log.Printf("[posts.go:UpdatePostHandler] Post %d edited by user %d", postID, user.ID)
+	if hub != nil {
+		hub.PublishPostUpdated(updated)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeletePostHandler handles DELETE /api/posts/{id}.
+// The post is soft-deleted so its comment thread stays intact.
+func DeletePostHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok || user == nil {
+		RespondWithError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	postID, err := postIDFromPath(r.URL.Path, "")
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	post, err := repo.GetPostByID(int64(postID))
+	if err == repo.ErrNoRows || (err == nil && post.Deleted) {
+		RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[posts.go:DeletePostHandler] repo.GetPostByID failed for ID %d: %v", postID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve post")
+		return
+	}
+	if !auth.CanDeletePost(user, post.UserID) {
+		RespondWithError(w, http.StatusForbidden, "You cannot delete this post")
+		return
+	}
+
+	if err := repo.SoftDeletePost(postID); err != nil {
+		if err == repo.ErrNoRows {
+			RespondWithError(w, http.StatusNotFound, "Post not found")
+			return
+		}
+		RespondWithError(w, http.StatusInternalServerError, "Failed to delete post")
+		return
+	}
+
+	log.Printf("[posts.go:DeletePostHandler] Post %d deleted by user %d", postID, user.ID)
+	if deleted, err := repo.GetPostByID(int64(postID)); err == nil && hub != nil {
+		hub.PublishPostUpdated(deleted)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Post deleted",
+		"post_id": postID,
+	})
+}
+
+// GetPostRevisionsHandler handles GET /api/posts/{id}/revisions.
+// Without parameters it lists every revision. With from and/or to (revision numbers)
+// it returns a line diff of the title and content between the two revisions;
+// to defaults to the latest revision and from to the one before it.
+func GetPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	postID, err := postIDFromPath(r.URL.Path, "/revisions")
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	post, err := repo.GetPostByID(int64(postID))
+	if err == repo.ErrNoRows || (err == nil && (post.Deleted || post.Hidden)) {
+		RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve post")
+		return
+	}
+
+	revisions, err := repo.GetPostRevisions(postID)
+	if err != nil {
+		log.Printf("[posts.go:GetPostRevisionsHandler] repo.GetPostRevisions failed for ID %d: %v", postID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve revisions")
+		return
+	}
+
+	fromStr, toStr := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if fromStr == "" && toStr == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{"revisions": revisions})
+		return
+	}
+
+	to := len(revisions)
+	if toStr != "" {
+		if to, err = strconv.Atoi(toStr); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid to parameter")
+			return
+		}
+	}
+	from := to - 1
+	if fromStr != "" {
+		if from, err = strconv.Atoi(fromStr); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid from parameter")
+			return
+		}
+	}
+	// Revisions are numbered 1..n, so revision k is at index k-1.
+	if from < 1 || to < 1 || from > len(revisions) || to > len(revisions) {
+		RespondWithError(w, http.StatusNotFound, "Revision not found")
+		return
+	}
+	older, newer := revisions[from-1], revisions[to-1]
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":    older,
+		"to":      newer,
+		"title":   diff.Lines(older.Title, newer.Title),
+		"content": diff.Lines(older.Content, newer.Content),
+	})
+}
+
+// postIDFromPath extracts the post ID from /api/posts/{id}{suffix}.
+func postIDFromPath(urlPath, suffix string) (int, error) {
+	path := strings.TrimSuffix(urlPath, "/")
+	path = strings.TrimSuffix(path, suffix)
+	path = strings.TrimSuffix(path, "/")
+	return strconv.Atoi(strings.TrimPrefix(path, "/api/posts/"))
+}
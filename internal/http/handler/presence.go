@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+// PresenceStatusHandler handles PUT /api/users/me/status with {"status": "away"}.
+// Users pick online, away or dnd; the status is kept across connections and
+// the hub tells everyone who can see the user. Idle and offline are set by the server.
+func PresenceStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.PresenceStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !models.IsSelectablePresence(req.Status) {
+		RespondWithError(w, http.StatusBadRequest, "Status must be online, away or dnd")
+		return
+	}
+
+	if err := repo.UpdatePresenceStatus(user.ID, req.Status); err != nil {
+		log.Printf("[presence.go:PresenceStatusHandler] repo.UpdatePresenceStatus failed for user %d: %v", user.ID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to update status")
+		return
+	}
+	if hub != nil {
+		hub.SetPresenceStatus(user.ID, req.Status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"presence_status": req.Status,
+	})
+}
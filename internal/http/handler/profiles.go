@@ -0,0 +1,365 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	netmail "net/mail"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+// profilePostsLimit is the number of recent posts included in a profile.
+const profilePostsLimit = 10
+
+// profileUserID reads the user ID of /api/users/{id} and /api/users/{id}/posts paths.
+func profileUserID(path string) (int, error) {
+	idStr := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(path, "/api/users/"), "/"), "/posts")
+	return strconv.Atoi(idStr)
+}
+
+// GetProfileHandler handles GET /api/users/{id}. Anyone can read a profile;
+// full name, age and gender are only shown as the user allowed.
+func GetProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	userID, err := profileUserID(r.URL.Path)
+	if err != nil || userID <= 0 {
+		RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	viewer, _ := auth.GetUserFromContext(r.Context())
+	respondWithProfile(w, userID, viewer)
+}
+
+// OwnProfileHandler handles GET /api/users/me, the caller's full profile with
+// their email and visibility settings, and PATCH /api/users/me to change them.
+func OwnProfileHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		var req models.ProfileUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		if msg := validateProfileUpdate(&req); msg != "" {
+			RespondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+		if err := repo.UpdateProfile(user.ID, &req); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to update profile")
+			return
+		}
+		log.Printf("[profiles.go:OwnProfileHandler] User %d updated their profile", user.ID)
+	default:
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	respondWithProfile(w, user.ID, user)
+}
+
+// validateProfileUpdate trims the fields of a profile update and checks them
+// against the registration rules. It returns an error message, or "" when valid.
+func validateProfileUpdate(req *models.ProfileUpdateRequest) string {
+	for _, name := range []*string{req.FirstName, req.LastName} {
+		if name == nil {
+			continue
+		}
+		*name = strings.TrimSpace(*name)
+		if *name == "" || len(*name) > 50 {
+			return "First and last name must be 1 to 50 characters"
+		}
+	}
+	if req.Age != nil && (*req.Age <= 13 || *req.Age > 120) {
+		return "age must be between 14 and 120"
+	}
+	if req.Gender != nil {
+		*req.Gender = strings.TrimSpace(*req.Gender)
+		if len(*req.Gender) > 30 {
+			return "Gender must be at most 30 characters"
+		}
+	}
+	return ""
+}
+
+// respondWithProfile sends the profile of a user as the viewer may see it.
+// The user, moderators and admins see every detail; only the user sees their
+// email and visibility settings. Presence is hidden between users where one
+// blocked the other, like on the socket.
+func respondWithProfile(w http.ResponseWriter, userID int, viewer *models.User) {
+	profile, visibility, err := repo.GetProfile(userID)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve profile")
+		return
+	}
+	if profile == nil {
+		RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	viewerID := 0
+	if viewer != nil {
+		viewerID = viewer.ID
+	}
+	own := viewerID == profile.ID
+
+	if own {
+		profile.Visibility = visibility
+	} else {
+		profile.Email = ""
+		profile.PresenceStatus = ""
+		if viewer == nil || !auth.HasPermission(viewer, models.PermModerate) {
+			if !visibility.FullName {
+				profile.FirstName, profile.LastName = "", ""
+			}
+			if !visibility.Age {
+				profile.Age = 0
+			}
+			if !visibility.Gender {
+				profile.Gender = ""
+			}
+		}
+	}
+
+	hidePresence := false
+	if viewerID != 0 && !own {
+		if hidePresence, err = repo.IsBlockedBetween(viewerID, profile.ID); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve profile")
+			return
+		}
+	}
+	profile.Status = visibleStatus(presenceStatuses(), profile.ID, hidePresence)
+	profile.IsOnline = profile.Status != models.PresenceOffline
+	if hidePresence {
+		profile.LastSeenAt = nil
+	}
+
+	posts, nextCursor, err := repo.GetPosts(models.PostListOptions{
+		Limit:    profilePostsLimit,
+		Sort:     models.PostSortNew,
+		AuthorID: profile.ID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		log.Printf("[profiles.go:respondWithProfile] repo.GetPosts failed for user %d: %v", profile.ID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve profile")
+		return
+	}
+	if posts == nil {
+		posts = []*models.Post{}
+	}
+	profile.Posts = posts
+	profile.NextCursor = nextCursor
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// GetUserPostsHandler handles GET /api/users/{id}/posts, the posts of one user
+// with the same cursor, limit and sort parameters as /api/posts.
+func GetUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	userID, err := profileUserID(r.URL.Path)
+	if err != nil || userID <= 0 {
+		RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	author, err := repo.GetUserByID(userID)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user")
+		return
+	}
+	if author == nil {
+		RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	opts, ok := postListOptions(w, r)
+	if !ok {
+		return
+	}
+	opts.AuthorID = author.ID
+	respondWithPostPage(w, opts)
+}
+
+// checkCurrentPassword verifies the password a signed-in user typed to confirm an
+// account change. Attempts go through the login lockout and are recorded as sign-in
+// attempts, so a stolen session cannot be used to guess the password. On failure
+// it responds and returns false.
+func checkCurrentPassword(w http.ResponseWriter, r *http.Request, user *models.User, password string) bool {
+	event := &models.LoginEvent{
+		UserID:     user.ID,
+		Identifier: auth.NormalizeIdentifier(user.Nickname),
+		IP:         auth.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+	}
+
+	wait, err := auth.LoginRetryAfter(event.Identifier, event.IP)
+	if err != nil {
+		log.Printf("[profiles.go:checkCurrentPassword] ERROR checking failed logins: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return false
+	}
+	if wait > 0 {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		event.Outcome = models.LoginLocked
+		recordLoginEvent(event)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		RespondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many failed attempts, try again in %d seconds", retryAfter))
+		return false
+	}
+
+	if !auth.CheckPasswordHash(password, user.PasswordHash) {
+		log.Printf("[profiles.go:checkCurrentPassword] Wrong current password for user %d", user.ID)
+		event.Outcome = models.LoginFailure
+		recordLoginEvent(event)
+		RespondWithError(w, http.StatusForbidden, "Current password is incorrect")
+		return false
+	}
+	return true
+}
+
+// ChangeEmailHandler handles POST /api/users/me/email. The current password is
+// required; the new address must be verified again, and the old one is told about the change.
+func ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.EmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !checkCurrentPassword(w, r, user, req.CurrentPassword) {
+		return
+	}
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if _, err := netmail.ParseAddress(newEmail); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "invalid email format")
+		return
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		RespondWithError(w, http.StatusBadRequest, "This is already your email address")
+		return
+	}
+
+	err := repo.UpdateUserEmail(user.ID, newEmail)
+	if err == repo.ErrDuplicateEntry {
+		RespondWithError(w, http.StatusConflict, "Email already in use")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to change email")
+		return
+	}
+
+	oldEmail := user.Email
+	user.Email = newEmail
+	if err := sendVerificationEmail(user); err != nil {
+		log.Printf("[profiles.go:ChangeEmailHandler] Error sending verification mail to user %d: %v", user.ID, err)
+	}
+	sendMail(mail.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe email address of your account was changed to %s.\n\n"+
+			"If you did not do this, reset your password and contact the moderators.\n", user.Nickname, newEmail),
+	})
+	log.Printf("[profiles.go:ChangeEmailHandler] User %d changed their email address", user.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Email changed, check your inbox to verify the new address",
+		"email":   newEmail,
+	})
+}
+
+// ChangePasswordHandler handles POST /api/users/me/password. The current
+// password is required, and every other session of the user is signed out.
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	current, _ := auth.GetSessionFromContext(r.Context())
+
+	var req models.PasswordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !checkCurrentPassword(w, r, user, req.CurrentPassword) {
+		return
+	}
+	if err := auth.ValidatePassword(req.NewPassword); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if err := repo.UpdateUserPassword(user.ID, hashedPassword); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	// Whoever knew the old password must not stay signed in elsewhere
+	keep := 0
+	if current != nil {
+		keep = current.ID
+	}
+	revoked, err := auth.RevokeOtherSessions(user.ID, keep)
+	if err != nil {
+		log.Printf("[profiles.go:ChangePasswordHandler] Error revoking sessions of user %d: %v", user.ID, err)
+	} else if hub != nil {
+		hub.CloseSessions(revoked)
+	}
+	sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe password of your account was changed and your other sessions were signed out.\n\n"+
+			"If you did not do this, reset your password right away.\n", user.Nickname),
+	})
+	log.Printf("[profiles.go:ChangePasswordHandler] User %d changed their password, %d sessions revoked", user.ID, len(revoked))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":          "Password changed",
+		"revoked_sessions": len(revoked),
+	})
+}
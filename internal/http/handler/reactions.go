@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+// ReactionsHandler handles /api/reactions.
+//   - GET ?target_type=post&target_id=1 lists reactions and their counts (public)
+//   - POST {"target_type","target_id","reaction"} adds a reaction (authenticated)
+//   - DELETE ?target_type=post&target_id=1&reaction=up removes one (authenticated)
+//
+// POST and DELETE are wrapped by AuthMiddleware in routes.go.
+func ReactionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listReactions(w, r)
+	case http.MethodPost, http.MethodDelete:
+		changeReaction(w, r)
+	default:
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// listReactions responds with every reaction on a target plus the aggregated counts.
+// Deleted and hidden targets answer 404, as when reacting to them.
+func listReactions(w http.ResponseWriter, r *http.Request) {
+	targetType := r.URL.Query().Get("target_type")
+	targetID, err := strconv.Atoi(r.URL.Query().Get("target_id"))
+	if err != nil || !validTargetType(targetType) {
+		RespondWithError(w, http.StatusBadRequest, "Invalid target")
+		return
+	}
+	exists, err := repo.ReactionTargetExists(targetType, targetID)
+	if err != nil {
+		log.Printf("[reactions.go:listReactions] repo.ReactionTargetExists failed for %s %d: %v", targetType, targetID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve reactions")
+		return
+	}
+	if !exists {
+		RespondWithError(w, http.StatusNotFound, "Target not found")
+		return
+	}
+
+	reactions, err := repo.GetReactions(targetType, targetID)
+	if err != nil {
+		log.Printf("[reactions.go:listReactions] repo.GetReactions failed for %s %d: %v", targetType, targetID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve reactions")
+		return
+	}
+
+	counts := map[string]int{}
+	for _, reaction := range reactions {
+		counts[reaction.Reaction]++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reactions": reactions,
+		"counts":    counts,
+		"score":     counts[models.ReactionUp] - counts[models.ReactionDown],
+	})
+}
+
+// changeReaction adds (POST) or removes (DELETE) the current user's reaction
+// and responds with the target's updated counts.
+func changeReaction(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok || user == nil {
+		RespondWithError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req models.ReactionRequest
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	} else {
+		req.TargetType = r.URL.Query().Get("target_type")
+		req.TargetID, _ = strconv.Atoi(r.URL.Query().Get("target_id"))
+		req.Reaction = r.URL.Query().Get("reaction")
+	}
+
+	if !validTargetType(req.TargetType) || req.TargetID <= 0 {
+		RespondWithError(w, http.StatusBadRequest, "Invalid target")
+		return
+	}
+	if !models.ValidReactions[req.Reaction] {
+		RespondWithError(w, http.StatusBadRequest, "Unknown reaction")
+		return
+	}
+
+	var err error
+	if r.Method == http.MethodPost {
+		var exists bool
+		exists, err = repo.ReactionTargetExists(req.TargetType, req.TargetID)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to add reaction")
+			return
+		}
+		if !exists {
+			RespondWithError(w, http.StatusNotFound, "Target not found")
+			return
+		}
+		err = repo.AddReaction(user.ID, req.TargetType, req.TargetID, req.Reaction)
+	} else {
+		err = repo.RemoveReaction(user.ID, req.TargetType, req.TargetID, req.Reaction)
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to update reaction")
+		return
+	}
+
+	counts, err := repo.GetReactionCounts(req.TargetType, []int{req.TargetID})
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve reactions")
+		return
+	}
+	targetCounts := counts[req.TargetID]
+	if targetCounts == nil {
+		targetCounts = map[string]int{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"target_type": req.TargetType,
+		"target_id":   req.TargetID,
+		"counts":      targetCounts,
+		"score":       targetCounts[models.ReactionUp] - targetCounts[models.ReactionDown],
+	})
+}
+
+// validTargetType reports whether reactions can be attached to the given target type.
+func validTargetType(targetType string) bool {
+	return targetType == models.TargetPost || targetType == models.TargetComment
+}
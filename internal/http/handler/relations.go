@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+// BlocksHandler handles GET /api/blocks, the users the caller blocked, and
+// POST /api/blocks with {"user_id"} to block a user.
+func BlocksHandler(w http.ResponseWriter, r *http.Request) {
+	userRelationsHandler(w, r, models.RelationBlock)
+}
+
+// UnblockHandler handles DELETE /api/blocks/{user_id}.
+func UnblockHandler(w http.ResponseWriter, r *http.Request) {
+	removeUserRelationHandler(w, r, models.RelationBlock, "/api/blocks/")
+}
+
+// MutesHandler handles GET /api/mutes, the users the caller muted, and
+// POST /api/mutes with {"user_id"} to mute a user.
+func MutesHandler(w http.ResponseWriter, r *http.Request) {
+	userRelationsHandler(w, r, models.RelationMute)
+}
+
+// UnmuteHandler handles DELETE /api/mutes/{user_id}.
+func UnmuteHandler(w http.ResponseWriter, r *http.Request) {
+	removeUserRelationHandler(w, r, models.RelationMute, "/api/mutes/")
+}
+
+// userRelationsHandler lists or adds the blocks or mutes of the caller.
+func userRelationsHandler(w http.ResponseWriter, r *http.Request, kind string) {
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		relations, err := repo.GetUserRelations(kind, user.ID)
+		if err != nil {
+			log.Printf("[relations.go:userRelationsHandler] Error listing %s relations of user %d: %v", kind, user.ID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve users")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"users": relations,
+		})
+
+	case http.MethodPost:
+		var req models.UserRelationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+			RespondWithError(w, http.StatusBadRequest, "user_id is required")
+			return
+		}
+		if req.UserID == user.ID {
+			RespondWithError(w, http.StatusBadRequest, "You cannot "+kind+" yourself")
+			return
+		}
+		target, err := repo.GetUserByID(req.UserID)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user")
+			return
+		}
+		if target == nil {
+			RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+
+		if err := repo.AddUserRelation(kind, user.ID, target.ID); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to "+kind+" user")
+			return
+		}
+		if hub != nil {
+			hub.RelationsChanged(user.ID)
+		}
+		log.Printf("[relations.go:userRelationsHandler] User %d added a %s on user %d", user.ID, kind, target.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"user_id":  target.ID,
+			"nickname": target.Nickname,
+		})
+
+	default:
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// removeUserRelationHandler lifts a block or mute of the caller on the user in the path.
+func removeUserRelationHandler(w http.ResponseWriter, r *http.Request, kind, prefix string) {
+	if r.Method != http.MethodDelete {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	targetID, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSuffix(r.URL.Path, "/"), prefix))
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := repo.RemoveUserRelation(kind, user.ID, targetID); err != nil {
+		if err == repo.ErrNoRows {
+			RespondWithError(w, http.StatusNotFound, "No "+kind+" on this user")
+			return
+		}
+		RespondWithError(w, http.StatusInternalServerError, "Failed to remove "+kind)
+		return
+	}
+	if hub != nil {
+		hub.RelationsChanged(user.ID)
+	}
+	log.Printf("[relations.go:removeUserRelationHandler] User %d removed a %s on user %d", user.ID, kind, targetID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+// SearchHandler handles GET /api/search.
+// Query parameters: q (required), type (post|comment), category (ID), author (nickname),
+// from/to (YYYY-MM-DD or RFC3339), limit (max 50) and cursor (from a previous next_cursor).
+// A signed-in viewer does not see content by users they blocked.
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	params := models.SearchParams{
+		Query:  strings.TrimSpace(q.Get("q")),
+		Type:   q.Get("type"),
+		Author: strings.TrimSpace(q.Get("author")),
+		Cursor: q.Get("cursor"),
+		Limit:  20,
+	}
+	if viewer, ok := auth.GetUserFromContext(r.Context()); ok {
+		params.ViewerID = viewer.ID
+	}
+
+	if params.Query == "" {
+		RespondWithError(w, http.StatusBadRequest, "Missing q parameter")
+		return
+	}
+	if params.Type != "" && params.Type != "post" && params.Type != "comment" {
+		RespondWithError(w, http.StatusBadRequest, "type must be post or comment")
+		return
+	}
+	if categoryStr := q.Get("category"); categoryStr != "" {
+		categoryID, err := strconv.Atoi(categoryStr)
+		if err != nil || categoryID <= 0 {
+			RespondWithError(w, http.StatusBadRequest, "Invalid category parameter")
+			return
+		}
+		params.CategoryID = categoryID
+	}
+	if limitStr := q.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 50 {
+			params.Limit = l
+		}
+	}
+
+	var err error
+	if params.From, err = parseDateParam(q.Get("from"), false); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid from parameter")
+		return
+	}
+	if params.To, err = parseDateParam(q.Get("to"), true); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid to parameter")
+		return
+	}
+
+	results, nextCursor, err := repo.SearchContent(params)
+	if err != nil {
+		switch err {
+		case repo.ErrInvalidCursor:
+			RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		default:
+			log.Printf("[search.go:SearchHandler] repo.SearchContent failed for %q: %v", params.Query, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to search")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results":     results,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
+	})
+}
+
+// parseDateParam parses a YYYY-MM-DD or RFC3339 query value.
+// A bare date used as an upper bound covers the whole day.
+func parseDateParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/repo"
+)
+
+// SessionsHandler lists the user's active sessions (GET) or signs out every
+// session except the current one (DELETE)
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	current, ok := auth.GetSessionFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sessions, err := auth.GetUserSessions(user.ID)
+		if err != nil {
+			log.Printf("[sessions.go:SessionsHandler] Error listing sessions of user %d: %v", user.ID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve sessions")
+			return
+		}
+		for _, session := range sessions {
+			session.Current = session.ID == current.ID
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sessions": sessions,
+		})
+
+	case http.MethodDelete:
+		revoked, err := auth.RevokeOtherSessions(user.ID, current.ID)
+		if err != nil {
+			log.Printf("[sessions.go:SessionsHandler] Error revoking sessions of user %d: %v", user.ID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+			return
+		}
+		if hub != nil {
+			hub.CloseSessions(revoked)
+		}
+		log.Printf("[sessions.go:SessionsHandler] User %d revoked %d other sessions", user.ID, len(revoked))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"revoked": len(revoked),
+		})
+
+	default:
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// RevokeSessionHandler signs out one of the user's sessions (DELETE /api/sessions/{id}).
+// Revoking the current session also clears the cookie, like logging out.
+func RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	current, _ := auth.GetSessionFromContext(r.Context())
+
+	id, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSuffix(r.URL.Path, "/"), "/api/sessions/"))
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	if err := auth.RevokeSession(user.ID, id); err != nil {
+		if err == repo.ErrNoRows {
+			RespondWithError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		log.Printf("[sessions.go:RevokeSessionHandler] Error revoking session %d of user %d: %v", id, user.ID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+	if hub != nil {
+		hub.CloseSessions([]int{id})
+	}
+	if current != nil && current.ID == id {
+		auth.ClearSessionCookie(w)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Session revoked",
+	})
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+// GetSidebarHandler handles GET /api/sidebar, the chat sidebar of the caller:
+// their conversations, most recently active first, with the last message, the
+// unread count and the other user's online state, then every other user
+// alphabetically. Later changes arrive as sidebar_update events on the socket.
+func GetSidebarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	entries, err := repo.GetSidebar(user.ID)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to load the sidebar")
+		return
+	}
+
+	statuses := presenceStatuses()
+	for _, entry := range entries {
+		if entry.Kind == models.ConversationDirect {
+			entry.Status = visibleStatus(statuses, entry.UserID, entry.HidePresence)
+			entry.IsOnline = entry.Status != models.PresenceOffline
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+	})
+}
@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/models"
+	"real-time-forum/internal/repo"
+)
+
+// LoginTwoFactorHandler completes a login with the pending token from the
+// password step and a TOTP or recovery code. Wrong codes count as failed
+// logins of the identifier, and too many of them end the pending login.
+func LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.LoginTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	challenge, err := repo.GetLoginChallenge(auth.HashAccountToken(req.PendingToken))
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if challenge == nil {
+		RespondWithError(w, http.StatusUnauthorized, "Login expired, please sign in again")
+		return
+	}
+
+	event := &models.LoginEvent{
+		UserID:     challenge.UserID,
+		Identifier: challenge.Identifier,
+		IP:         auth.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+	}
+
+	// The lockout of the password step applies to codes as well
+	wait, err := auth.LoginRetryAfter(challenge.Identifier, event.IP)
+	if err != nil {
+		log.Printf("[two_factor.go:LoginTwoFactorHandler] ERROR checking failed logins: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if wait > 0 {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		event.Outcome = models.LoginLocked
+		recordLoginEvent(event)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		RespondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many failed attempts, try again in %d seconds", retryAfter))
+		return
+	}
+
+	ok, err := auth.CheckSecondFactor(challenge.UserID, req.Code)
+	if err != nil {
+		log.Printf("[two_factor.go:LoginTwoFactorHandler] ERROR checking code of user %d: %v", challenge.UserID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if !ok {
+		event.Outcome = models.LoginFailure
+		recordLoginEvent(event)
+		attempts, err := repo.CountLoginChallengeAttempt(challenge.ID)
+		if err == nil && attempts >= auth.MaxLoginChallengeAttempts {
+			repo.DeleteLoginChallenge(challenge.ID)
+			RespondWithError(w, http.StatusUnauthorized, "Too many wrong codes, please sign in again")
+			return
+		}
+		RespondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	// Deleting the challenge claims it, so one pending token yields one session
+	if err := repo.DeleteLoginChallenge(challenge.ID); err != nil {
+		RespondWithError(w, http.StatusUnauthorized, "Login expired, please sign in again")
+		return
+	}
+
+	user, err := repo.GetUserByID(challenge.UserID)
+	if err != nil || user == nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if refuseSuspendedLogin(w, user, event) {
+		return
+	}
+	completeLogin(w, user, event, challenge.Remember)
+}
+
+// TwoFactorHandler returns the two-factor setup of the current user (GET).
+func TwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	status := models.TwoFactorStatus{Enabled: user.TwoFactor, Required: auth.RequiresTwoFactor(user)}
+	if user.TwoFactor {
+		left, err := repo.CountRecoveryCodes(user.ID)
+		if err != nil {
+			log.Printf("[two_factor.go:TwoFactorHandler] Error counting recovery codes of user %d: %v", user.ID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		status.RecoveryCodesLeft = left
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// TwoFactorSetupHandler starts enrollment (POST): it creates a new secret and
+// returns it with its provisioning URI. Two-factor authentication is not on
+// until TwoFactorEnableHandler confirms a code from it.
+func TwoFactorSetupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	err = repo.SetPendingTOTPSecret(user.ID, secret)
+	if err == repo.ErrNoRows {
+		RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":           secret,
+		"provisioning_uri": auth.TOTPProvisioningURI(secret, user.Email),
+	})
+}
+
+// TwoFactorEnableHandler finishes enrollment with a code from the new secret
+// (POST) and returns the recovery codes. They are shown only this once.
+func TwoFactorEnableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	secret, enabled, err := repo.GetTOTPSecret(user.ID)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if enabled {
+		RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if secret == "" {
+		RespondWithError(w, http.StatusBadRequest, "Start the setup first")
+		return
+	}
+
+	step, ok := auth.MatchTOTP(secret, req.Code, time.Now())
+	if !ok {
+		RespondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := auth.NewRecoveryCodes()
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if err := repo.EnableTOTP(user.ID, step, hashes); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+	log.Printf("[two_factor.go:TwoFactorEnableHandler] User %d enabled two-factor authentication", user.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// TwoFactorDisableHandler turns two-factor authentication off (POST). It needs
+// the password and a current code, and is refused to roles that require it.
+func TwoFactorDisableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if !user.TwoFactor {
+		RespondWithError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+		return
+	}
+	if auth.RequiresTwoFactor(user) {
+		RespondWithError(w, http.StatusForbidden, "Your role requires two-factor authentication")
+		return
+	}
+
+	var req models.TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !auth.CheckPasswordHash(req.Password, user.PasswordHash) {
+		RespondWithError(w, http.StatusUnauthorized, "Invalid password")
+		return
+	}
+	ok, err := auth.CheckSecondFactor(user.ID, req.Code)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	if err := repo.DisableTOTP(user.ID); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+	log.Printf("[two_factor.go:TwoFactorDisableHandler] User %d disabled two-factor authentication", user.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// RecoveryCodesHandler replaces the recovery codes of the current user (POST)
+// after checking a current code, and returns the new ones.
+func RecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	user, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if !user.TwoFactor {
+		RespondWithError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+		return
+	}
+
+	var req models.TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	ok, err := auth.CheckSecondFactor(user.ID, req.Code)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := auth.NewRecoveryCodes()
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if err := repo.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to replace recovery codes")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
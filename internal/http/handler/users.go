@@ -2,50 +2,163 @@ package handler
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/models"
 	"real-time-forum/internal/repo"
 )
 
-// GetAllUsersHandler handles GET /api/users requests
+// GetAllUsersHandler handles GET /api/users, the user directory of the caller.
+// Users they exchanged direct messages with come first, most recent conversation
+// first, then everyone else alphabetically. Query parameters: q (nickname prefix),
+// limit (default 50, max 100) and cursor (the next_cursor of the previous page).
 func GetAllUsersHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
+	caller, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	opts := models.UserListOptions{
+		ViewerID: caller.ID,
+		Prefix:   strings.TrimSpace(r.URL.Query().Get("q")),
+		Cursor:   r.URL.Query().Get("cursor"),
+		Limit:    50,
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			opts.Limit = l
+		}
+	}
 
-	users, err := repo.GetAllUsers()
+	users, nextCursor, err := repo.GetUserDirectory(opts)
 	if err != nil {
+		if err == repo.ErrInvalidCursor {
+			RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
 		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve users")
 		return
 	}
 
-	// Get online users from hub
-	hub := GetHub()
-	onlineUsers := make(map[int]bool)
-	if hub != nil {
-		hub.Mu.RLock()
-		for userID := range hub.Users {
-			onlineUsers[userID] = true
+	// Presence comes from the hub, and stays hidden across a block
+	statuses := presenceStatuses()
+	for _, user := range users {
+		user.Status = visibleStatus(statuses, user.ID, user.HidePresence)
+		user.IsOnline = user.Status != models.PresenceOffline
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users":       users,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
+	})
+}
+
+// presenceStatuses returns the presence status of every user connected to the hub
+func presenceStatuses() map[int]string {
+	if hub == nil {
+		return map[int]string{}
+	}
+	return hub.PresenceStatuses()
+}
+
+// visibleStatus returns the status of a user from presenceStatuses, offline
+// when they are not connected or presence is hidden by a block
+func visibleStatus(statuses map[int]string, userID int, hidden bool) string {
+	status, ok := statuses[userID]
+	if !ok || hidden {
+		return models.PresenceOffline
+	}
+	return status
+}
+
+// UpdateUserRoleHandler handles PUT /api/users/{id}/role with {"role": "..."}.
+// Routes guard it with the user.role.assign permission. The last admin cannot
+// be demoted, so the forum always keeps someone who can assign roles.
+func UpdateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	caller, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	idStr := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/users/"), "/"), "/role")
+	userID, err := strconv.Atoi(idStr)
+	if err != nil || userID <= 0 {
+		RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !auth.ValidRole(req.Role) {
+		RespondWithError(w, http.StatusBadRequest, "Unknown role")
+		return
+	}
+
+	target, err := repo.GetUserByID(userID)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if target == nil {
+		RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if target.Role == models.RoleAdmin && req.Role != models.RoleAdmin {
+		admins, err := repo.CountUsersWithRole(models.RoleAdmin)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		if admins <= 1 {
+			RespondWithError(w, http.StatusConflict, "Cannot demote the last admin")
+			return
 		}
-		hub.Mu.RUnlock()
 	}
 
-	// Create response with online/offline status
-	type UserResponse struct {
-		ID       int    `json:"id"`
-		Nickname string `json:"nickname"`
-		IsOnline bool   `json:"is_online"`
+	if err := repo.SetUserRole(userID, req.Role); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to update role")
+		return
 	}
+	log.Printf("[users.go:UpdateUserRoleHandler] User %d changed the role of user %d from %s to %s", caller.ID, userID, target.Role, req.Role)
 
-	var response []UserResponse
-	for _, user := range users {
-		response = append(response, UserResponse{
-			ID:       user.ID,
-			Nickname: user.Nickname,
-			IsOnline: onlineUsers[user.ID],
-		})
+	// Tokens issued for the old role must not carry the new one: the user logs in again
+	if target.Role != req.Role {
+		revoked, err := auth.RevokeOtherSessions(userID, 0)
+		if err != nil {
+			log.Printf("[users.go:UpdateUserRoleHandler] Error revoking sessions of user %d: %v", userID, err)
+		} else if hub != nil {
+			hub.CloseSessions(revoked)
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":   userID,
+		"role": req.Role,
+		// Moderators and admins have no privileges until they enable two-factor authentication
+		"two_factor_setup_required": auth.RequiresTwoFactor(&models.User{Role: req.Role}) && !target.TwoFactor,
+	})
 }
@@ -3,9 +3,9 @@ package handler
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"real-time-forum/internal/auth"
-	"real-time-forum/internal/models"
 	"real-time-forum/internal/repo"
 	"real-time-forum/internal/ws"
 
@@ -28,9 +28,17 @@ func InitWebSocket() {
 	hub = ws.NewHub()
 
 	// Inject the message repository function to avoid circular imports
-	ws.SetMessageRepo(func(userID1, userID2, limit, offset int) ([]models.PrivateMessage, error) {
-		return repo.GetPrivateMessagesBetweenUsers(userID1, userID2, limit, offset)
-	})
+	ws.SetMessageRepo(repo.GetPrivateMessagesBetweenUsers)
+	ws.SetMessageStore(repo.CreatePrivateMessage)
+	ws.SetDeliveryRepo(repo.GetQueuedMessages, repo.MarkMessagesDelivered)
+	ws.SetReadReceiptRepo(repo.MarkMessagesAsRead)
+	ws.SetConversationRepo(repo.GetConversationMemberIDs)
+	ws.SetRelationRepo(repo.GetRelationIDs, repo.IsBlockedBetween)
+	ws.SetSidebarRepo(repo.GetSidebarEntry)
+	ws.SetPresenceRepo(repo.GetPresenceStatus, repo.SetUserOnline)
+
+	// Nobody is connected yet, whatever the previous run left behind
+	repo.ResetOnlineUsers()
 
 	go hub.Run()
 	log.Println("WebSocket hub initialized")
@@ -49,13 +57,27 @@ func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user from session
-	user, err := auth.GetUserBySessionToken(sessionToken.Value)
-	if err != nil || user == nil {
+	// Get session and user; the connection is tied to the session so revoking it closes the socket
+	session, err := auth.GetSessionByToken(sessionToken.Value)
+	if err != nil || session == nil || time.Now().After(session.Expiry) {
 		log.Printf("[websocket.go:WebSocketHandler] Invalid session token")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	user, err := repo.GetUserByID(session.UserID)
+	if err != nil || user == nil {
+		log.Printf("[websocket.go:WebSocketHandler] No user for session %d", session.ID)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// A session issued for another role is rejected as in AuthMiddleware
+	if session.Role != user.Role {
+		log.Printf("[websocket.go:WebSocketHandler] Rejected session %d of user %d issued for role %s, now %s", session.ID, user.ID, session.Role, user.Role)
+		_ = auth.DeleteSession(sessionToken.Value)
+		auth.ClearSessionCookie(w)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
 	userID := user.ID
 	nickname := user.Nickname
@@ -71,7 +93,7 @@ func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create new client and register with hub
-	client := ws.NewClient(hub, conn, userID, nickname)
+	client := ws.NewClient(hub, conn, userID, nickname, session.ID)
 	hub.Register <- client
 
 	// Start client goroutines
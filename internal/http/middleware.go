@@ -76,13 +76,83 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// 4. Add the user to the request context
+		// 4. Role changes revoke the user's sessions; a token issued for other
+		// privileges that survived is rejected, so a captured token never gains the new role
+		if session.Role != user.Role {
+			log.Printf("[middleware.go:AuthMiddleware] Rejected session %d of user %d issued for role %s, now %s", session.ID, user.ID, session.Role, user.Role)
+			_ = auth.DeleteSession(sessionToken)
+			auth.ClearSessionCookie(w)
+			handler.RespondWithError(w, http.StatusUnauthorized, "Your role changed, please log in again")
+			return
+		}
+		if err := auth.RenewSession(w, session); err != nil {
+			log.Printf("[middleware.go:AuthMiddleware] Failed to renew session %d: %v", session.ID, err)
+		}
+
+		// 5. Add the user and session to the request context
 		ctx := context.WithValue(r.Context(), models.UserContextKey, user)
+		ctx = context.WithValue(ctx, models.SessionContextKey, session)
 		r = r.WithContext(ctx)
 
 		log.Printf("[middleware.go:AuthMiddleware] AuthMiddleware: User %s (ID: %d) authenticated successfully. Proceeding to handler.", user.Nickname, user.ID)
 
-		// 5. Call the next handler in the chain
+		// 6. Call the next handler in the chain
 		next.ServeHTTP(w, r)
 	})
 }
+
+// OptionalAuthMiddleware adds the user to the request context like AuthMiddleware
+// when the session cookie is valid, and otherwise lets the request through
+// anonymously. Public pages use it to tailor their content to a signed-in viewer.
+func OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session_token")
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, err := auth.GetSessionByToken(cookie.Value)
+		if err != nil || session == nil || session.Expiry.Before(time.Now()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, err := repo.GetUserByID(session.UserID)
+		if err != nil || user == nil || session.Role != user.Role {
+			if err != nil {
+				log.Printf("[middleware.go:OptionalAuthMiddleware] Error retrieving user %d: %v", session.UserID, err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), models.UserContextKey, user)
+		ctx = context.WithValue(ctx, models.SessionContextKey, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequirePermission returns middleware that lets a request through only when
+// the authenticated user holds the permission. It must run inside
+// AuthMiddleware, e.g. AuthMiddleware(RequirePermission(models.PermCategoryManage)(h)).
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := auth.GetUserFromContext(r.Context())
+			if !ok {
+				handler.RespondWithError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+			if !auth.HasPermission(user, permission) {
+				log.Printf("[middleware.go:RequirePermission] User %d (%s) lacks permission %s for %s %s", user.ID, user.Role, permission, r.Method, r.URL.Path)
+				if auth.RequiresTwoFactor(user) && !user.TwoFactor {
+					handler.RespondWithError(w, http.StatusForbidden, "Enable two-factor authentication to use your privileges")
+					return
+				}
+				handler.RespondWithError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
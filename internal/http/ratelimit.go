@@ -0,0 +1,84 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/auth"
+	"real-time-forum/internal/http/handler"
+	"real-time-forum/internal/ratelimit"
+	"real-time-forum/internal/ws"
+)
+
+// Rate limit state shared by every HTTP request
+var (
+	rateLimits  = ratelimit.DefaultConfig()
+	httpLimiter = ratelimit.NewLimiter()
+)
+
+// InitRateLimits installs the HTTP and WebSocket limits
+func InitRateLimits(cfg ratelimit.Config) {
+	rateLimits = cfg
+	ws.SetRateLimits(cfg)
+}
+
+// RateLimitMiddleware applies the token bucket of the request's route group.
+// Signed-in callers are limited per user ID, anonymous callers per IP.
+// Limited requests get 429 Too Many Requests with a Retry-After header.
+func RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := routeGroup(r)
+		policy, ok := rateLimits.HTTP[group]
+		if group == "" || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := group + ":" + rateLimitKey(r)
+		allowed, wait := httpLimiter.Allow(key, policy)
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			log.Printf("[ratelimit.go:RateLimitMiddleware] Rate limited %s %s for %s (retry in %ds)", r.Method, r.URL.Path, key, retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			handler.RespondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many requests, retry in %d seconds", retryAfter))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeGroup returns the rate limit group of a request, or "" when it is not limited
+func routeGroup(r *http.Request) string {
+	path := r.URL.Path
+	switch {
+	case path == "/login" || path == "/login/2fa" || path == "/register" || path == "/logout",
+		path == "/verify-email" || strings.HasPrefix(path, "/api/auth/password-reset"):
+		return ratelimit.GroupAuth
+	case path == "/ws":
+		return ratelimit.GroupWS
+	case strings.HasPrefix(path, "/api/"):
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return ratelimit.GroupRead
+		}
+		return ratelimit.GroupWrite
+	}
+	return ""
+}
+
+// rateLimitKey identifies the caller: "user:<id>" when the session cookie is
+// valid, otherwise "ip:<address>". Proxy headers are not trusted.
+func rateLimitKey(r *http.Request) string {
+	if cookie, err := r.Cookie("session_token"); err == nil {
+		session, err := auth.GetSessionByToken(cookie.Value)
+		if err == nil && session != nil && session.Expiry.After(time.Now()) {
+			return "user:" + strconv.Itoa(session.UserID)
+		}
+	}
+	return "ip:" + auth.ClientIP(r)
+}
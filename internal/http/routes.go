@@ -9,6 +9,8 @@ import (
 
 	"real-time-forum/internal/auth"
 	"real-time-forum/internal/http/handler"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/models"
 )
 
 // debugLog is a helper function to add file and function name to debug logs
@@ -18,6 +20,11 @@ func InitWebSocket() {
 	handler.InitWebSocket()
 }
 
+// InitMailer sets the mailer used for verification and password reset mails
+func InitMailer(m mail.Mailer, baseURL string) {
+	handler.InitMailer(m, baseURL)
+}
+
 // RegisterRoutes sets up all the application's routes.
 // It uses a ServeMux for better modularity and to avoid using the default global multiplexer.
 func RegisterRoutes(mux *http.ServeMux) {
@@ -29,6 +36,7 @@ func RegisterRoutes(mux *http.ServeMux) {
 	// API and page routes
 	mux.HandleFunc("/register", handler.RegisterHandler)
 	mux.HandleFunc("/login", handler.LoginHandler)
+	mux.HandleFunc("/login/2fa", handler.LoginTwoFactorHandler)
 	mux.HandleFunc("/logout", handler.LogoutHandler)
 
 	// Add a new route to check authentication status
@@ -42,11 +50,55 @@ func RegisterRoutes(mux *http.ServeMux) {
 				handler.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve user from context")
 				return
 			}
-			json.NewEncoder(w).Encode(map[string]interface{}{"isAuthenticated": true, "user": user})
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"isAuthenticated": true,
+				"user":            user,
+				"permissions":     auth.Permissions(user),
+			})
 		})
 		AuthMiddleware(statusHandler).ServeHTTP(w, r)
 	})
 
+	// Email verification: the link in the mail, and a new link on request
+	mux.HandleFunc("/verify-email", handler.VerifyEmailHandler)
+	mux.HandleFunc("/api/auth/verify-email/resend", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.ResendVerificationHandler)).ServeHTTP(w, r)
+	})
+
+	// Password reset: mail a link, then set a new password with its token
+	mux.HandleFunc("/api/auth/password-reset", handler.PasswordResetRequestHandler)
+	mux.HandleFunc("/api/auth/password-reset/confirm", handler.PasswordResetHandler)
+
+	// Two-factor authentication: status, enrollment, disabling and new recovery codes
+	mux.HandleFunc("/api/auth/2fa", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.TwoFactorHandler)).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/auth/2fa/setup", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.TwoFactorSetupHandler)).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/auth/2fa/enable", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.TwoFactorEnableHandler)).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/auth/2fa/disable", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.TwoFactorDisableHandler)).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/auth/2fa/recovery-codes", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.RecoveryCodesHandler)).ServeHTTP(w, r)
+	})
+
+	// Recent sign-in attempts on the current user's account
+	mux.HandleFunc("/api/auth/logins", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.GetLoginEventsHandler)).ServeHTTP(w, r)
+	})
+
+	// Active sessions of the current user: list, sign out others, or sign out one
+	mux.HandleFunc("/api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.SessionsHandler)).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.RevokeSessionHandler)).ServeHTTP(w, r)
+	})
+
 	// Handle all /api/posts/... routes
 	mux.HandleFunc("/api/posts/", func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[routes.go:RegisterRoutes] Router: Handling path: %s", r.URL.Path)
@@ -60,7 +112,7 @@ func RegisterRoutes(mux *http.ServeMux) {
 			log.Printf("[routes.go:RegisterRoutes] Path is for comments. Routing by method: %s", r.Method)
 			switch r.Method {
 			case http.MethodGet:
-				handler.GetCommentsByPostIDHandler(w, r)
+				OptionalAuthMiddleware(http.HandlerFunc(handler.GetCommentsByPostIDHandler)).ServeHTTP(w, r)
 			case http.MethodPost:
 				AuthMiddleware(http.HandlerFunc(handler.CreateCommentHandler)).ServeHTTP(w, r)
 			default:
@@ -69,26 +121,146 @@ func RegisterRoutes(mux *http.ServeMux) {
 			return
 		}
 
+		// Revision history of a post, e.g. /api/posts/123/revisions
+		if strings.HasSuffix(path, "/revisions") {
+			handler.GetPostRevisionsHandler(w, r)
+			return
+		}
+
 		// If path is empty, the original path was /api/posts/ or /api/posts.
 		// This is for listing all posts (GET) or creating a new one (POST).
 		if path == "" {
 			log.Printf("[routes.go:RegisterRoutes] Router: Path is for list/create. Routing by method: %s", r.Method)
 			switch r.Method {
 			case http.MethodGet:
-				handler.GetAllPostsHandler(w, r)
+				OptionalAuthMiddleware(http.HandlerFunc(handler.GetAllPostsHandler)).ServeHTTP(w, r)
 			case http.MethodPost:
 				AuthMiddleware(http.HandlerFunc(handler.CreatePostHandler)).ServeHTTP(w, r)
 			}
-		} else if r.Method == http.MethodGet { // If there's an ID and method is GET, fetch the specific post.
+		} else {
 			log.Printf("[routes.go:RegisterRoutes] Router: Path is for a specific resource (ID: %s).", path)
-			handler.GetPostByIDHandler(w, r)
+			switch r.Method {
+			case http.MethodGet:
+				handler.GetPostByIDHandler(w, r)
+			case http.MethodPut, http.MethodPatch:
+				AuthMiddleware(http.HandlerFunc(handler.UpdatePostHandler)).ServeHTTP(w, r)
+			case http.MethodDelete:
+				AuthMiddleware(http.HandlerFunc(handler.DeletePostHandler)).ServeHTTP(w, r)
+			default:
+				handler.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed for posts")
+			}
+		}
+	})
+
+	// Categories: anyone can list them, managers can add them
+	mux.HandleFunc("/api/categories", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			handler.GetAllCategoriesHandler(w, r)
+			return
+		}
+		AuthMiddleware(RequirePermission(models.PermCategoryManage)(http.HandlerFunc(handler.CreateCategoryHandler))).ServeHTTP(w, r)
+	})
+
+	// A single category, e.g. /api/categories/tech, its feed at /api/categories/tech/posts,
+	// and the display order at /api/categories/order; managers can change them
+	mux.HandleFunc("/api/categories/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/categories/"), "/")
+		switch {
+		case path == "":
+			handler.RespondWithError(w, http.StatusNotFound, "API endpoint not found")
+		case strings.HasSuffix(path, "/posts"):
+			OptionalAuthMiddleware(http.HandlerFunc(handler.GetCategoryPostsHandler)).ServeHTTP(w, r)
+		case path == "order":
+			AuthMiddleware(RequirePermission(models.PermCategoryManage)(http.HandlerFunc(handler.ReorderCategoriesHandler))).ServeHTTP(w, r)
+		case r.Method == http.MethodGet:
+			handler.GetCategoryHandler(w, r)
+		case r.Method == http.MethodPatch:
+			AuthMiddleware(RequirePermission(models.PermCategoryManage)(http.HandlerFunc(handler.UpdateCategoryHandler))).ServeHTTP(w, r)
+		case r.Method == http.MethodDelete:
+			AuthMiddleware(RequirePermission(models.PermCategoryManage)(http.HandlerFunc(handler.DeleteCategoryHandler))).ServeHTTP(w, r)
+		default:
+			handler.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed for categories")
 		}
 	})
 
-	mux.HandleFunc("/api/categories", handler.GetAllCategoriesHandler)
+	// Blocks and mutes of the signed-in user
+	mux.HandleFunc("/api/blocks", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.BlocksHandler)).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/blocks/", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.UnblockHandler)).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/mutes", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.MutesHandler)).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/mutes/", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.UnmuteHandler)).ServeHTTP(w, r)
+	})
 
-	// Users API route - temporarily no auth for testing
-	mux.HandleFunc("/api/users", handler.GetAllUsersHandler)
+	// Reports of posts, comments and private messages by any signed-in user
+	mux.HandleFunc("/api/reports", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.CreateReportHandler)).ServeHTTP(w, r)
+	})
+
+	// Moderation: the report queue, one report, actions and the moderation log
+	mux.HandleFunc("/api/moderation/reports", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(RequirePermission(models.PermModerate)(http.HandlerFunc(handler.GetReportsHandler))).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/moderation/reports/", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(RequirePermission(models.PermModerate)(http.HandlerFunc(handler.ReportHandler))).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/moderation/actions", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(RequirePermission(models.PermModerate)(http.HandlerFunc(handler.ModerationActionHandler))).ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/api/moderation/log", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(RequirePermission(models.PermModerate)(http.HandlerFunc(handler.GetModerationLogHandler))).ServeHTTP(w, r)
+	})
+
+	// Comment deletion by its author or a moderator, e.g. DELETE /api/comments/7
+	mux.HandleFunc("/api/comments/", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.DeleteCommentHandler)).ServeHTTP(w, r)
+	})
+
+	// Reactions and votes on posts and comments; reading is public, changing needs a session
+	mux.HandleFunc("/api/reactions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			handler.ReactionsHandler(w, r)
+			return
+		}
+		AuthMiddleware(http.HandlerFunc(handler.ReactionsHandler)).ServeHTTP(w, r)
+	})
+
+	// Full-text search over posts and comments
+	mux.Handle("/api/search", OptionalAuthMiddleware(http.HandlerFunc(handler.SearchHandler)))
+
+	// User directory of the signed-in user
+	mux.HandleFunc("/api/users", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.GetAllUsersHandler)).ServeHTTP(w, r)
+	})
+
+	// Profiles, e.g. /api/users/12 and /api/users/12/posts; the caller's own
+	// profile, email, password and presence status under /api/users/me; role assignment at PUT /api/users/12/role
+	mux.HandleFunc("/api/users/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/users/"), "/")
+		switch {
+		case path == "me":
+			AuthMiddleware(http.HandlerFunc(handler.OwnProfileHandler)).ServeHTTP(w, r)
+		case path == "me/email":
+			AuthMiddleware(http.HandlerFunc(handler.ChangeEmailHandler)).ServeHTTP(w, r)
+		case path == "me/password":
+			AuthMiddleware(http.HandlerFunc(handler.ChangePasswordHandler)).ServeHTTP(w, r)
+		case path == "me/status":
+			AuthMiddleware(http.HandlerFunc(handler.PresenceStatusHandler)).ServeHTTP(w, r)
+		case strings.HasSuffix(path, "/role"):
+			AuthMiddleware(RequirePermission(models.PermUserAssignRole)(http.HandlerFunc(handler.UpdateUserRoleHandler))).ServeHTTP(w, r)
+		case strings.HasSuffix(path, "/posts"):
+			OptionalAuthMiddleware(http.HandlerFunc(handler.GetUserPostsHandler)).ServeHTTP(w, r)
+		case path != "" && !strings.Contains(path, "/"):
+			OptionalAuthMiddleware(http.HandlerFunc(handler.GetProfileHandler)).ServeHTTP(w, r)
+		default:
+			handler.RespondWithError(w, http.StatusNotFound, "API endpoint not found")
+		}
+	})
 
 	// Private messaging routes
 	mux.HandleFunc("/api/messages/send", func(w http.ResponseWriter, r *http.Request) {
@@ -97,8 +269,39 @@ func RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/messages", func(w http.ResponseWriter, r *http.Request) {
 		AuthMiddleware(http.HandlerFunc(handler.GetPrivateMessagesHandler)).ServeHTTP(w, r)
 	})
+	// The chat sidebar; changes are pushed as sidebar_update events afterwards
+	mux.HandleFunc("/api/sidebar", func(w http.ResponseWriter, r *http.Request) {
+		AuthMiddleware(http.HandlerFunc(handler.GetSidebarHandler)).ServeHTTP(w, r)
+	})
 	mux.HandleFunc("/api/conversations", func(w http.ResponseWriter, r *http.Request) {
-		AuthMiddleware(http.HandlerFunc(handler.GetConversationsHandler)).ServeHTTP(w, r)
+		switch r.Method {
+		case http.MethodGet:
+			AuthMiddleware(http.HandlerFunc(handler.GetConversationsHandler)).ServeHTTP(w, r)
+		case http.MethodPost:
+			AuthMiddleware(http.HandlerFunc(handler.CreateConversationHandler)).ServeHTTP(w, r)
+		default:
+			handler.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed for conversations")
+		}
+	})
+
+	// Handle all /api/conversations/{id}/... routes
+	mux.HandleFunc("/api/conversations/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+
+		var h http.HandlerFunc
+		switch {
+		case strings.HasSuffix(path, "/members"):
+			h = handler.InviteConversationMembersHandler
+		case strings.HasSuffix(path, "/leave"):
+			h = handler.LeaveConversationHandler
+		case strings.HasSuffix(path, "/messages"):
+			h = handler.GetConversationMessagesHandler
+		case strings.HasSuffix(path, "/read"):
+			h = handler.MarkConversationReadHandler
+		default:
+			h = handler.ConversationHandler
+		}
+		AuthMiddleware(h).ServeHTTP(w, r)
 	})
 	mux.HandleFunc("/api/messages/unread", func(w http.ResponseWriter, r *http.Request) {
 		AuthMiddleware(http.HandlerFunc(handler.GetUnreadCountHandler)).ServeHTTP(w, r)
@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer is for local development and tests: instead of sending mail it
+// writes each message to a .eml file in Dir, or to the log when Dir is empty.
+type FileMailer struct {
+	Dir  string
+	From string
+
+	seq atomic.Int64
+}
+
+// Send writes one message.
+func (m *FileMailer) Send(msg Message) error {
+	data := format(m.From, msg)
+	if m.Dir == "" {
+		log.Printf("[file.go:Send] Mail not sent (no MAIL_SMTP_ADDR):\n%s", strings.ReplaceAll(string(data), "\r\n", "\n"))
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", time.Now().Format("20060102-150405"), m.seq.Add(1))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	log.Printf("[file.go:Send] Wrote mail to %s for %s", path, msg.To)
+	return nil
+}
@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"os"
+	"strings"
+)
+
+// Environment variables selecting and configuring the mailer.
+const (
+	SMTPAddrEnv     = "MAIL_SMTP_ADDR"     // host:port of the SMTP server; enables SMTP delivery
+	SMTPUserEnv     = "MAIL_SMTP_USER"     // Optional PLAIN auth user name
+	SMTPPasswordEnv = "MAIL_SMTP_PASSWORD" // Optional PLAIN auth password
+	FromAddrEnv     = "MAIL_FROM"          // Sender address
+	DirEnv          = "MAIL_DIR"           // Without SMTP: directory mails are written to; empty logs them
+)
+
+// DefaultFrom is the sender address used when MAIL_FROM is not set.
+const DefaultFrom = "Real-Time Forum <no-reply@localhost>"
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// FromEnvironment returns the mailer configured by the environment: SMTP when
+// MAIL_SMTP_ADDR is set, otherwise a FileMailer writing to MAIL_DIR (or the log).
+func FromEnvironment() Mailer {
+	from := strings.TrimSpace(os.Getenv(FromAddrEnv))
+	if from == "" {
+		from = DefaultFrom
+	}
+
+	if addr := strings.TrimSpace(os.Getenv(SMTPAddrEnv)); addr != "" {
+		return &SMTPMailer{
+			Addr:     addr,
+			Username: os.Getenv(SMTPUserEnv),
+			Password: os.Getenv(SMTPPasswordEnv),
+			From:     from,
+		}
+	}
+	return &FileMailer{Dir: os.Getenv(DirEnv), From: from}
+}
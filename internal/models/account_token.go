@@ -0,0 +1,7 @@
+package models
+
+// Purposes of the single-use tokens mailed to users.
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
@@ -3,16 +3,28 @@ package models
 import "time"
 
 // Comment represents a comment on a post.
+// Replies point at their parent comment; top-level comments have no ParentID.
 type Comment struct {
-	ID        int       `json:"id"`
-	PostID    int       `json:"postId"`
-	UserID    int       `json:"userId"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"createdAt"`
-	Author    *User     `json:"author"` // To hold author's details like nickname
+	ID         int            `json:"id"`
+	PostID     int            `json:"postId"`
+	UserID     int            `json:"userId"`
+	ParentID   *int           `json:"parentId,omitempty"`
+	Content    string         `json:"content"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	Author     *User          `json:"author"`            // To hold author's details like nickname
+	Depth      int            `json:"depth"`             // 0 for top-level comments
+	Path       string         `json:"path"`              // Zero-padded IDs from the root comment, e.g. "0000000003/0000000007"
+	ReplyCount int            `json:"replyCount"`        // Number of direct replies, including ones cut off by a depth limit
+	Replies    []*Comment     `json:"replies,omitempty"` // Only filled in tree responses
+	Reactions  map[string]int `json:"reactions"`         // Reaction name -> count
+	Score      int            `json:"score"`             // Upvotes minus downvotes
+	Deleted    bool           `json:"deleted,omitempty"` // Deleted comments keep their place in the thread but lose their content
+	Hidden     bool           `json:"hidden,omitempty"`  // Hidden by a moderator; the content is cleared like for deleted comments
+	Blocked    bool           `json:"blocked,omitempty"` // Written by a user the viewer blocked; the content is cleared as well
 }
 
 // CreateCommentRequest defines the expected structure for a new comment request from the client.
 type CreateCommentRequest struct {
-	Content string `json:"content"`
+	Content  string `json:"content"`
+	ParentID *int   `json:"parent_id"` // Set to reply to an existing comment on the same post
 }
@@ -6,4 +6,6 @@ type ContextKey string
 const (
 	// UserContextKey is the key used to store the authenticated user in the request context.
 	UserContextKey ContextKey = "authenticatedUser"
+	// SessionContextKey is the key used to store the session of the authenticated request.
+	SessionContextKey ContextKey = "authenticatedSession"
 )
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Conversation kinds. Direct conversations are created on the first message
+// between two users; group conversations are created explicitly.
+const (
+	ConversationDirect = "direct"
+	ConversationGroup  = "group"
+)
+
+// Roles of a conversation member. The owner is the group's creator, or the
+// longest-standing member once the creator has left.
+const (
+	MemberOwner  = "owner"
+	MemberMember = "member"
+)
+
+// Conversation is a message thread between two or more users.
+// The snake_case JSON keys extend the original /api/conversations payload;
+// for direct conversations UserID and Nickname describe the other user.
+type Conversation struct {
+	ID              int                   `json:"id"`
+	Kind            string                `json:"kind"`
+	Name            string                `json:"name"`
+	CreatedBy       int                   `json:"created_by,omitempty"`
+	CreatedAt       time.Time             `json:"created_at"`
+	UserID          int                   `json:"user_id,omitempty"`  // Direct only: the other member
+	Nickname        string                `json:"nickname,omitempty"` // Direct only: the other member's nickname
+	LastMessage     string                `json:"last_message"`
+	LastMessageTime *time.Time            `json:"last_message_time,omitempty"`
+	UnreadCount     int                   `json:"unread_count"`
+	Blocked         bool                  `json:"blocked,omitempty"` // Direct only: the user blocked the other member
+	Muted           bool                  `json:"muted,omitempty"`   // Direct only: the user muted the other member
+	Members         []*ConversationMember `json:"members,omitempty"` // Only filled for single conversation lookups
+}
+
+// ConversationMember is a user taking part in a conversation.
+type ConversationMember struct {
+	UserID            int       `json:"user_id"`
+	Nickname          string    `json:"nickname"`
+	Role              string    `json:"role"`
+	JoinedAt          time.Time `json:"joined_at"`
+	LastReadMessageID int       `json:"last_read_message_id"`
+}
+
+// CreateConversationRequest creates a group conversation. The creator is added automatically.
+type CreateConversationRequest struct {
+	Name      string `json:"name"`
+	MemberIDs []int  `json:"member_ids"`
+}
+
+// RenameConversationRequest renames a group conversation.
+type RenameConversationRequest struct {
+	Name string `json:"name"`
+}
+
+// InviteMembersRequest adds users to a group conversation.
+type InviteMembersRequest struct {
+	UserIDs []int `json:"user_ids"`
+}
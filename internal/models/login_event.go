@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Outcomes of a sign-in attempt.
+const (
+	LoginSuccess   = "success"
+	LoginFailure   = "failure"   // Unknown identifier or wrong password
+	LoginLocked    = "locked"    // Rejected without checking the password because of too many failures
+	LoginSuspended = "suspended" // Correct password, but the account is suspended
+)
+
+// LoginEvent is one sign-in attempt.
+type LoginEvent struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"-"`
+	Identifier string    `json:"-"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Outcome    string    `json:"outcome"`
+	CreatedAt  time.Time `json:"created_at"`
+}
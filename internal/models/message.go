@@ -2,12 +2,34 @@ package models
 
 import "time"
 
-// PrivateMessage represents a direct message between two users.
+// PrivateMessage represents a message in a conversation. Direct messages have a
+// ReceiverID; group messages only have a ConversationID (ReceiverID is 0).
 type PrivateMessage struct {
-	ID         int       `json:"id"`
-	SenderID   int       `json:"senderId"`
-	ReceiverID int       `json:"receiverId"`
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"createdAt"`
-	IsRead     bool      `json:"isRead"`
+	ID             int       `json:"id"`
+	ConversationID int       `json:"conversationId"`
+	SenderID       int       `json:"senderId"`
+	ReceiverID     int       `json:"receiverId"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"createdAt"`
+	IsRead         bool      `json:"isRead"`
+	Hidden         bool      `json:"hidden,omitempty"` // Hidden by a moderator; Content is empty
+	// SenderNickname is only filled by queries that join the sender, such as the offline queue
+	SenderNickname string `json:"senderNickname,omitempty"`
+}
+
+// Delivery states tracked per private message in message_deliveries.
+const (
+	DeliveryQueued    = "queued"    // Stored, not yet pushed to any recipient connection
+	DeliveryDelivered = "delivered" // Pushed to at least one recipient connection
+	DeliveryRead      = "read"      // Recipient opened the conversation
+)
+
+// MessageDelivery tracks the delivery state of a private message for its recipient.
+type MessageDelivery struct {
+	MessageID   int        `json:"messageId"`
+	RecipientID int        `json:"recipientId"`
+	Status      string     `json:"status"`
+	QueuedAt    time.Time  `json:"queuedAt"`
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty"`
+	ReadAt      *time.Time `json:"readAt,omitempty"`
 }
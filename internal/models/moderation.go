@@ -0,0 +1,107 @@
+package models
+
+import "time"
+
+// Report states. Open reports wait in the moderation queue; a moderator either
+// takes action on them or dismisses them, and can reopen either.
+const (
+	ReportOpen      = "open"
+	ReportActioned  = "actioned"
+	ReportDismissed = "dismissed"
+)
+
+// Targets of reports and moderation actions, besides TargetPost and TargetComment.
+const (
+	TargetMessage = "message" // A private message
+	TargetUser    = "user"    // Warnings and suspensions
+	TargetReport  = "report"  // Report status changes
+)
+
+// Moderation actions recorded in the moderation log.
+const (
+	ActionHide          = "hide"           // Hide a post, comment or message from everyone
+	ActionUnhide        = "unhide"         // Show hidden content again
+	ActionWarn          = "warn"           // Send the user a warning
+	ActionSuspend       = "suspend"        // Sign the user out and refuse logins until ExpiresAt
+	ActionUnsuspend     = "unsuspend"      // Lift a suspension early
+	ActionResolveReport = "resolve_report" // A report was marked actioned by hand
+	ActionDismissReport = "dismiss_report" // A report was dismissed
+	ActionReopenReport  = "reopen_report"  // A closed report was put back in the queue
+)
+
+// Report is a user's complaint about a post, comment or private message.
+type Report struct {
+	ID               int        `json:"id"`
+	ReporterID       int        `json:"reporter_id"`
+	ReporterNickname string     `json:"reporter_nickname"`
+	TargetType       string     `json:"target_type"`
+	TargetID         int        `json:"target_id"`
+	TargetUserID     int        `json:"target_user_id"`
+	TargetNickname   string     `json:"target_nickname"`
+	Content          string     `json:"content"` // The target as it was when reported
+	Reason           string     `json:"reason"`
+	Status           string     `json:"status"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ResolvedBy       *int       `json:"resolved_by,omitempty"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
+	ResolutionNote   string     `json:"resolution_note,omitempty"`
+}
+
+// ReportRequest is the body of POST /api/reports.
+type ReportRequest struct {
+	TargetType string `json:"target_type"`
+	TargetID   int    `json:"target_id"`
+	Reason     string `json:"reason"`
+}
+
+// ReportStatusRequest is the body of PATCH /api/moderation/reports/{id}.
+type ReportStatusRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+// ReportListOptions filters and pages the moderation queue. Empty fields match everything.
+type ReportListOptions struct {
+	Status       string
+	TargetType   string
+	TargetUserID int
+	Cursor       string
+	Limit        int
+}
+
+// ModerationLogEntry is one immutable line of the moderation log.
+type ModerationLogEntry struct {
+	ID                int        `json:"id"`
+	ModeratorID       int        `json:"moderator_id"`
+	ModeratorNickname string     `json:"moderator_nickname"`
+	Action            string     `json:"action"`
+	TargetType        string     `json:"target_type"`
+	TargetID          int        `json:"target_id"`
+	TargetUserID      int        `json:"target_user_id,omitempty"`
+	ReportID          int        `json:"report_id,omitempty"`
+	Reason            string     `json:"reason"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"` // End of a suspension
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// ModerationLogOptions filters and pages the moderation log.
+type ModerationLogOptions struct {
+	Action       string
+	TargetUserID int
+	ModeratorID  int
+	Cursor       string
+	Limit        int
+}
+
+// ModerationActionRequest is the body of POST /api/moderation/actions.
+// The target can be given directly or through ReportID: hide and unhide act on
+// the reported content, warn and suspend on its author.
+type ModerationActionRequest struct {
+	Action        string `json:"action"`
+	TargetType    string `json:"target_type"` // post, comment or message, for hide and unhide
+	TargetID      int    `json:"target_id"`
+	UserID        int    `json:"user_id"` // For warn, suspend and unsuspend
+	ReportID      int    `json:"report_id"`
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"duration_hours"` // Length of a suspension
+}
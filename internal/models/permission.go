@@ -0,0 +1,12 @@
+package models
+
+// Permissions checked by handlers and RequirePermission. Users may always edit
+// and delete their own content; these grant the same on everyone's.
+const (
+	PermPostEditAny      = "post.edit.any"
+	PermPostDeleteAny    = "post.delete.any"
+	PermCommentDeleteAny = "comment.delete.any"
+	PermCategoryManage   = "category.manage"
+	PermUserAssignRole   = "user.role.assign"
+	PermModerate         = "moderation.act" // Review reports, hide content, warn and suspend users
+)
@@ -6,19 +6,40 @@ import (
 
 // Post represents a forum post.
 type Post struct {
-	ID         int       `json:"id"`
-	UserID     int       `json:"userId"`
-	Title      string    `json:"title"`
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"createdAt"`
-	Author     *User     `json:"author"`     // To hold author's details like nickname
-	Categories []string  `json:"categories"` // To hold the names of the categories
+	ID         int            `json:"id"`
+	UserID     int            `json:"userId"`
+	Title      string         `json:"title"`
+	Content    string         `json:"content"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	Author     *User          `json:"author"`              // To hold author's details like nickname
+	Categories []string       `json:"categories"`          // To hold the names of the categories
+	UpdatedAt  *time.Time     `json:"updatedAt,omitempty"` // Set when the post has been edited
+	Deleted    bool           `json:"deleted,omitempty"`   // Soft-deleted posts keep their comments but lose their content
+	Hidden     bool           `json:"hidden,omitempty"`    // Hidden by a moderator; the content is cleared like for deleted posts
+	Reactions  map[string]int `json:"reactions"`           // Reaction name -> count
+	Score      int            `json:"score"`               // Upvotes minus downvotes
 }
 
-// Category represents a post category.
+// Category represents a post category. PostCount and LastActivityAt count
+// visible posts and their comments, and are only filled in lists.
 type Category struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID             int        `json:"id"`
+	Slug           string     `json:"slug"`
+	Name           string     `json:"name"`
+	Description    string     `json:"description"`
+	Position       int        `json:"position"`
+	Archived       bool       `json:"archived"`
+	PostCount      int        `json:"post_count"`
+	LastActivityAt *time.Time `json:"last_activity_at"`
+}
+
+// CategoryRequest is the body of category create and update requests. For
+// updates, nil fields are left unchanged; Archived archives or restores.
+type CategoryRequest struct {
+	Name        *string `json:"name"`
+	Slug        *string `json:"slug"`
+	Description *string `json:"description"`
+	Archived    *bool   `json:"archived"`
 }
 
 // PostCategory is the junction table for the many-to-many relationship
@@ -34,3 +55,37 @@ type CreatePostRequest struct {
 	Content     string `json:"content"`
 	CategoryIDs []int  `json:"category_ids"`
 }
+
+// UpdatePostRequest defines the body of PUT and PATCH /api/posts/{id}.
+// For PATCH, nil fields are left unchanged; PUT requires title and content.
+type UpdatePostRequest struct {
+	Title       *string `json:"title"`
+	Content     *string `json:"content"`
+	CategoryIDs *[]int  `json:"category_ids"`
+}
+
+// PostRevision is one stored version of a post's title and content.
+type PostRevision struct {
+	Revision  int       `json:"revision"`
+	PostID    int       `json:"postId"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+	Editor    *User     `json:"editor"`
+}
+
+// PostListOptions controls which page of the feed is returned.
+type PostListOptions struct {
+	Cursor     string // Opaque cursor from a previous page, "" for the first page
+	Limit      int
+	Sort       string // PostSortNew (default) or PostSortTop
+	CategoryID int    // Only posts in this category when set
+	AuthorID   int    // Only posts by this user when set
+	ViewerID   int    // Leaves out posts by users the viewer blocked when set
+}
+
+// Feed sort modes.
+const (
+	PostSortNew = "new" // Newest first
+	PostSortTop = "top" // Highest score, decayed by age
+)
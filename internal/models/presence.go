@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Presence states. Users pick online, away or do-not-disturb for themselves;
+// online users whose connections report no activity for a while show as idle,
+// and users without a connection are offline.
+const (
+	PresenceOnline  = "online"
+	PresenceIdle    = "idle"
+	PresenceAway    = "away"
+	PresenceDND     = "dnd"
+	PresenceOffline = "offline"
+)
+
+// Presence is the state of a user as sent in presence and presence_list events.
+type Presence struct {
+	UserID     int        `json:"user_id"`
+	Nickname   string     `json:"nickname"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"` // Set when the user goes offline
+}
+
+// PresenceStatusRequest is the body of PUT /api/users/me/status.
+type PresenceStatusRequest struct {
+	Status string `json:"status"` // online, away or dnd
+}
+
+// IsSelectablePresence reports whether users can pick a presence status for themselves.
+func IsSelectablePresence(status string) bool {
+	return status == PresenceOnline || status == PresenceAway || status == PresenceDND
+}
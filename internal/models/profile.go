@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// ProfileVisibility says which personal details other users can see on a profile.
+// The user, moderators and admins always see everything.
+type ProfileVisibility struct {
+	FullName bool `json:"full_name"`
+	Age      bool `json:"age"`
+	Gender   bool `json:"gender"`
+}
+
+// Profile is a user as shown on their profile page. Private details are left
+// empty for other viewers; Email, Visibility and PresenceStatus are only filled on the user's own profile.
+type Profile struct {
+	ID           int                `json:"id"`
+	Nickname     string             `json:"nickname"`
+	FirstName    string             `json:"first_name,omitempty"`
+	LastName     string             `json:"last_name,omitempty"`
+	Age          int                `json:"age,omitempty"`
+	Gender       string             `json:"gender,omitempty"`
+	Role         string             `json:"role"`
+	JoinedAt     time.Time          `json:"joined_at"`
+	LastSeenAt   *time.Time         `json:"last_seen_at,omitempty"`
+	IsOnline     bool               `json:"is_online"`
+	Status       string             `json:"status"` // Presence, see Presence
+	PostCount    int                `json:"post_count"`
+	CommentCount int                `json:"comment_count"`
+	Posts        []*Post            `json:"posts"`       // Most recent posts, more at /api/users/{id}/posts
+	NextCursor   string             `json:"next_cursor"` // Cursor of the next page of posts
+	Email        string             `json:"email,omitempty"`
+	Visibility   *ProfileVisibility `json:"visibility,omitempty"`
+	// PresenceStatus is the status the user picked, which Status shows unless they are idle or offline
+	PresenceStatus string `json:"presence_status,omitempty"`
+}
+
+// ProfileUpdateRequest is the body of PATCH /api/users/me. Nil fields are left unchanged.
+type ProfileUpdateRequest struct {
+	FirstName  *string            `json:"first_name"`
+	LastName   *string            `json:"last_name"`
+	Age        *int               `json:"age"`
+	Gender     *string            `json:"gender"`
+	Visibility *ProfileVisibility `json:"visibility"`
+}
+
+// EmailChangeRequest is the body of POST /api/users/me/email.
+type EmailChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewEmail        string `json:"new_email"`
+}
+
+// PasswordChangeRequest is the body of POST /api/users/me/password.
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Reaction target types.
+const (
+	TargetPost    = "post"
+	TargetComment = "comment"
+)
+
+// Reactions a user can leave. Up and down are votes and count towards the score;
+// a user can hold only one of them on a target at a time.
+const (
+	ReactionUp         = "up"
+	ReactionDown       = "down"
+	ReactionHeart      = "heart"
+	ReactionLaugh      = "laugh"
+	ReactionInsightful = "insightful"
+)
+
+// ValidReactions lists every accepted reaction.
+var ValidReactions = map[string]bool{
+	ReactionUp:         true,
+	ReactionDown:       true,
+	ReactionHeart:      true,
+	ReactionLaugh:      true,
+	ReactionInsightful: true,
+}
+
+// Reaction is one user's reaction to a post or comment.
+type Reaction struct {
+	UserID     int       `json:"userId"`
+	Nickname   string    `json:"nickname"`
+	TargetType string    `json:"targetType"`
+	TargetID   int       `json:"targetId"`
+	Reaction   string    `json:"reaction"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ReactionRequest is the body of POST /api/reactions.
+type ReactionRequest struct {
+	TargetType string `json:"target_type"`
+	TargetID   int    `json:"target_id"`
+	Reaction   string `json:"reaction"`
+}
@@ -13,4 +13,16 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Identifier string `json:"identifier"` // Can be either nickname or email
 	Password   string `json:"password"`
+	RememberMe bool   `json:"remember_me"` // Longer-lived session with a persistent cookie
+}
+
+// PasswordResetRequest asks for a reset link to be mailed to an address.
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetConfirm sets a new password with the token from a reset link.
+type PasswordResetConfirm struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
 }
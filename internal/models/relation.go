@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Relations a user can set on another user. A block stops direct messages in
+// both directions, hides the blocked user's posts and comments from the blocker
+// and hides presence between the two; a mute only silences notifications.
+const (
+	RelationBlock = "block"
+	RelationMute  = "mute"
+)
+
+// UserRelation is a user the current user has blocked or muted.
+type UserRelation struct {
+	UserID    int       `json:"user_id"`
+	Nickname  string    `json:"nickname"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserRelationRequest is the body of POST /api/blocks and POST /api/mutes.
+type UserRelationRequest struct {
+	UserID int `json:"user_id"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// SearchResult is a single post or comment matched by a full-text search.
+type SearchResult struct {
+	Type      string    `json:"type"`      // "post" or "comment"
+	ID        int       `json:"id"`        // Post ID or comment ID, depending on Type
+	PostID    int       `json:"postId"`    // The post the match belongs to
+	PostTitle string    `json:"postTitle"` // Title of the post, for comment matches too
+	Snippet   string    `json:"snippet"`   // HTML-escaped excerpt with matches wrapped in <mark>
+	Score     float64   `json:"score"`     // bm25 rank, lower is more relevant
+	CreatedAt time.Time `json:"createdAt"`
+	Author    *User     `json:"author"`
+}
+
+// SearchParams holds the filters for a full-text search.
+type SearchParams struct {
+	Query      string
+	Type       string // "post", "comment" or "" for both
+	CategoryID int
+	Author     string // Author nickname
+	ViewerID   int    // Leaves out content by users the viewer blocked when set
+	From       *time.Time
+	To         *time.Time
+	Cursor     string
+	Limit      int
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// SidebarEntry is one line of the chat sidebar: a user, with the direct
+// conversation the viewer has with them if any, or a group conversation.
+// Entries with messages come first, most recent first, then the other users alphabetically.
+type SidebarEntry struct {
+	Kind           string     `json:"kind"`                      // ConversationDirect or ConversationGroup
+	ConversationID int        `json:"conversation_id,omitempty"` // 0 for users the viewer never talked to
+	UserID         int        `json:"user_id,omitempty"`         // Direct only: the other user
+	Name           string     `json:"name"`                      // The other user's nickname or the group name
+	LastMessage    string     `json:"last_message"`
+	LastSenderID   int        `json:"last_sender_id,omitempty"`
+	LastMessageAt  *time.Time `json:"last_message_at,omitempty"`
+	UnreadCount    int        `json:"unread_count"`
+	IsOnline       bool       `json:"is_online"`
+	Status         string     `json:"status,omitempty"`    // Direct only: the other user's presence, see Presence
+	Blocked        bool       `json:"blocked,omitempty"`   // Direct only: the viewer blocked the other user
+	Muted          bool       `json:"muted,omitempty"`     // Direct only: the viewer muted the other user
+	ActiveAt       *time.Time `json:"active_at,omitempty"` // Sort key: last message, or creation for a group without messages
+	HidePresence   bool       `json:"-"`                   // One of the viewer and the user blocked the other
+}
+
+// SidebarPresence is a change of presence of a user shown in the sidebar.
+type SidebarPresence struct {
+	UserID   int    `json:"user_id"`
+	Status   string `json:"status"`
+	IsOnline bool   `json:"is_online"` // Any status but offline
+}
+
+// SidebarUpdate is the payload of a sidebar_update event. Entries replace the
+// client's copy of the same conversation or user and take their place in the order;
+// presence changes only update status and is_online.
+type SidebarUpdate struct {
+	Entries  []*SidebarEntry   `json:"entries,omitempty"`
+	Presence []SidebarPresence `json:"presence,omitempty"`
+}
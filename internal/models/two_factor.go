@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// LoginChallenge is a login that passed the password check and waits for its
+// second factor. The client holds the pending token; only its hash is stored.
+type LoginChallenge struct {
+	ID         int
+	UserID     int
+	TokenHash  string
+	Identifier string // What the user typed at the password step, for the lockout counters
+	Remember   bool
+	Attempts   int
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+}
+
+// TwoFactorStatus describes the two-factor setup of the current user.
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"` // Moderators and admins need it for their privileges
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorRequest carries a TOTP or recovery code, and the password where
+// an action also needs it (disabling two-factor authentication).
+type TwoFactorRequest struct {
+	Code     string `json:"code"`
+	Password string `json:"password,omitempty"`
+}
+
+// LoginTwoFactorRequest completes a login with the pending token from the password step.
+type LoginTwoFactorRequest struct {
+	PendingToken string `json:"pending_token"`
+	Code         string `json:"code"`
+}
@@ -4,23 +4,63 @@ import "time"
 
 // User represents a user in the database.
 type User struct {
-	ID           int        `json:"id"`
-	Nickname     string     `json:"nickname"`
-	Email        string     `json:"email"`
-	PasswordHash string     `json:"-"` // Should not be sent to the client
-	FirstName    string     `json:"firstName"`
-	LastName     string     `json:"lastName"`
-	Age          int        `json:"age"`
-	Gender       string     `json:"gender"`
-	CreatedAt    time.Time  `json:"createdAt"`
-	LastLogin    *time.Time `json:"lastLogin,omitempty"` // Use pointer for nullable field
-	IsOnline     bool       `json:"isOnline"`
+	ID            int        `json:"id"`
+	Nickname      string     `json:"nickname"`
+	Email         string     `json:"email"`
+	PasswordHash  string     `json:"-"` // Should not be sent to the client
+	FirstName     string     `json:"firstName"`
+	LastName      string     `json:"lastName"`
+	Age           int        `json:"age"`
+	Gender        string     `json:"gender"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	LastLogin     *time.Time `json:"lastLogin,omitempty"` // Use pointer for nullable field
+	IsOnline      bool       `json:"isOnline"`
+	Role          string     `json:"role"`
+	EmailVerified bool       `json:"emailVerified"`    // Unverified accounts cannot create posts
+	TwoFactor     bool       `json:"twoFactorEnabled"` // TOTP is required at login
+	// SuspendedUntil is set while a moderator has suspended the account
+	SuspendedUntil *time.Time `json:"suspendedUntil,omitempty"`
 }
 
+// DirectoryUser is one entry of the user directory, GET /api/users.
+type DirectoryUser struct {
+	ID            int        `json:"id"`
+	Nickname      string     `json:"nickname"`
+	IsOnline      bool       `json:"is_online"`
+	Status        string     `json:"status"`                    // Presence, see Presence
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"` // Last direct message with the viewer
+	HidePresence  bool       `json:"-"`                         // One of the viewer and the user blocked the other
+}
+
+// UserListOptions filters and pages the user directory as seen by ViewerID.
+type UserListOptions struct {
+	ViewerID int
+	Prefix   string // Only nicknames starting with this, case-insensitive
+	Cursor   string
+	Limit    int
+}
+
+// User roles. What each role may do is defined in auth.rolePermissions;
+// moderator and admin privileges only apply once two-factor authentication is enabled.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 // Session represents a user session in the database.
+// The token and the role it was issued for never leave the server.
 type Session struct {
-	UserID    int
-	Token     string
-	Expiry    time.Time
-	CreatedAt time.Time
+	ID             int       `json:"id"`
+	UserID         int       `json:"-"`
+	Token          string    `json:"-"`
+	Expiry         time.Time `json:"expiry"`          // Moves forward on use, up to AbsoluteExpiry
+	AbsoluteExpiry time.Time `json:"absolute_expiry"` // Hard end of the session however active it is
+	Remember       bool      `json:"remember"`        // Signed in with "remember me"
+	CreatedAt      time.Time `json:"created_at"`
+	LastSeenAt     time.Time `json:"last_seen_at"`
+	IP             string    `json:"ip"`
+	UserAgent      string    `json:"user_agent"`
+	Role           string    `json:"-"`       // User role when the token was issued; a mismatch rejects the token
+	Current        bool      `json:"current"` // Set when listing: this is the session of the request
 }
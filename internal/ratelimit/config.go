@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// ConfigEnv names the environment variable holding the path of a JSON file that
+// overrides the default limits, so they can be tuned without recompiling.
+const ConfigEnv = "RATE_LIMIT_CONFIG"
+
+// HTTP route groups. Requests outside these groups (static files, pages) are not limited.
+const (
+	GroupAuth  = "auth"  // /login, /login/2fa, /register, /logout, email verification and password reset
+	GroupRead  = "read"  // GET requests to /api/
+	GroupWrite = "write" // Other requests to /api/
+	GroupWS    = "ws"    // WebSocket upgrades
+)
+
+// Config holds every limit of the server.
+type Config struct {
+	// HTTP maps a route group to its policy
+	HTTP map[string]Policy `json:"http"`
+	// WS maps a WebSocket message type to its policy; "default" applies to unlisted types
+	WS map[string]Policy `json:"ws"`
+	// WSMaxViolations is how many rate_limited events a connection may get within
+	// WSViolationWindowSeconds before it is closed as abusive
+	WSMaxViolations          int `json:"ws_max_violations"`
+	WSViolationWindowSeconds int `json:"ws_violation_window_seconds"`
+}
+
+// DefaultConfig returns the limits used when no configuration file is given.
+func DefaultConfig() Config {
+	return Config{
+		HTTP: map[string]Policy{
+			GroupAuth:  {Rate: 0.2, Burst: 10}, // 10 attempts, then one every 5s
+			GroupRead:  {Rate: 10, Burst: 60},
+			GroupWrite: {Rate: 1, Burst: 20},
+			GroupWS:    {Rate: 0.5, Burst: 10},
+		},
+		WS: map[string]Policy{
+			"private_message": {Rate: 2, Burst: 20},
+			"typing_start":    {Rate: 1, Burst: 5},
+			"typing_stop":     {Rate: 1, Burst: 5},
+			"heartbeat":       {Rate: 0.2, Burst: 5},
+			"default":         {Rate: 5, Burst: 30},
+		},
+		WSMaxViolations:          20,
+		WSViolationWindowSeconds: 60,
+	}
+}
+
+// LoadConfig returns the default limits, overridden by the JSON file named in
+// RATE_LIMIT_CONFIG when it is set. Groups and message types missing from the
+// file keep their defaults; a policy with rate 0 disables that limit.
+func LoadConfig() (Config, error) {
+	cfg := DefaultConfig()
+
+	path := os.Getenv(ConfigEnv)
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read rate limit config: %w", err)
+	}
+
+	var file Config
+	if err := json.Unmarshal(data, &file); err != nil {
+		return cfg, fmt.Errorf("parse rate limit config %s: %w", path, err)
+	}
+	for group, policy := range file.HTTP {
+		cfg.HTTP[group] = policy
+	}
+	for msgType, policy := range file.WS {
+		cfg.WS[msgType] = policy
+	}
+	if file.WSMaxViolations > 0 {
+		cfg.WSMaxViolations = file.WSMaxViolations
+	}
+	if file.WSViolationWindowSeconds > 0 {
+		cfg.WSViolationWindowSeconds = file.WSViolationWindowSeconds
+	}
+
+	log.Printf("[config.go:LoadConfig] Loaded rate limits from %s", path)
+	return cfg, nil
+}
+
+// WSPolicy returns the policy for a WebSocket message type.
+func (c Config) WSPolicy(msgType string) Policy {
+	if policy, ok := c.WS[msgType]; ok {
+		return policy
+	}
+	return c.WS["default"]
+}
+
+// WSBucket returns the name a message type is limited under: the type itself
+// when it has a policy, "default" otherwise, so that unlisted types share one bucket.
+func (c Config) WSBucket(msgType string) string {
+	if _, ok := c.WS[msgType]; ok {
+		return msgType
+	}
+	return "default"
+}
+
+// ViolationWindow returns WSViolationWindowSeconds as a duration.
+func (c Config) ViolationWindow() time.Duration {
+	return time.Duration(c.WSViolationWindowSeconds) * time.Second
+}
@@ -0,0 +1,88 @@
+// Package ratelimit implements token-bucket rate limiting for HTTP routes and
+// WebSocket messages. Buckets are keyed by caller (user ID or IP) and policy name.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// idleBucketTTL is how long an untouched bucket is kept before it is pruned
+const idleBucketTTL = 10 * time.Minute
+
+// Policy describes one token bucket: Burst tokens at most, refilled at Rate tokens per second.
+// A zero Rate disables the policy.
+type Policy struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Enabled reports whether the policy limits anything.
+func (p Policy) Enabled() bool {
+	return p.Rate > 0 && p.Burst > 0
+}
+
+// bucket is the state of one caller under one policy
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter holds the buckets of every caller. It is safe for concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+// NewLimiter creates an empty limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets:   make(map[string]*bucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// Allow takes one token from the bucket of key under the policy. When the bucket
+// is empty it returns false and how long the caller must wait for the next token.
+func (l *Limiter) Allow(key string, policy Policy) (bool, time.Duration) {
+	if !policy.Enabled() {
+		return true, 0
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > idleBucketTTL {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(policy.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	// Refill for the time elapsed since the last request, capped at the burst size
+	b.tokens = math.Min(float64(policy.Burst), b.tokens+now.Sub(b.last).Seconds()*policy.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / policy.Rate * float64(time.Second))
+	return false, wait
+}
+
+// prune drops buckets that have not been used for a while; they would be full again anyway
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleBucketTTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastPrune = now
+}
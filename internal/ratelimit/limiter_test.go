@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterBurst(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		allowed int // requests allowed in a row before the first refusal, -1 for never refused
+	}{
+		{"burst of three", Policy{Rate: 0.1, Burst: 3}, 3},
+		{"burst of one", Policy{Rate: 0.1, Burst: 1}, 1},
+		{"zero rate disables", Policy{Rate: 0, Burst: 1}, -1},
+		{"zero burst disables", Policy{Rate: 1, Burst: 0}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter()
+			for i := 0; i < 10; i++ {
+				ok, wait := l.Allow("user:1", tt.policy)
+				want := tt.allowed < 0 || i < tt.allowed
+				if ok != want {
+					t.Fatalf("request %d: allowed = %v, want %v", i+1, ok, want)
+				}
+				if ok && wait != 0 {
+					t.Errorf("request %d: allowed with a wait of %v", i+1, wait)
+				}
+				if !ok && (wait <= 0 || wait > time.Duration(float64(time.Second)/tt.policy.Rate)) {
+					t.Errorf("request %d: wait %v, want at most one refill interval", i+1, wait)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterKeysAreIndependent(t *testing.T) {
+	l := NewLimiter()
+	policy := Policy{Rate: 0.1, Burst: 1}
+	if ok, _ := l.Allow("user:1:typing_start", policy); !ok {
+		t.Fatal("first request refused")
+	}
+	if ok, _ := l.Allow("user:1:typing_start", policy); ok {
+		t.Fatal("second request on an empty bucket allowed")
+	}
+	for _, key := range []string{"user:2:typing_start", "user:1:private_message", "ip:127.0.0.1"} {
+		if ok, _ := l.Allow(key, policy); !ok {
+			t.Errorf("%s refused because of another key", key)
+		}
+	}
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l := NewLimiter()
+	policy := Policy{Rate: 50, Burst: 2} // a token every 20ms
+	for i := 0; i < 2; i++ {
+		l.Allow("k", policy)
+	}
+	ok, wait := l.Allow("k", policy)
+	if ok {
+		t.Fatal("request on an empty bucket allowed")
+	}
+
+	time.Sleep(wait + 5*time.Millisecond)
+	if ok, _ := l.Allow("k", policy); !ok {
+		t.Fatalf("refused after waiting the returned %v", wait)
+	}
+
+	// A long pause refills the bucket only up to the burst
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("k", policy); !ok {
+			t.Fatalf("request %d after a pause refused", i+1)
+		}
+	}
+	if ok, _ := l.Allow("k", policy); ok {
+		t.Error("bucket refilled beyond its burst")
+	}
+}
+
+func TestLimiterPrunesIdleBuckets(t *testing.T) {
+	l := NewLimiter()
+	policy := Policy{Rate: 1, Burst: 1}
+	l.Allow("old", policy)
+	l.Allow("recent", policy)
+
+	now := time.Now()
+	l.buckets["old"].last = now.Add(-2 * idleBucketTTL)
+	l.lastPrune = now.Add(-2 * idleBucketTTL)
+
+	l.Allow("recent", policy)
+	if _, ok := l.buckets["old"]; ok {
+		t.Error("idle bucket was not pruned")
+	}
+	if _, ok := l.buckets["recent"]; !ok {
+		t.Error("active bucket was pruned")
+	}
+}
+
+func TestWSBucket(t *testing.T) {
+	cfg := DefaultConfig()
+	tests := []struct {
+		msgType string
+		want    string
+	}{
+		{"private_message", "private_message"},
+		{"heartbeat", "heartbeat"},
+		{"default", "default"},
+		{"made_up_type_1", "default"},
+		{"", "default"},
+	}
+	for _, tt := range tests {
+		if got := cfg.WSBucket(tt.msgType); got != tt.want {
+			t.Errorf("WSBucket(%q) = %q, want %q", tt.msgType, got, tt.want)
+		}
+		if got, want := cfg.WSPolicy(tt.msgType), cfg.WS[tt.want]; got != want {
+			t.Errorf("WSPolicy(%q) = %+v, want %+v", tt.msgType, got, want)
+		}
+	}
+}
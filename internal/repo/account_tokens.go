@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// CreateAccountToken stores the hash of a new token for a user. Unused tokens
+// the user already had for the same purpose stop working, so only the most
+// recently mailed link is valid.
+func CreateAccountToken(userID int, purpose, tokenHash string, expiresAt time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.Exec(`
+		UPDATE account_tokens SET used_at = ?
+		WHERE user_id = ? AND purpose = ? AND used_at IS NULL
+	`, now, userID, purpose); err != nil {
+		log.Printf("[account_tokens.go:CreateAccountToken] Error invalidating old tokens: %v", err)
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO account_tokens (user_id, purpose, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, purpose, tokenHash, expiresAt, now); err != nil {
+		log.Printf("[account_tokens.go:CreateAccountToken] Error creating %s token: %v", purpose, err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// VerifyEmailWithToken consumes an email verification token and marks the
+// address of its user as verified. It returns the user ID, or ErrNoRows when
+// the token is unknown, expired or already used.
+func VerifyEmailWithToken(tokenHash string) (int, error) {
+	return useAccountToken(models.TokenVerifyEmail, tokenHash, func(tx *sql.Tx, userID int, now time.Time) error {
+		_, err := tx.Exec(`UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?) WHERE id = ?`, now, userID)
+		return err
+	})
+}
+
+// ResetPasswordWithToken consumes a password reset token and sets the new
+// password hash of its user. Receiving the mail proves the address, so it is
+// marked verified as well. It returns the user ID, or ErrNoRows when the token
+// is unknown, expired or already used.
+func ResetPasswordWithToken(tokenHash, passwordHash string) (int, error) {
+	return useAccountToken(models.TokenResetPassword, tokenHash, func(tx *sql.Tx, userID int, now time.Time) error {
+		_, err := tx.Exec(`
+			UPDATE users SET password_hash = ?, email_verified_at = COALESCE(email_verified_at, ?)
+			WHERE id = ?
+		`, passwordHash, now, userID)
+		return err
+	})
+}
+
+// useAccountToken marks a valid token as used and applies its effect in the
+// same transaction, so a token can never be used twice.
+func useAccountToken(purpose, tokenHash string, apply func(tx *sql.Tx, userID int, now time.Time) error) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var id, userID int
+	err = tx.QueryRow(`
+		SELECT id, user_id FROM account_tokens
+		WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?
+	`, tokenHash, purpose, now).Scan(&id, &userID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[account_tokens.go:useAccountToken] Error looking up %s token: %v", purpose, err)
+		}
+		return 0, err
+	}
+
+	res, err := tx.Exec(`UPDATE account_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`, now, id)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return 0, ErrNoRows // Used concurrently
+	}
+
+	if err := apply(tx, userID, now); err != nil {
+		log.Printf("[account_tokens.go:useAccountToken] Error applying %s token of user %d: %v", purpose, userID, err)
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
+
+// PurgeAccountTokens deletes tokens that were used or expired before the given time.
+func PurgeAccountTokens(before time.Time) (int64, error) {
+	res, err := DB.Exec(`
+		DELETE FROM account_tokens
+		WHERE (used_at IS NOT NULL AND used_at < ?) OR expires_at < ?
+	`, before, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,227 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/models"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// categoryColumns selects a category with its visible post count and the time
+// of the latest post or comment in it. julianday() compares timestamps written
+// by SQLite and by the driver alike; datetime() turns the maximum back into UTC text.
+const categoryColumns = `
+	c.id, c.slug, c.name, c.description, c.position, c.archived_at IS NOT NULL,
+	(SELECT COUNT(*) FROM post_categories pc JOIN posts p ON p.id = pc.post_id
+		WHERE pc.category_id = c.id AND p.deleted_at IS NULL AND p.hidden_at IS NULL),
+	datetime(NULLIF(MAX(
+		COALESCE((SELECT MAX(julianday(p.created_at)) FROM post_categories pc JOIN posts p ON p.id = pc.post_id
+			WHERE pc.category_id = c.id AND p.deleted_at IS NULL AND p.hidden_at IS NULL), 0),
+		COALESCE((SELECT MAX(julianday(cm.created_at)) FROM post_categories pc JOIN posts p ON p.id = pc.post_id
+			JOIN comments cm ON cm.post_id = p.id
+			WHERE pc.category_id = c.id AND p.deleted_at IS NULL AND p.hidden_at IS NULL AND cm.deleted_at IS NULL AND cm.hidden_at IS NULL), 0)
+	), 0))`
+
+// scanCategory reads a row selected with categoryColumns.
+func scanCategory(row interface{ Scan(...interface{}) error }) (*models.Category, error) {
+	category := &models.Category{}
+	var lastActivity sql.NullString
+	err := row.Scan(&category.ID, &category.Slug, &category.Name, &category.Description, &category.Position,
+		&category.Archived, &category.PostCount, &lastActivity)
+	if err != nil {
+		return nil, err
+	}
+	if lastActivity.Valid {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", lastActivity.String, time.UTC); err == nil {
+			category.LastActivityAt = &t
+		}
+	}
+	return category, nil
+}
+
+// GetCategories returns the categories in display order, with their post
+// counts and last activity. Archived categories are only included when asked for.
+func GetCategories(includeArchived bool) ([]*models.Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories c`
+	if !includeArchived {
+		query += ` WHERE c.archived_at IS NULL`
+	}
+	query += ` ORDER BY c.position ASC, c.name ASC`
+
+	rows, err := DB.Query(query)
+	if err != nil {
+		log.Printf("[categories.go:GetCategories] Error querying categories: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []*models.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			log.Printf("[categories.go:GetCategories] Error scanning category: %v", err)
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// GetCategoryBySlug returns one category, archived or not. It returns ErrNoRows
+// when no category has the slug.
+func GetCategoryBySlug(slug string) (*models.Category, error) {
+	return scanCategory(DB.QueryRow(`SELECT `+categoryColumns+` FROM categories c WHERE c.slug = ?`, slug))
+}
+
+// isUniqueViolation reports whether err comes from a UNIQUE constraint.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// CreateCategory adds a category at the end of the display order and sets its
+// ID and position. It returns ErrDuplicateEntry when the name or slug is taken.
+func CreateCategory(category *models.Category) error {
+	res, err := DB.Exec(`
+		INSERT INTO categories (name, slug, description, position)
+		VALUES (?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM categories))
+	`, category.Name, category.Slug, category.Description)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicateEntry
+		}
+		log.Printf("[categories.go:CreateCategory] Error creating category %q: %v", category.Name, err)
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	category.ID = int(id)
+	return DB.QueryRow(`SELECT position FROM categories WHERE id = ?`, id).Scan(&category.Position)
+}
+
+// UpdateCategory saves the name, slug, description and archived state of a
+// category. It returns ErrDuplicateEntry when the new name or slug is taken.
+func UpdateCategory(category *models.Category) error {
+	_, err := DB.Exec(`
+		UPDATE categories SET name = ?, slug = ?, description = ?,
+			archived_at = CASE WHEN ? THEN COALESCE(archived_at, ?) ELSE NULL END
+		WHERE id = ?
+	`, category.Name, category.Slug, category.Description, category.Archived, time.Now(), category.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicateEntry
+		}
+		log.Printf("[categories.go:UpdateCategory] Error updating category %d: %v", category.ID, err)
+		return err
+	}
+	return nil
+}
+
+// ReorderCategories moves the categories with the given slugs to the front of
+// the display order, in that order; the others keep their relative order after
+// them. It returns ErrNoRows when a slug matches no category.
+func ReorderCategories(slugs []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, slug FROM categories ORDER BY position ASC, name ASC`)
+	if err != nil {
+		return err
+	}
+	idBySlug := map[string]int{}
+	var current []int
+	for rows.Next() {
+		var id int
+		var slug string
+		if err := rows.Scan(&id, &slug); err != nil {
+			rows.Close()
+			return err
+		}
+		idBySlug[slug] = id
+		current = append(current, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	placed := map[int]bool{}
+	var order []int
+	for _, slug := range slugs {
+		id, ok := idBySlug[slug]
+		if !ok {
+			return ErrNoRows
+		}
+		if !placed[id] {
+			placed[id] = true
+			order = append(order, id)
+		}
+	}
+	for _, id := range current {
+		if !placed[id] {
+			order = append(order, id)
+		}
+	}
+
+	for i, id := range order {
+		if _, err := tx.Exec(`UPDATE categories SET position = ? WHERE id = ?`, i+1, id); err != nil {
+			log.Printf("[categories.go:ReorderCategories] Error moving category %d: %v", id, err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteCategory removes a category. Its posts stay, without this category.
+func DeleteCategory(id int) error {
+	res, err := DB.Exec(`DELETE FROM categories WHERE id = ?`, id)
+	if err != nil {
+		log.Printf("[categories.go:DeleteCategory] Error deleting category %d: %v", id, err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// CategoriesAcceptPosts reports whether a post can be filed under every given
+// category: each must exist and not be archived, unless the post (postID, 0 for
+// a new post) is already in it.
+func CategoriesAcceptPosts(postID int, ids []int) (bool, error) {
+	unique := map[int]bool{}
+	for _, id := range ids {
+		unique[id] = true
+	}
+	if len(unique) == 0 {
+		return true, nil
+	}
+
+	args := make([]interface{}, 0, len(unique)+1)
+	args = append(args, postID)
+	for id := range unique {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(unique)), ",")
+
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM categories
+		WHERE (archived_at IS NULL OR id IN (SELECT category_id FROM post_categories WHERE post_id = ?))
+			AND id IN (`+placeholders+`)
+	`, args...).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == len(unique), nil
+}
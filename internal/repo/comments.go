@@ -1,59 +1,252 @@
 package repo
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"real-time-forum/internal/models"
 )
 
+var (
+	// ErrInvalidParent is returned when a reply targets a comment on a different post.
+	ErrInvalidParent = errors.New("parent comment does not belong to this post")
+	// ErrParentDeleted is returned when a reply targets a deleted comment.
+	ErrParentDeleted = errors.New("parent comment has been deleted")
+	// ErrParentHidden is returned when a reply targets a comment hidden by a moderator.
+	ErrParentHidden = errors.New("parent comment has been hidden")
+)
+
 // CreateComment inserts a new comment into the database.
+// When comment.ParentID is set the comment is stored as a reply, one level below its parent,
+// which must be neither deleted nor hidden.
 func CreateComment(comment *models.Comment) (int64, error) {
-	stmt, err := DB.Prepare(`
-		INSERT INTO comments (post_id, user_id, content, created_at)
-		VALUES (?, ?, ?, ?)
-	`)
+	tx, err := DB.Begin()
 	if err != nil {
-		log.Printf("[comments.go:CreateComment] Error preparing create comment statement: %v", err)
 		return 0, err
 	}
-	defer stmt.Close()
 
-	res, err := stmt.Exec(comment.PostID, comment.UserID, comment.Content, time.Now())
+	parentPath := ""
+	depth := 0
+	if comment.ParentID != nil {
+		var parentPostID int
+		var parentDeleted, parentHidden bool
+		err := tx.QueryRow(`SELECT post_id, path, depth, deleted_at IS NOT NULL, hidden_at IS NOT NULL FROM comments WHERE id = ?`, *comment.ParentID).
+			Scan(&parentPostID, &parentPath, &depth, &parentDeleted, &parentHidden)
+		if err != nil {
+			tx.Rollback()
+			if err == sql.ErrNoRows {
+				return 0, ErrInvalidParent
+			}
+			return 0, err
+		}
+		if parentPostID != comment.PostID {
+			tx.Rollback()
+			return 0, ErrInvalidParent
+		}
+		if parentDeleted {
+			tx.Rollback()
+			return 0, ErrParentDeleted
+		}
+		if parentHidden {
+			tx.Rollback()
+			return 0, ErrParentHidden
+		}
+		depth++
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO comments (post_id, user_id, parent_id, depth, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, comment.PostID, comment.UserID, comment.ParentID, depth, comment.Content, time.Now())
 	if err != nil {
-		log.Printf("Error executing create comment statement: %v", err)
+		tx.Rollback()
+		log.Printf("[comments.go:CreateComment] Error executing create comment statement: %v", err)
 		return 0, err
 	}
 
-	return res.LastInsertId()
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	path := commentPathSegment(id)
+	if parentPath != "" {
+		path = parentPath + "/" + path
+	}
+	if _, err := tx.Exec(`UPDATE comments SET path = ? WHERE id = ?`, path, id); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// commentPathSegment formats a comment ID as one fixed-width segment of a thread path.
+func commentPathSegment(id int64) string {
+	return fmt.Sprintf("%010d", id)
 }
 
-// GetCommentsByPostID retrieves comments for a given post ID with pagination.
-// It also fetches the author's nickname for each comment.
-func GetCommentsByPostID(postID, limit, offset int) ([]*models.Comment, error) {
-	query := `
-		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, u.nickname
+// GetCommentsByPostID retrieves one page of comment threads for a post.
+// Pages count top-level comments only, oldest first, using keyset pagination on
+// (created_at, id); each page includes the replies of its top-level comments down to
+// maxDepth (0 returns top-level comments only). Comments are returned flat in thread
+// order (sorted by path). The returned cursor is "" when there are no more threads.
+// Comments by users the viewer blocked stay in place, like deleted ones, without
+// their content or author; viewerID 0 is an anonymous reader.
+func GetCommentsByPostID(postID int, cursor string, limit, maxDepth, viewerID int) ([]*models.Comment, string, error) {
+	after, afterArgs, err := keysetCondition(cursor, "created_at", "id", false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 1. The page of top-level comments
+	rootQuery := `
+		SELECT id, CAST(created_at AS TEXT)
+		FROM comments
+		WHERE post_id = ? AND parent_id IS NULL AND ` + after + `
+		ORDER BY created_at ASC, id ASC
+		LIMIT ?
+	`
+	args := append([]interface{}{postID}, afterArgs...)
+	args = append(args, limit+1)
+
+	rows, err := DB.Query(rootQuery, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	var rootIDs []int
+	var rawTimes []string
+	for rows.Next() {
+		var id int
+		var rawCreatedAt string
+		if err := rows.Scan(&id, &rawCreatedAt); err != nil {
+			rows.Close()
+			return nil, "", err
+		}
+		rootIDs = append(rootIDs, id)
+		rawTimes = append(rawTimes, rawCreatedAt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(rootIDs) > limit {
+		rootIDs = rootIDs[:limit]
+		nextCursor = nextTimeCursor(rawTimes[limit-1], rootIDs[limit-1])
+	}
+	if len(rootIDs) == 0 {
+		return nil, "", nil
+	}
+
+	// 2. Those threads, down to maxDepth, in thread order
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(rootIDs)), ",")
+	threadQuery := `
+		SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.created_at, c.depth, c.path, u.nickname,
+			(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count, c.deleted_at IS NOT NULL, c.hidden_at IS NOT NULL,
+			EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = ? AND blocked_id = c.user_id)
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
-		WHERE c.post_id = ?
-		ORDER BY c.created_at ASC
-		LIMIT ? OFFSET ?
+		WHERE c.post_id = ? AND c.depth <= ? AND substr(c.path, 1, 10) IN (` + placeholders + `)
+		ORDER BY c.path ASC
 	`
-	rows, err := DB.Query(query, postID, limit, offset)
+	args = []interface{}{viewerID, postID, maxDepth}
+	for _, id := range rootIDs {
+		args = append(args, commentPathSegment(int64(id)))
+	}
+
+	rows, err = DB.Query(threadQuery, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	var comments []*models.Comment
+	// Paths sort threads in ID order; put them back in the order of the page.
+	threads := make(map[string][]*models.Comment, len(rootIDs))
 	for rows.Next() {
 		comment := &models.Comment{Author: &models.User{}}
-		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.Content, &comment.CreatedAt, &comment.Author.Nickname); err != nil {
-			return nil, err
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentID, &comment.Content, &comment.CreatedAt,
+			&comment.Depth, &comment.Path, &comment.Author.Nickname, &comment.ReplyCount, &comment.Deleted, &comment.Hidden,
+			&comment.Blocked); err != nil {
+			return nil, "", err
 		}
-		comments = append(comments, comment)
+		clearDeletedComment(comment)
+		root := comment.Path[:10]
+		threads[root] = append(threads[root], comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var comments []*models.Comment
+	for _, id := range rootIDs {
+		comments = append(comments, threads[commentPathSegment(int64(id))]...)
+	}
+	if err := attachCommentReactions(comments); err != nil {
+		return nil, "", err
+	}
+	return comments, nextCursor, nil
+}
+
+// GetCommentByID retrieves a single comment with its author's nickname.
+func GetCommentByID(id int64) (*models.Comment, error) {
+	comment := &models.Comment{Author: &models.User{}}
+	err := DB.QueryRow(`
+		SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.created_at, c.depth, c.path, u.id, u.nickname,
+			(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count, c.deleted_at IS NOT NULL, c.hidden_at IS NOT NULL
+		FROM comments c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.id = ?
+	`, id).Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentID, &comment.Content, &comment.CreatedAt,
+		&comment.Depth, &comment.Path, &comment.Author.ID, &comment.Author.Nickname, &comment.ReplyCount, &comment.Deleted, &comment.Hidden)
+	if err != nil {
+		return nil, err
+	}
+	clearDeletedComment(comment)
+	if err := attachCommentReactions([]*models.Comment{comment}); err != nil {
+		return nil, err
 	}
-	return comments, rows.Err()
+	return comment, nil
+}
+
+// clearDeletedComment hides the content of a soft-deleted, hidden or blocked comment.
+// The comment stays in the result so its replies keep their parent.
+func clearDeletedComment(comment *models.Comment) {
+	if comment.Deleted || comment.Hidden || comment.Blocked {
+		comment.Content = ""
+	}
+	if comment.Blocked {
+		comment.Author.Nickname = ""
+	}
+}
+
+// SoftDeleteComment marks a comment as deleted without removing it, so its replies survive.
+func SoftDeleteComment(commentID int) error {
+	res, err := DB.Exec(`UPDATE comments SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, time.Now(), commentID)
+	if err != nil {
+		log.Printf("[comments.go:SoftDeleteComment] Error deleting comment %d: %v", commentID, err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// CountTopLevelComments returns the number of top-level comments (threads) on a post.
+func CountTopLevelComments(postID int) (int, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM comments WHERE post_id = ? AND parent_id IS NULL`, postID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
 // CountCommentsByPostID returns the total number of comments for a specific post.
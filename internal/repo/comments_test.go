@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"testing"
+
+	"real-time-forum/internal/models"
+)
+
+func TestCreateCommentParent(t *testing.T) {
+	openTestDB(t)
+
+	alice := createTestUser(t, "alice")
+	postID, err := CreatePost(&models.Post{UserID: alice.ID, Title: "Thread", Content: "Body"}, nil)
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	otherPostID, err := CreatePost(&models.Post{UserID: alice.ID, Title: "Other", Content: "Body"}, nil)
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	newParent := func(postID int64, state string) int {
+		t.Helper()
+		id, err := CreateComment(&models.Comment{PostID: int(postID), UserID: alice.ID, Content: "parent"})
+		if err != nil {
+			t.Fatalf("CreateComment: %v", err)
+		}
+		if state != "" {
+			if _, err := DB.Exec(`UPDATE comments SET `+state+` = CURRENT_TIMESTAMP WHERE id = ?`, id); err != nil {
+				t.Fatalf("setting %s: %v", state, err)
+			}
+		}
+		return int(id)
+	}
+
+	tests := []struct {
+		name    string
+		parent  int
+		wantErr error
+	}{
+		{"visible parent", newParent(postID, ""), nil},
+		{"deleted parent", newParent(postID, "deleted_at"), ErrParentDeleted},
+		{"hidden parent", newParent(postID, "hidden_at"), ErrParentHidden},
+		{"parent on another post", newParent(otherPostID, ""), ErrInvalidParent},
+		{"missing parent", 9999, ErrInvalidParent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := tt.parent
+			_, err := CreateComment(&models.Comment{PostID: int(postID), UserID: alice.ID, ParentID: &parent, Content: "reply"})
+			if err != tt.wantErr {
+				t.Fatalf("CreateComment error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
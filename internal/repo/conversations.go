@@ -0,0 +1,404 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+var (
+	// ErrNotMember is returned when a user acts on a conversation they do not belong to.
+	ErrNotMember = errors.New("user is not a member of this conversation")
+	// ErrNotGroup is returned for group-only operations on a direct conversation.
+	ErrNotGroup = errors.New("conversation is not a group")
+)
+
+// directConversationKey identifies the direct conversation of two users regardless of order.
+func directConversationKey(userA, userB int) string {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	return fmt.Sprintf("%d:%d", userA, userB)
+}
+
+// directConversation returns the ID of the direct conversation between two users,
+// creating it with both members when they have never talked before.
+func directConversation(tx *sql.Tx, userA, userB int, createdAt time.Time) (int, error) {
+	key := directConversationKey(userA, userB)
+
+	var id int
+	err := tx.QueryRow(`SELECT id FROM conversations WHERE direct_key = ?`, key).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO conversations (kind, direct_key, created_at)
+		VALUES (?, ?, ?)
+	`, models.ConversationDirect, key, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	newID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, userID := range []int{userA, userB} {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO conversation_members (conversation_id, user_id, role, joined_at)
+			VALUES (?, ?, ?, ?)
+		`, newID, userID, models.MemberMember, createdAt); err != nil {
+			return 0, err
+		}
+	}
+	return int(newID), nil
+}
+
+// conversationRecipients returns the kind of a conversation and every member except the sender.
+// It returns ErrNotMember when the sender does not belong to the conversation.
+func conversationRecipients(tx *sql.Tx, conversationID, senderID int) (string, []int, error) {
+	var kind string
+	err := tx.QueryRow(`SELECT kind FROM conversations WHERE id = ?`, conversationID).Scan(&kind)
+	if err == sql.ErrNoRows {
+		return "", nil, ErrNotMember
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	rows, err := tx.Query(`SELECT user_id FROM conversation_members WHERE conversation_id = ?`, conversationID)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	isMember := false
+	var recipients []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return "", nil, err
+		}
+		if userID == senderID {
+			isMember = true
+			continue
+		}
+		recipients = append(recipients, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+	if !isMember {
+		return "", nil, ErrNotMember
+	}
+	return kind, recipients, nil
+}
+
+// CreateGroupConversation creates a named group with the creator as owner and
+// the given users as members. It returns the new conversation ID.
+func CreateGroupConversation(creatorID int, name string, memberIDs []int) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	res, err := tx.Exec(`
+		INSERT INTO conversations (kind, name, created_by, created_at)
+		VALUES (?, ?, ?, ?)
+	`, models.ConversationGroup, name, creatorID, now)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("[conversations.go:CreateGroupConversation] Error creating conversation: %v", err)
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO conversation_members (conversation_id, user_id, role, joined_at)
+		VALUES (?, ?, ?, ?)
+	`, id, creatorID, models.MemberOwner, now); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := addMembers(tx, int(id), memberIDs, now); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+// addMembers adds users to a conversation, ignoring those already in it.
+// New members start with everything sent so far marked as read.
+func addMembers(tx *sql.Tx, conversationID int, userIDs []int, joinedAt time.Time) error {
+	for _, userID := range userIDs {
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO conversation_members (conversation_id, user_id, role, joined_at, last_read_message_id)
+			VALUES (?, ?, ?, ?, COALESCE((SELECT MAX(id) FROM private_messages WHERE conversation_id = ?), 0))
+		`, conversationID, userID, models.MemberMember, joinedAt, conversationID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requireGroupMember checks that a conversation is a group and that the user belongs to it.
+func requireGroupMember(tx *sql.Tx, conversationID, userID int) error {
+	var kind string
+	err := tx.QueryRow(`
+		SELECT c.kind
+		FROM conversations c
+		JOIN conversation_members cm ON cm.conversation_id = c.id AND cm.user_id = ?
+		WHERE c.id = ?
+	`, userID, conversationID).Scan(&kind)
+	if err == sql.ErrNoRows {
+		return ErrNotMember
+	}
+	if err != nil {
+		return err
+	}
+	if kind != models.ConversationGroup {
+		return ErrNotGroup
+	}
+	return nil
+}
+
+// AddConversationMembers lets a member of a group invite other users to it.
+func AddConversationMembers(conversationID, inviterID int, userIDs []int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err := requireGroupMember(tx, conversationID, inviterID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := addMembers(tx, conversationID, userIDs, time.Now()); err != nil {
+		tx.Rollback()
+		log.Printf("[conversations.go:AddConversationMembers] Error adding members to %d: %v", conversationID, err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// LeaveConversation removes a user from a group. When the owner leaves, the
+// longest-standing member becomes owner; the group is deleted with its last member.
+func LeaveConversation(conversationID, userID int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err := requireGroupMember(tx, conversationID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM conversation_members WHERE conversation_id = ? AND user_id = ?`, conversationID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var remaining, owners int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(role = ?), 0) FROM conversation_members WHERE conversation_id = ?
+	`, models.MemberOwner, conversationID).Scan(&remaining, &owners); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	switch {
+	case remaining == 0:
+		_, err = tx.Exec(`DELETE FROM conversations WHERE id = ?`, conversationID)
+	case owners == 0:
+		_, err = tx.Exec(`
+			UPDATE conversation_members SET role = ?
+			WHERE conversation_id = ? AND user_id = (
+				SELECT user_id FROM conversation_members
+				WHERE conversation_id = ?
+				ORDER BY joined_at ASC, user_id ASC
+				LIMIT 1
+			)
+		`, models.MemberOwner, conversationID, conversationID)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RenameConversation lets a member of a group change its name.
+func RenameConversation(conversationID, userID int, name string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err := requireGroupMember(tx, conversationID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE conversations SET name = ? WHERE id = ?`, name, conversationID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetConversation returns a conversation as seen by one of its members, with the
+// member list and the viewer's unread count. It returns ErrNoRows when the
+// conversation does not exist and ErrNotMember when the viewer is not in it.
+func GetConversation(conversationID, viewerID int) (*models.Conversation, error) {
+	conv := &models.Conversation{}
+	var createdBy sql.NullInt64
+	err := DB.QueryRow(`
+		SELECT id, kind, name, created_by, created_at FROM conversations WHERE id = ?
+	`, conversationID).Scan(&conv.ID, &conv.Kind, &conv.Name, &createdBy, &conv.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoRows
+	}
+	if err != nil {
+		return nil, err
+	}
+	conv.CreatedBy = int(createdBy.Int64)
+
+	rows, err := DB.Query(`
+		SELECT cm.user_id, u.nickname, cm.role, cm.joined_at, cm.last_read_message_id
+		FROM conversation_members cm
+		JOIN users u ON u.id = cm.user_id
+		WHERE cm.conversation_id = ?
+		ORDER BY cm.joined_at ASC, cm.user_id ASC
+	`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	isMember := false
+	for rows.Next() {
+		member := &models.ConversationMember{}
+		if err := rows.Scan(&member.UserID, &member.Nickname, &member.Role, &member.JoinedAt, &member.LastReadMessageID); err != nil {
+			return nil, err
+		}
+		conv.Members = append(conv.Members, member)
+		if member.UserID == viewerID {
+			isMember = true
+			conv.UnreadCount, err = countUnread(conversationID, viewerID, member.LastReadMessageID)
+			if err != nil {
+				return nil, err
+			}
+		} else if conv.Kind == models.ConversationDirect {
+			conv.UserID = member.UserID
+			conv.Nickname = member.Nickname
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrNotMember
+	}
+	return conv, nil
+}
+
+// countUnread counts the messages of other members after a read position.
+func countUnread(conversationID, userID, lastReadID int) (int, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM private_messages
+		WHERE conversation_id = ? AND sender_id != ? AND id > ?
+	`, conversationID, userID, lastReadID).Scan(&count)
+	return count, err
+}
+
+// GetConversationMemberIDs returns the user IDs of every member of a conversation.
+func GetConversationMemberIDs(conversationID int) ([]int, error) {
+	rows, err := DB.Query(`SELECT user_id FROM conversation_members WHERE conversation_id = ?`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MarkConversationRead moves a member's read position to the newest message of
+// the other members, and updates the read flag and delivery state of those
+// messages. It returns the highest message ID marked, or 0 when nothing was unread.
+func MarkConversationRead(conversationID, userID int) (int, error) {
+	var lastRead int
+	err := DB.QueryRow(`
+		SELECT last_read_message_id FROM conversation_members WHERE conversation_id = ? AND user_id = ?
+	`, conversationID, userID).Scan(&lastRead)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotMember
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var newest sql.NullInt64
+	if err := DB.QueryRow(`
+		SELECT MAX(id) FROM private_messages WHERE conversation_id = ? AND sender_id != ? AND id > ?
+	`, conversationID, userID, lastRead).Scan(&newest); err != nil {
+		return 0, err
+	}
+	if !newest.Valid {
+		return 0, nil
+	}
+	lastID := int(newest.Int64)
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE conversation_members SET last_read_message_id = MAX(last_read_message_id, ?)
+			WHERE conversation_id = ? AND user_id = ?`,
+			[]interface{}{lastID, conversationID, userID}},
+		{`UPDATE private_messages SET is_read = TRUE
+			WHERE conversation_id = ? AND receiver_id = ? AND is_read = FALSE AND id <= ?`,
+			[]interface{}{conversationID, userID, lastID}},
+		{`UPDATE message_deliveries SET status = ?, read_at = ?, delivered_at = COALESCE(delivered_at, ?)
+			WHERE recipient_id = ? AND status != ? AND message_id IN (
+				SELECT id FROM private_messages WHERE conversation_id = ? AND id <= ?
+			)`,
+			[]interface{}{models.DeliveryRead, now, now, userID, models.DeliveryRead, conversationID, lastID}},
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			tx.Rollback()
+			log.Printf("[conversations.go:MarkConversationRead] Error marking conversation %d read for user %d: %v", conversationID, userID, err)
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return lastID, nil
+}
@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCursor is returned when a client sends a cursor that cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor turns a keyset position into an opaque, URL-safe string.
+func EncodeCursor(position interface{}) string {
+	data, err := json.Marshal(position)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a cursor produced by EncodeCursor into position.
+func DecodeCursor(cursor string, position interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, position); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// sqliteTimeFormat matches how the sqlite3 driver stores time.Time values.
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// timeCursor is the keyset position used by lists ordered by (created_at, id).
+// CreatedAt is kept as the raw stored text so comparisons match SQLite exactly.
+type timeCursor struct {
+	CreatedAt string `json:"t"`
+	ID        int    `json:"i"`
+}
+
+// keysetCondition returns a SQL condition that selects the rows after cursor in a list
+// ordered by (timeCol, idCol), plus its arguments. An empty cursor selects every row.
+func keysetCondition(cursor, timeCol, idCol string, descending bool) (string, []interface{}, error) {
+	if cursor == "" {
+		return "1 = 1", nil, nil
+	}
+
+	var position timeCursor
+	if err := DecodeCursor(cursor, &position); err != nil {
+		return "", nil, err
+	}
+
+	op := ">"
+	if descending {
+		op = "<"
+	}
+	condition := fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", timeCol, op, timeCol, idCol, op)
+	return condition, []interface{}{position.CreatedAt, position.CreatedAt, position.ID}, nil
+}
+
+// nextTimeCursor returns the cursor pointing after the given row.
+func nextTimeCursor(rawCreatedAt string, id int) string {
+	return EncodeCursor(timeCursor{CreatedAt: rawCreatedAt, ID: id})
+}
@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	last := 2460000.5
+	tests := []interface{}{
+		timeCursor{CreatedAt: "2026-10-17 03:05:23.123456789+00:00", ID: 42},
+		timeCursor{},
+		userCursor{LastMessage: &last, Nickname: "Zoë & co/?", ID: 7},
+		userCursor{Nickname: "bob", ID: 1},
+	}
+	for _, position := range tests {
+		cursor := EncodeCursor(position)
+		for _, c := range cursor {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				t.Errorf("EncodeCursor(%+v) = %q, not URL-safe", position, cursor)
+				break
+			}
+		}
+
+		decoded := reflect.New(reflect.TypeOf(position))
+		if err := DecodeCursor(cursor, decoded.Interface()); err != nil {
+			t.Fatalf("DecodeCursor(%q): %v", cursor, err)
+		}
+		if got := decoded.Elem().Interface(); !reflect.DeepEqual(got, position) {
+			t.Errorf("round trip of %+v gave %+v", position, got)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []string{
+		"not base64!",
+		"e30=",                                  // padded, cursors are unpadded
+		"bnVs",                                  // base64 of the truncated JSON "nul"
+		EncodeCursor("a string, not an object"), // valid base64 and JSON of the wrong shape
+	}
+	for _, cursor := range tests {
+		var position timeCursor
+		if err := DecodeCursor(cursor, &position); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	cursor := nextTimeCursor("2026-01-02 03:04:05+00:00", 9)
+	tests := []struct {
+		name       string
+		cursor     string
+		descending bool
+		wantCond   string
+		wantArgs   []interface{}
+		wantErr    error
+	}{
+		{"first page", "", true, "1 = 1", nil, nil},
+		{"ascending", cursor, false, "(p.created_at > ? OR (p.created_at = ? AND p.id > ?))",
+			[]interface{}{"2026-01-02 03:04:05+00:00", "2026-01-02 03:04:05+00:00", 9}, nil},
+		{"descending", cursor, true, "(p.created_at < ? OR (p.created_at = ? AND p.id < ?))",
+			[]interface{}{"2026-01-02 03:04:05+00:00", "2026-01-02 03:04:05+00:00", 9}, nil},
+		{"garbage", "%%%", true, "", nil, ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, args, err := keysetCondition(tt.cursor, "p.created_at", "p.id", tt.descending)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if cond != tt.wantCond || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("got %q %v, want %q %v", cond, args, tt.wantCond, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// TestUserDirectoryPages walks the directory with every page size and checks that
+// the pages join up to the full list: no user twice, none missing, across the
+// boundary between conversation partners and everyone else.
+func TestUserDirectoryPages(t *testing.T) {
+	openTestDB(t)
+
+	viewer := createTestUser(t, "viewer")
+	nicknames := []string{"alice", "Bob", "carol", "dave", "Eve", "frank", "grace"}
+	users := map[string]*models.User{}
+	for _, nickname := range nicknames {
+		users[nickname] = createTestUser(t, nickname)
+	}
+	// Conversations, oldest first: the directory lists grace, then carol, then dave
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	sendTestMessage(t, viewer.ID, users["dave"].ID, start)
+	sendTestMessage(t, users["carol"].ID, viewer.ID, start.Add(time.Minute))
+	sendTestMessage(t, viewer.ID, users["grace"].ID, start.Add(2*time.Minute))
+	// Messages between other users do not move them up for the viewer
+	sendTestMessage(t, users["alice"].ID, users["frank"].ID, start.Add(3*time.Minute))
+
+	want := []string{"grace", "carol", "dave", "alice", "Bob", "Eve", "frank"}
+
+	for limit := 1; limit <= len(want)+1; limit++ {
+		var got []string
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > len(want) {
+				t.Fatalf("limit %d: more pages than users", limit)
+			}
+			page, next, err := GetUserDirectory(models.UserListOptions{ViewerID: viewer.ID, Cursor: cursor, Limit: limit})
+			if err != nil {
+				t.Fatalf("limit %d: GetUserDirectory: %v", limit, err)
+			}
+			if len(page) > limit {
+				t.Fatalf("limit %d: page of %d users", limit, len(page))
+			}
+			for _, user := range page {
+				got = append(got, user.Nickname)
+			}
+			if next == "" {
+				break
+			}
+			if len(page) != limit {
+				t.Fatalf("limit %d: short page of %d users with a next cursor", limit, len(page))
+			}
+			cursor = next
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("limit %d: pages joined to %v, want %v", limit, got, want)
+		}
+	}
+
+	page, _, err := GetUserDirectory(models.UserListOptions{ViewerID: viewer.ID, Prefix: "_", Limit: 10})
+	if err != nil || len(page) != 0 {
+		t.Errorf("prefix %q matched %d users (err %v), want none: wildcards must be escaped", "_", len(page), err)
+	}
+	if _, _, err := GetUserDirectory(models.UserListOptions{ViewerID: viewer.ID, Cursor: "!!", Limit: 10}); err != ErrInvalidCursor {
+		t.Errorf("invalid cursor: err = %v, want ErrInvalidCursor", err)
+	}
+}
@@ -10,95 +10,33 @@ import (
 // DB is the global database connection pool.
 var DB *sql.DB
 
-// InitDB initializes the database connection pool.
+// InitDB initializes the database connection pool and applies any pending migrations.
 // It's meant to be called once at application startup.
 func InitDB(dataSourceName string) error {
-	var err error
-	DB, err = sql.Open("sqlite3", dataSourceName)
-	if err != nil {
+	if err := OpenDB(dataSourceName); err != nil {
 		return err
 	}
 
-	// Ping the database to verify the connection is alive.
-	if err = DB.Ping(); err != nil {
+	// Bring the schema up to date. This makes the app self-contained.
+	if err := MigrateUp(); err != nil {
 		return err
 	}
 
-	// Create tables if they don't exist. This makes the app self-contained.
-	schema := `
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			nickname TEXT NOT NULL UNIQUE,
-			email TEXT NOT NULL UNIQUE,
-			password_hash TEXT NOT NULL,
-			first_name TEXT,
-			last_name TEXT,
-			age INTEGER,
-			gender TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			last_login DATETIME,
-			is_online BOOLEAN DEFAULT FALSE
-		);
-
-		CREATE TABLE IF NOT EXISTS sessions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			token TEXT NOT NULL UNIQUE,
-			expiry DATETIME NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
-		);
-
-		CREATE TABLE IF NOT EXISTS categories (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL UNIQUE
-		);
-
-		CREATE TABLE IF NOT EXISTS posts (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			title TEXT NOT NULL,
-			content TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
-		);
-
-		CREATE TABLE IF NOT EXISTS post_categories (
-			post_id INTEGER NOT NULL,
-			category_id INTEGER NOT NULL,
-			PRIMARY KEY (post_id, category_id),
-			FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
-			FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE CASCADE
-		);
-
-		CREATE TABLE IF NOT EXISTS comments (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			post_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			content TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
-			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
-		);
+	log.Println("[db.go:InitDB] Database connected successfully!")
+	return nil
+}
 
-		CREATE TABLE IF NOT EXISTS private_messages (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			sender_id INTEGER NOT NULL,
-			receiver_id INTEGER NOT NULL,
-			content TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			is_read BOOLEAN DEFAULT FALSE,
-			FOREIGN KEY (sender_id) REFERENCES users (id) ON DELETE CASCADE,
-			FOREIGN KEY (receiver_id) REFERENCES users (id) ON DELETE CASCADE
-		);
-	`
-	_, err = DB.Exec(schema)
+// OpenDB opens the database connection pool without touching the schema.
+// The migrate command uses it so it can inspect or roll back the schema itself.
+func OpenDB(dataSourceName string) error {
+	var err error
+	DB, err = sql.Open("sqlite3", dataSourceName)
 	if err != nil {
 		return err
 	}
 
-	log.Println("[db.go:InitDB] Database connected successfully!")
-	return nil
+	// Ping the database to verify the connection is alive.
+	return DB.Ping()
 }
 
 // CloseDB closes the database connection.
@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// openTestDB opens a fresh, fully migrated database in a temporary directory as DB.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "forum.db") + "?_foreign_keys=on"
+	if err := InitDB(dsn); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(CloseDB)
+}
+
+// createTestUser inserts a user with the given nickname and returns it.
+func createTestUser(t *testing.T, nickname string) *models.User {
+	t.Helper()
+	user := &models.User{
+		Nickname:     nickname,
+		Email:        nickname + "@example.com",
+		PasswordHash: "x",
+		FirstName:    "Test",
+		LastName:     "User",
+		Age:          30,
+		Gender:       "other",
+	}
+	if err := CreateUser(user); err != nil {
+		t.Fatalf("CreateUser(%s): %v", nickname, err)
+	}
+	return user
+}
+
+// sendTestMessage stores a direct message from sender to receiver, at distinct times
+// so conversations order predictably.
+func sendTestMessage(t *testing.T, senderID, receiverID int, at time.Time) {
+	t.Helper()
+	message := &models.PrivateMessage{
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+		Content:    fmt.Sprintf("hello from %d", senderID),
+		CreatedAt:  at,
+	}
+	if _, err := CreatePrivateMessage(message); err != nil {
+		t.Fatalf("CreatePrivateMessage %d -> %d: %v", senderID, receiverID, err)
+	}
+}
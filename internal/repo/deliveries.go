@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// GetQueuedMessages returns every private message still waiting to be pushed to
+// the recipient, oldest first, with the sender's nickname filled in.
+func GetQueuedMessages(recipientID int) ([]models.PrivateMessage, error) {
+	query := `
+		SELECT pm.id, pm.conversation_id, pm.sender_id, COALESCE(pm.receiver_id, 0),
+			CASE WHEN pm.hidden_at IS NULL THEN pm.content ELSE '' END, pm.created_at, pm.is_read, pm.hidden_at IS NOT NULL, u.nickname
+		FROM message_deliveries md
+		JOIN private_messages pm ON pm.id = md.message_id
+		JOIN users u ON u.id = pm.sender_id
+		WHERE md.recipient_id = ? AND md.status = ?
+		ORDER BY pm.created_at ASC, pm.id ASC
+	`
+	rows, err := DB.Query(query, recipientID, models.DeliveryQueued)
+	if err != nil {
+		log.Printf("[deliveries.go:GetQueuedMessages] Error querying queued messages: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.PrivateMessage
+	for rows.Next() {
+		var msg models.PrivateMessage
+		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt, &msg.IsRead, &msg.Hidden, &msg.SenderNickname); err != nil {
+			log.Printf("[deliveries.go:GetQueuedMessages] Error scanning queued message: %v", err)
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// MarkMessagesDelivered moves the given queued messages of a recipient to the delivered state.
+// Messages that are already delivered or read are left untouched.
+func MarkMessagesDelivered(recipientID int, messageIDs []int) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(messageIDs)), ",")
+	args := make([]interface{}, 0, len(messageIDs)+3)
+	args = append(args, models.DeliveryDelivered, time.Now(), recipientID)
+	for _, id := range messageIDs {
+		args = append(args, id)
+	}
+
+	query := `
+		UPDATE message_deliveries
+		SET status = ?, delivered_at = ?
+		WHERE status = 'queued' AND recipient_id = ? AND message_id IN (` + placeholders + `)
+	`
+	if _, err := DB.Exec(query, args...); err != nil {
+		log.Printf("[deliveries.go:MarkMessagesDelivered] Error marking messages delivered: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"log"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// RecordLoginEvent stores a sign-in attempt. UserID 0 means the identifier matched no account.
+func RecordLoginEvent(event *models.LoginEvent) error {
+	var userID interface{}
+	if event.UserID != 0 {
+		userID = event.UserID
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	res, err := DB.Exec(`
+		INSERT INTO login_events (user_id, identifier, ip, user_agent, outcome, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, event.Identifier, event.IP, event.UserAgent, event.Outcome, event.CreatedAt)
+	if err != nil {
+		log.Printf("[logins.go:RecordLoginEvent] Error recording login event: %v", err)
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	event.ID = int(id)
+	return nil
+}
+
+// CountIdentifierFailures returns the number of failed attempts on an identifier
+// since the given time and since its last successful sign-in, with the time of
+// the latest one (zero when there were none).
+func CountIdentifierFailures(identifier string, since time.Time) (int, time.Time, error) {
+	return countFailures(`
+		identifier = ? AND created_at > ? AND created_at > COALESCE(
+			(SELECT MAX(created_at) FROM login_events WHERE identifier = ? AND outcome = ?), '')
+	`, identifier, since, identifier, models.LoginSuccess)
+}
+
+// CountIPFailures returns the number of failed attempts from an IP since the
+// given time, with the time of the latest one.
+func CountIPFailures(ip string, since time.Time) (int, time.Time, error) {
+	return countFailures(`ip = ? AND created_at > ?`, ip, since)
+}
+
+// countFailures counts failed attempts matching the filter and finds the latest one.
+func countFailures(filter string, args ...interface{}) (int, time.Time, error) {
+	where := `outcome = '` + models.LoginFailure + `' AND ` + filter
+
+	var count int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM login_events WHERE `+where, args...).Scan(&count); err != nil {
+		log.Printf("[logins.go:countFailures] Error counting failed logins: %v", err)
+		return 0, time.Time{}, err
+	}
+	if count == 0 {
+		return 0, time.Time{}, nil
+	}
+
+	var last time.Time
+	err := DB.QueryRow(`SELECT created_at FROM login_events WHERE `+where+` ORDER BY created_at DESC LIMIT 1`, args...).Scan(&last)
+	if err != nil {
+		log.Printf("[logins.go:countFailures] Error finding last failed login: %v", err)
+		return 0, time.Time{}, err
+	}
+	return count, last, nil
+}
+
+// GetLoginEvents returns the most recent sign-in attempts on a user's account, newest first.
+func GetLoginEvents(userID, limit int) ([]*models.LoginEvent, error) {
+	rows, err := DB.Query(`
+		SELECT id, ip, user_agent, outcome, created_at
+		FROM login_events
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, userID, limit)
+	if err != nil {
+		log.Printf("[logins.go:GetLoginEvents] Error querying login events: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*models.LoginEvent{}
+	for rows.Next() {
+		event := &models.LoginEvent{UserID: userID}
+		if err := rows.Scan(&event.ID, &event.IP, &event.UserAgent, &event.Outcome, &event.CreatedAt); err != nil {
+			log.Printf("[logins.go:GetLoginEvents] Error scanning login event: %v", err)
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
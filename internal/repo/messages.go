@@ -1,50 +1,169 @@
 package repo
 
 import (
+	"database/sql"
 	"log"
 
 	"real-time-forum/internal/models"
 )
 
-// CreatePrivateMessage inserts a new private message into the database
-func CreatePrivateMessage(message *models.PrivateMessage) error {
-	query := `
-		INSERT INTO private_messages (sender_id, receiver_id, content, created_at, is_read)
-		VALUES (?, ?, ?, ?, ?)
-	`
-	_, err := DB.Exec(query, message.SenderID, message.ReceiverID, message.Content, message.CreatedAt, message.IsRead)
+// CreatePrivateMessage inserts a new message into its conversation and queues it
+// for delivery to every other member. Direct messages (ReceiverID set, no
+// ConversationID) go to the pair's direct conversation, which is created on the
+// first message. It uses a transaction so a message never exists without its
+// delivery state, and returns ErrNotMember if the sender is not in the conversation
+// and ErrBlocked if either side of a direct conversation blocked the other.
+// It returns the new message ID and also stores it, and the conversation ID, on the passed message.
+func CreatePrivateMessage(message *models.PrivateMessage) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	if message.ConversationID == 0 {
+		convID, err := directConversation(tx, message.SenderID, message.ReceiverID, message.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("[messages.go:CreatePrivateMessage] Error finding direct conversation: %v", err)
+			return 0, err
+		}
+		message.ConversationID = convID
+	}
+
+	kind, recipients, err := conversationRecipients(tx, message.ConversationID, message.SenderID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Direct messages keep their receiver so pair lookups keep working; group messages have none
+	var receiver interface{}
+	if kind == models.ConversationDirect {
+		if len(recipients) == 1 {
+			message.ReceiverID = recipients[0]
+		}
+		receiver = message.ReceiverID
+
+		// A block on either side stops direct messages; groups are not affected
+		blocked, err := isBlockedBetween(tx, message.SenderID, message.ReceiverID)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("[messages.go:CreatePrivateMessage] Error checking blocks: %v", err)
+			return 0, err
+		}
+		if blocked {
+			tx.Rollback()
+			return 0, ErrBlocked
+		}
+	} else {
+		message.ReceiverID = 0
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO private_messages (conversation_id, sender_id, receiver_id, content, created_at, is_read)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, message.ConversationID, message.SenderID, receiver, message.Content, message.CreatedAt, message.IsRead)
 	if err != nil {
+		tx.Rollback()
 		log.Printf("[messages.go:CreatePrivateMessage] Error creating private message: %v", err)
-		return err
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("[messages.go:CreatePrivateMessage] Error reading new message ID: %v", err)
+		return 0, err
+	}
+
+	for _, recipientID := range recipients {
+		_, err = tx.Exec(`
+			INSERT INTO message_deliveries (message_id, recipient_id, status, queued_at)
+			VALUES (?, ?, ?, ?)
+		`, id, recipientID, models.DeliveryQueued, message.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("[messages.go:CreatePrivateMessage] Error queueing message %d for user %d: %v", id, recipientID, err)
+			return 0, err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE conversations SET last_message_at = ? WHERE id = ?`, message.CreatedAt, message.ConversationID); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
 	}
-	return nil
+	message.ID = int(id)
+	return id, nil
+}
+
+// GetPrivateMessagesBetweenUsers retrieves one page of the conversation between two users.
+// Pages go backwards in time from the cursor (newest page first), but each page is
+// returned in chronological order. The returned cursor points at older messages,
+// or is "" when the start of the conversation has been reached.
+func GetPrivateMessagesBetweenUsers(userID1, userID2 int, cursor string, limit int) ([]models.PrivateMessage, string, error) {
+	return getMessagePage(
+		"((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))",
+		[]interface{}{userID1, userID2, userID2, userID1},
+		cursor, limit,
+	)
+}
+
+// GetConversationMessages retrieves one page of a conversation, paged like
+// GetPrivateMessagesBetweenUsers. Membership is checked by the caller.
+func GetConversationMessages(conversationID int, cursor string, limit int) ([]models.PrivateMessage, string, error) {
+	return getMessagePage("conversation_id = ?", []interface{}{conversationID}, cursor, limit)
 }
 
-// GetPrivateMessagesBetweenUsers retrieves private messages between two users
-func GetPrivateMessagesBetweenUsers(userID1, userID2 int, limit, offset int) ([]models.PrivateMessage, error) {
+// getMessagePage runs a keyset-paginated message query for the given filter.
+func getMessagePage(filter string, filterArgs []interface{}, cursor string, limit int) ([]models.PrivateMessage, string, error) {
+	before, beforeArgs, err := keysetCondition(cursor, "created_at", "id", true)
+	if err != nil {
+		return nil, "", err
+	}
+
 	query := `
-		SELECT id, sender_id, receiver_id, content, created_at, is_read
+		SELECT id, conversation_id, sender_id, COALESCE(receiver_id, 0),
+			CASE WHEN hidden_at IS NULL THEN content ELSE '' END, created_at, CAST(created_at AS TEXT), is_read, hidden_at IS NOT NULL
 		FROM private_messages
-		WHERE (sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?
+		WHERE ` + filter + ` AND ` + before + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
 	`
-	rows, err := DB.Query(query, userID1, userID2, userID2, userID1, limit, offset)
+	args := append(filterArgs, beforeArgs...)
+	args = append(args, limit+1)
+
+	rows, err := DB.Query(query, args...)
 	if err != nil {
-		log.Printf("[messages.go:GetPrivateMessagesBetweenUsers] Error querying private messages: %v", err)
-		return nil, err
+		log.Printf("[messages.go:getMessagePage] Error querying private messages: %v", err)
+		return nil, "", err
 	}
 	defer rows.Close()
 
 	var messages []models.PrivateMessage
+	var rawTimes []string
 	for rows.Next() {
 		var msg models.PrivateMessage
-		err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt, &msg.IsRead)
+		var rawCreatedAt string
+		err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt, &rawCreatedAt, &msg.IsRead, &msg.Hidden)
 		if err != nil {
-			log.Printf("[messages.go:GetPrivateMessagesBetweenUsers] Error scanning private message: %v", err)
-			return nil, err
+			log.Printf("[messages.go:getMessagePage] Error scanning private message: %v", err)
+			return nil, "", err
 		}
 		messages = append(messages, msg)
+		rawTimes = append(rawTimes, rawCreatedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(messages) > limit {
+		messages = messages[:limit]
+		nextCursor = nextTimeCursor(rawTimes[limit-1], messages[limit-1].ID)
 	}
 
 	// Reverse to get chronological order (oldest first)
@@ -52,33 +171,44 @@ func GetPrivateMessagesBetweenUsers(userID1, userID2 int, limit, offset int) ([]
 		messages[i], messages[j] = messages[j], messages[i]
 	}
 
-	return messages, nil
+	return messages, nextCursor, nil
 }
-  
+
 // MarkMessagesAsRead marks messages from sender to receiver as read
-func MarkMessagesAsRead(senderID, receiverID int) error {
-	query := `
-		UPDATE private_messages
-		SET is_read = TRUE
-		WHERE sender_id = ? AND receiver_id = ? AND is_read = FALSE
-	`
-	_, err := DB.Exec(query, senderID, receiverID)
+// and moves their delivery state to read. It returns the highest message ID
+// that was marked, or 0 when there was nothing unread.
+func MarkMessagesAsRead(senderID, receiverID int) (int, error) {
+	var conversationID int
+	err := DB.QueryRow(`SELECT id FROM conversations WHERE direct_key = ?`, directConversationKey(senderID, receiverID)).Scan(&conversationID)
+	if err == sql.ErrNoRows {
+		return 0, nil // They never talked
+	}
+	if err != nil {
+		log.Printf("[messages.go:MarkMessagesAsRead] Error finding conversation: %v", err)
+		return 0, err
+	}
+
+	lastID, err := MarkConversationRead(conversationID, receiverID)
+	if err == ErrNotMember {
+		return 0, nil
+	}
 	if err != nil {
 		log.Printf("[messages.go:MarkMessagesAsRead] Error marking messages as read: %v", err)
-		return err
+		return 0, err
 	}
-	return nil
+	return lastID, nil
 }
 
-// GetUnreadMessageCount returns the count of unread messages for a user
+// GetUnreadMessageCount returns the count of unread messages for a user across all conversations
 func GetUnreadMessageCount(userID int) (int, error) {
 	query := `
 		SELECT COUNT(*)
-		FROM private_messages
-		WHERE receiver_id = ? AND is_read = FALSE
+		FROM conversation_members cm
+		JOIN private_messages pm ON pm.conversation_id = cm.conversation_id
+		WHERE cm.user_id = ? AND pm.sender_id != ? AND pm.id > cm.last_read_message_id
 	`
 	var count int
-	err := DB.QueryRow(query, userID).Scan(&count)
+	err := DB.QueryRow(query, userID, userID).Scan(&count)
 	if err != nil {
 		log.Printf("[messages.go:GetUnreadMessageCount] Error getting unread message count: %v", err)
 		return 0, err
@@ -86,61 +216,53 @@ func GetUnreadMessageCount(userID int) (int, error) {
 	return count, nil
 }
 
-// GetRecentConversations returns recent conversation partners for a user
-func GetRecentConversations(userID int, limit int) ([]map[string]interface{}, error) {
+// GetRecentConversations returns the direct and group conversations of a user,
+// most recently active first, with the last message and the user's unread count.
+// Direct conversations also say whether the user blocked or muted the other member.
+func GetRecentConversations(userID int, limit int) ([]*models.Conversation, error) {
 	query := `
-		SELECT DISTINCT
-			CASE
-				WHEN pm.sender_id = ? THEN pm.receiver_id
-				ELSE pm.sender_id
-			END as other_user_id,
-			u.nickname,
-			pm.content as last_message,
-			pm.created_at as last_message_time,
-			(SELECT COUNT(*) FROM private_messages WHERE receiver_id = ? AND sender_id =
-				CASE
-					WHEN pm.sender_id = ? THEN pm.receiver_id
-					ELSE pm.sender_id
-				END AND is_read = FALSE) as unread_count
-		FROM private_messages pm
-		JOIN users u ON u.id = CASE
-			WHEN pm.sender_id = ? THEN pm.receiver_id
-			ELSE pm.sender_id
-		END
-		WHERE pm.sender_id = ? OR pm.receiver_id = ?
-		ORDER BY pm.created_at DESC
+		SELECT c.id, c.kind, c.name, COALESCE(c.created_by, 0), c.created_at,
+			COALESCE(partner.id, 0), COALESCE(partner.nickname, ''),
+			CASE WHEN last.hidden_at IS NULL THEN COALESCE(last.content, '') ELSE '' END, last.created_at,
+			(SELECT COUNT(*) FROM private_messages m
+				WHERE m.conversation_id = c.id AND m.sender_id != cm.user_id AND m.id > cm.last_read_message_id) AS unread_count,
+			EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = cm.user_id AND blocked_id = partner.id),
+			EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = cm.user_id AND muted_id = partner.id)
+		FROM conversation_members cm
+		JOIN conversations c ON c.id = cm.conversation_id
+		LEFT JOIN conversation_members other
+			ON c.kind = 'direct' AND other.conversation_id = c.id AND other.user_id != cm.user_id
+		LEFT JOIN users partner ON partner.id = other.user_id
+		LEFT JOIN private_messages last
+			ON last.id = (SELECT MAX(id) FROM private_messages WHERE conversation_id = c.id)
+		WHERE cm.user_id = ?
+		ORDER BY COALESCE(c.last_message_at, c.created_at) DESC, c.id DESC
 		LIMIT ?
 	`
 
-	rows, err := DB.Query(query, userID, userID, userID, userID, userID, userID, limit)
+	rows, err := DB.Query(query, userID, limit)
 	if err != nil {
 		log.Printf("[messages.go:GetRecentConversations] Error getting recent conversations: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
 
-	var conversations []map[string]interface{}
+	conversations := []*models.Conversation{}
 	for rows.Next() {
-		var otherUserID int
-		var nickname, lastMessage string
-		var lastMessageTime string
-		var unreadCount int
-
-		err := rows.Scan(&otherUserID, &nickname, &lastMessage, &lastMessageTime, &unreadCount)
+		conv := &models.Conversation{}
+		var lastMessageTime sql.NullTime
+		err := rows.Scan(&conv.ID, &conv.Kind, &conv.Name, &conv.CreatedBy, &conv.CreatedAt,
+			&conv.UserID, &conv.Nickname, &conv.LastMessage, &lastMessageTime, &conv.UnreadCount,
+			&conv.Blocked, &conv.Muted)
 		if err != nil {
 			log.Printf("[messages.go:GetRecentConversations] Error scanning conversation: %v", err)
-			continue
+			return nil, err
 		}
-
-		conversation := map[string]interface{}{
-			"user_id":           otherUserID,
-			"nickname":          nickname,
-			"last_message":      lastMessage,
-			"last_message_time": lastMessageTime,
-			"unread_count":      unreadCount,
+		if lastMessageTime.Valid {
+			conv.LastMessageTime = &lastMessageTime.Time
 		}
-		conversations = append(conversations, conversation)
+		conversations = append(conversations, conv)
 	}
 
-	return conversations, nil
+	return conversations, rows.Err()
 }
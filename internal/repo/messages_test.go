@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+func TestCreatePrivateMessageBlocks(t *testing.T) {
+	openTestDB(t)
+
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	carol := createTestUser(t, "carol")
+	group, err := CreateGroupConversation(alice.ID, "friends", []int{bob.ID, carol.ID})
+	if err != nil {
+		t.Fatalf("CreateGroupConversation: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		block   [2]int // blocker, blocked; zero for no block
+		message models.PrivateMessage
+		wantErr error
+	}{
+		{"no block", [2]int{}, models.PrivateMessage{SenderID: alice.ID, ReceiverID: bob.ID}, nil},
+		{"sender blocked receiver", [2]int{alice.ID, bob.ID}, models.PrivateMessage{SenderID: alice.ID, ReceiverID: bob.ID}, ErrBlocked},
+		{"receiver blocked sender", [2]int{bob.ID, alice.ID}, models.PrivateMessage{SenderID: alice.ID, ReceiverID: bob.ID}, ErrBlocked},
+		{"block by the conversation", [2]int{bob.ID, alice.ID}, models.PrivateMessage{SenderID: alice.ID, ConversationID: -1}, ErrBlocked},
+		{"unrelated block", [2]int{carol.ID, alice.ID}, models.PrivateMessage{SenderID: alice.ID, ReceiverID: bob.ID}, nil},
+		{"groups ignore blocks", [2]int{bob.ID, alice.ID}, models.PrivateMessage{SenderID: alice.ID, ConversationID: group}, nil},
+		{"not a member", [2]int{}, models.PrivateMessage{SenderID: carol.ID, ConversationID: -1}, ErrNotMember},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DB.Exec(`DELETE FROM user_blocks`); err != nil {
+				t.Fatalf("clearing blocks: %v", err)
+			}
+			if tt.block[0] != 0 {
+				if err := AddUserRelation(models.RelationBlock, tt.block[0], tt.block[1]); err != nil {
+					t.Fatalf("AddUserRelation: %v", err)
+				}
+			}
+
+			message := tt.message
+			if message.ConversationID == -1 {
+				// The direct conversation of alice and bob, created by the first test
+				if err := DB.QueryRow(`SELECT id FROM conversations WHERE direct_key = ?`,
+					directConversationKey(alice.ID, bob.ID)).Scan(&message.ConversationID); err != nil {
+					t.Fatalf("finding the direct conversation: %v", err)
+				}
+			}
+			message.Content = tt.name
+			message.CreatedAt = time.Now()
+
+			var before int
+			DB.QueryRow(`SELECT COUNT(*) FROM private_messages`).Scan(&before)
+
+			id, err := CreatePrivateMessage(&message)
+			if err != tt.wantErr {
+				t.Fatalf("CreatePrivateMessage: err = %v, want %v", err, tt.wantErr)
+			}
+
+			var after int
+			DB.QueryRow(`SELECT COUNT(*) FROM private_messages`).Scan(&after)
+			switch {
+			case tt.wantErr != nil && after != before:
+				t.Errorf("refused message was stored: %d messages, had %d", after, before)
+			case tt.wantErr == nil && (id == 0 || after != before+1):
+				t.Errorf("message not stored: id %d, %d messages, had %d", id, after, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the numbered up/down SQL migrations compiled into the binary.
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+// An up script may start with a `-- requires: <feature>` line, in which case it is
+// skipped until the SQLite build provides that feature (see migrationFeatures).
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is a single numbered schema change.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of the up script, used to detect edited migrations
+	Requires string // optional SQLite feature the migration needs, e.g. "fts5"
+}
+
+// MigrationStatus describes whether a migration has been applied to the database.
+type MigrationStatus struct {
+	Migration
+	Applied     bool
+	AppliedAt   *time.Time
+	Unavailable bool // the required SQLite feature is missing from this build
+}
+
+// migrationFeatures maps a `-- requires:` name to a check against the running SQLite library.
+var migrationFeatures = map[string]func() (bool, error){
+	"fts5": FullTextSearchAvailable,
+}
+
+// FullTextSearchAvailable reports whether the SQLite library was compiled with FTS5.
+// With mattn/go-sqlite3 this needs the `sqlite_fts5` build tag.
+func FullTextSearchAvailable() (bool, error) {
+	var used int
+	if err := DB.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used); err != nil {
+		return false, err
+	}
+	return used == 1, nil
+}
+
+// parseRequires returns the feature named in a leading `-- requires:` comment, if any.
+func parseRequires(script string) string {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		if feature, ok := strings.CutPrefix(strings.TrimSpace(strings.TrimPrefix(line, "--")), "requires:"); ok {
+			return strings.TrimSpace(feature)
+		}
+	}
+	return ""
+}
+
+// loadMigrations reads and pairs the embedded migration files, sorted by version.
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionStr, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>", fileName)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", fileName, err)
+		}
+
+		content, err := migrationFiles.ReadFile("migrations/" + fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d: conflicting names %q and %q", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+			m.Requires = parseRequires(m.Up)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up script", m.Version, m.Name)
+		}
+		if m.Requires != "" && migrationFeatures[m.Requires] == nil {
+			return nil, fmt.Errorf("migration %d (%s) requires unknown feature %q", m.Version, m.Name, m.Requires)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureMigrationsTable creates the schema_migrations bookkeeping table.
+func ensureMigrationsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`)
+	return err
+}
+
+// appliedMigrations returns the applied migrations keyed by version.
+func appliedMigrations() (map[int]MigrationStatus, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query("SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationStatus)
+	for rows.Next() {
+		var s MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&s.Version, &s.Name, &s.Checksum, &appliedAt); err != nil {
+			return nil, err
+		}
+		s.Applied = true
+		s.AppliedAt = &appliedAt
+		applied[s.Version] = s
+	}
+	return applied, rows.Err()
+}
+
+// GetMigrationStatus lists every known migration and whether it has been applied.
+// It fails if an applied migration no longer matches the embedded script.
+func GetMigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]bool, len(migrations))
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+		s := MigrationStatus{Migration: m}
+		if m.Requires != "" {
+			available, err := migrationFeatures[m.Requires]()
+			if err != nil {
+				return nil, err
+			}
+			s.Unavailable = !available
+		}
+		if a, ok := applied[m.Version]; ok {
+			if a.Checksum != m.Checksum {
+				return nil, fmt.Errorf("migration %d (%s) was modified after it was applied", m.Version, m.Name)
+			}
+			s.Applied = true
+			s.AppliedAt = a.AppliedAt
+		}
+		statuses = append(statuses, s)
+	}
+
+	for version, a := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("database has migration %d (%s) that this binary does not know", version, a.Name)
+		}
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies every pending migration in order, each in its own transaction.
+// Migrations whose required SQLite feature is missing are skipped with a warning and
+// applied by the first binary that supports them.
+func MigrateUp() error {
+	statuses, err := GetMigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		if s.Applied && s.Unavailable {
+			// The schema already depends on the feature (e.g. FTS5 triggers on posts),
+			// so this binary would fail on ordinary writes.
+			return fmt.Errorf("migration %d (%s) is applied but this build lacks %s; rebuild with -tags sqlite_%s", s.Version, s.Name, s.Requires, s.Requires)
+		}
+		if s.Applied {
+			continue
+		}
+		if s.Unavailable {
+			log.Printf("[migrate.go:MigrateUp] Skipping migration %04d_%s: SQLite build lacks %s", s.Version, s.Name, s.Requires)
+			continue
+		}
+		if err := runMigration(s.Migration, s.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec(
+				"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				s.Version, s.Name, s.Checksum, time.Now(),
+			)
+			return err
+		}); err != nil {
+			return err
+		}
+		log.Printf("[migrate.go:MigrateUp] Applied migration %04d_%s", s.Version, s.Name)
+	}
+	return nil
+}
+
+// MigrateDown rolls back applied migrations, newest first, until the schema is at target version.
+// A target of 0 rolls back everything.
+func MigrateDown(target int) error {
+	statuses, err := GetMigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		s := statuses[i]
+		if !s.Applied || s.Version <= target {
+			continue
+		}
+		if s.Down == "" {
+			return fmt.Errorf("migration %d (%s) has no down script", s.Version, s.Name)
+		}
+		if err := runMigration(s.Migration, s.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", s.Version)
+			return err
+		}); err != nil {
+			return err
+		}
+		log.Printf("[migrate.go:MigrateDown] Rolled back migration %04d_%s", s.Version, s.Name)
+	}
+	return nil
+}
+
+// CurrentSchemaVersion returns the highest applied migration version, or 0 for an empty database.
+func CurrentSchemaVersion() (int, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := DB.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// runMigration executes a migration script and its bookkeeping in one transaction.
+func runMigration(m Migration, script string, record func(*sql.Tx) error) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(script); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+	}
+	if err := record(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration %d (%s): recording state: %w", m.Version, m.Name, err)
+	}
+	return tx.Commit()
+}
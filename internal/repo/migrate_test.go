@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// schemaSnapshot returns the SQL of every table, index, trigger and view, in a stable order.
+func schemaSnapshot(t *testing.T) string {
+	t.Helper()
+	rows, err := DB.Query(`
+		SELECT type, name, COALESCE(sql, '') FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'
+		ORDER BY type, name`)
+	if err != nil {
+		t.Fatalf("reading schema: %v", err)
+	}
+	defer rows.Close()
+
+	var b strings.Builder
+	for rows.Next() {
+		var kind, name, sql string
+		if err := rows.Scan(&kind, &name, &sql); err != nil {
+			t.Fatalf("reading schema: %v", err)
+		}
+		b.WriteString(kind + " " + name + ": " + sql + "\n")
+	}
+	return b.String()
+}
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "forum.db") + "?_foreign_keys=on"
+	if err := OpenDB(dsn); err != nil {
+		t.Fatalf("OpenDB: %v", err)
+	}
+	t.Cleanup(CloseDB)
+
+	statuses, err := GetMigrationStatus()
+	if err != nil {
+		t.Fatalf("GetMigrationStatus: %v", err)
+	}
+	// Migrations this SQLite build cannot run are skipped, and so is their version
+	latest := 0
+	migrations := make([]Migration, len(statuses))
+	for i, s := range statuses {
+		migrations[i] = s.Migration
+		if !s.Unavailable {
+			latest = s.Version
+		}
+	}
+
+	if err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if version, _ := CurrentSchemaVersion(); version != latest {
+		t.Fatalf("version after MigrateUp = %d, want %d", version, latest)
+	}
+	full := schemaSnapshot(t)
+
+	// Each migration rolls back to exactly the schema before it and applies again
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		before := 0
+		if i > 0 {
+			before = migrations[i-1].Version
+		}
+		t.Run(m.Name, func(t *testing.T) {
+			if err := MigrateDown(before); err != nil {
+				t.Fatalf("MigrateDown(%d): %v", before, err)
+			}
+			down := schemaSnapshot(t)
+			if err := MigrateUp(); err != nil {
+				t.Fatalf("MigrateUp from %d: %v", before, err)
+			}
+			if got := schemaSnapshot(t); got != full {
+				t.Errorf("schema after down to %d and up again differs:\n%s\nwant:\n%s", before, got, full)
+			}
+			if err := MigrateDown(before); err != nil {
+				t.Fatalf("second MigrateDown(%d): %v", before, err)
+			}
+			if got := schemaSnapshot(t); got != down {
+				t.Errorf("rolling back to %d twice gives different schemas:\n%s\nwant:\n%s", before, got, down)
+			}
+		})
+		if t.Failed() {
+			return
+		}
+	}
+
+	if got := schemaSnapshot(t); got != "" {
+		t.Errorf("schema left after rolling back everything:\n%s", got)
+	}
+	if version, _ := CurrentSchemaVersion(); version != 0 {
+		t.Errorf("version after MigrateDown(0) = %d, want 0", version)
+	}
+}
+
+func TestMigrationChecksumMismatch(t *testing.T) {
+	openTestDB(t)
+
+	if _, err := DB.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`); err != nil {
+		t.Fatalf("editing checksum: %v", err)
+	}
+	if _, err := GetMigrationStatus(); err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Errorf("GetMigrationStatus with an edited migration: err = %v, want a modified error", err)
+	}
+	if err := MigrateUp(); err == nil {
+		t.Error("MigrateUp with an edited migration succeeded")
+	}
+}
+
+func TestParseRequires(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"-- requires: fts5\nCREATE VIRTUAL TABLE x USING fts5(a);", "fts5"},
+		{"-- Search index\n-- requires:fts5\nCREATE TABLE x (a);", "fts5"},
+		{"CREATE TABLE x (a);\n-- requires: fts5", ""},
+		{"-- just a comment\nCREATE TABLE x (a);", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := parseRequires(tt.script); got != tt.want {
+			t.Errorf("parseRequires(%q) = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
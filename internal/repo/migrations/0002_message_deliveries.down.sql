@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_message_deliveries_recipient_status;
+DROP TABLE IF EXISTS message_deliveries;
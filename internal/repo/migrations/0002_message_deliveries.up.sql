@@ -0,0 +1,15 @@
+-- Per-message delivery state for the offline queue.
+CREATE TABLE IF NOT EXISTS message_deliveries (
+	message_id INTEGER PRIMARY KEY,
+	recipient_id INTEGER NOT NULL,
+	status TEXT NOT NULL DEFAULT 'queued',
+	queued_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	delivered_at DATETIME,
+	read_at DATETIME,
+	FOREIGN KEY (message_id) REFERENCES private_messages (id) ON DELETE CASCADE,
+	FOREIGN KEY (recipient_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_deliveries_recipient_status
+	ON message_deliveries (recipient_id, status);
+
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_private_messages_pair_created_at;
+DROP INDEX IF EXISTS idx_comments_post_created_at;
+DROP INDEX IF EXISTS idx_posts_created_at;
@@ -0,0 +1,4 @@
+-- Indexes backing keyset pagination on (created_at, id).
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_comments_post_created_at ON comments (post_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_private_messages_pair_created_at ON private_messages (sender_id, receiver_id, created_at, id);
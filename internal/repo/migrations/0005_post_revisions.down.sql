@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS post_revisions;
+ALTER TABLE posts DROP COLUMN deleted_at;
+ALTER TABLE posts DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN role;
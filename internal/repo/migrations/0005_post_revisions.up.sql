@@ -0,0 +1,18 @@
+-- Post editing: roles for moderation, edit/soft-delete timestamps and revision history.
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+
+ALTER TABLE posts ADD COLUMN updated_at DATETIME;
+ALTER TABLE posts ADD COLUMN deleted_at DATETIME;
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER NOT NULL,
+	revision INTEGER NOT NULL,
+	editor_id INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (post_id, revision),
+	FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+	FOREIGN KEY (editor_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+-- Threaded comments: each reply points at its parent and stores its materialized path
+-- (zero-padded comment IDs from the root, separated by '/') so a thread sorts by path.
+ALTER TABLE comments ADD COLUMN parent_id INTEGER REFERENCES comments (id) ON DELETE CASCADE;
+ALTER TABLE comments ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN path TEXT NOT NULL DEFAULT '';
+
+UPDATE comments SET path = printf('%010d', id) WHERE path = '';
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments (parent_id);
+CREATE INDEX IF NOT EXISTS idx_comments_post_path ON comments (post_id, path);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_reactions_target;
+DROP TABLE IF EXISTS reactions;
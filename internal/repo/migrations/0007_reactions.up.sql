@@ -0,0 +1,12 @@
+-- Reactions and up/down votes on posts and comments.
+CREATE TABLE IF NOT EXISTS reactions (
+	user_id INTEGER NOT NULL,
+	target_type TEXT NOT NULL,
+	target_id INTEGER NOT NULL,
+	reaction TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, target_type, target_id, reaction),
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reactions_target ON reactions (target_type, target_id);
@@ -0,0 +1,52 @@
+-- Group messages have no receiver and cannot be represented without conversations; they are dropped.
+CREATE TABLE message_deliveries_backup AS
+SELECT md.* FROM message_deliveries md
+JOIN private_messages pm ON pm.id = md.message_id
+WHERE pm.receiver_id IS NOT NULL;
+DROP INDEX IF EXISTS idx_message_deliveries_recipient_status;
+DROP TABLE message_deliveries;
+
+CREATE TABLE private_messages_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sender_id INTEGER NOT NULL,
+	receiver_id INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	is_read BOOLEAN DEFAULT FALSE,
+	FOREIGN KEY (sender_id) REFERENCES users (id) ON DELETE CASCADE,
+	FOREIGN KEY (receiver_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+INSERT INTO private_messages_old (id, sender_id, receiver_id, content, created_at, is_read)
+SELECT id, sender_id, receiver_id, content, created_at, is_read
+FROM private_messages
+WHERE receiver_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_private_messages_conversation;
+DROP INDEX IF EXISTS idx_private_messages_pair_created_at;
+DROP TABLE private_messages;
+ALTER TABLE private_messages_old RENAME TO private_messages;
+
+CREATE INDEX IF NOT EXISTS idx_private_messages_pair_created_at ON private_messages (sender_id, receiver_id, created_at, id);
+
+CREATE TABLE message_deliveries (
+	message_id INTEGER PRIMARY KEY,
+	recipient_id INTEGER NOT NULL,
+	status TEXT NOT NULL DEFAULT 'queued',
+	queued_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	delivered_at DATETIME,
+	read_at DATETIME,
+	FOREIGN KEY (message_id) REFERENCES private_messages (id) ON DELETE CASCADE,
+	FOREIGN KEY (recipient_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+INSERT INTO message_deliveries (message_id, recipient_id, status, queued_at, delivered_at, read_at)
+SELECT message_id, recipient_id, status, queued_at, delivered_at, read_at FROM message_deliveries_backup;
+DROP TABLE message_deliveries_backup;
+
+CREATE INDEX IF NOT EXISTS idx_message_deliveries_recipient_status
+	ON message_deliveries (recipient_id, status);
+
+DROP INDEX IF EXISTS idx_conversation_members_user;
+DROP TABLE IF EXISTS conversation_members;
+DROP TABLE IF EXISTS conversations;
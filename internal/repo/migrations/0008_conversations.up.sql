@@ -0,0 +1,102 @@
+-- Conversations group private messages into direct (two users) and group threads.
+CREATE TABLE IF NOT EXISTS conversations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL DEFAULT 'direct',
+	name TEXT NOT NULL DEFAULT '',
+	direct_key TEXT UNIQUE, -- "<lower user id>:<higher user id>" for direct conversations, NULL for groups
+	created_by INTEGER,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_message_at DATETIME,
+	FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS conversation_members (
+	conversation_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	role TEXT NOT NULL DEFAULT 'member',
+	joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_read_message_id INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (conversation_id, user_id),
+	FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_members_user ON conversation_members (user_id);
+
+-- One direct conversation per pair of users that already exchanged messages.
+INSERT INTO conversations (kind, direct_key, created_at, last_message_at)
+SELECT 'direct',
+	MIN(sender_id, receiver_id) || ':' || MAX(sender_id, receiver_id),
+	MIN(created_at),
+	MAX(created_at)
+FROM private_messages
+GROUP BY MIN(sender_id, receiver_id), MAX(sender_id, receiver_id);
+
+INSERT OR IGNORE INTO conversation_members (conversation_id, user_id, joined_at)
+SELECT id, CAST(substr(direct_key, 1, instr(direct_key, ':') - 1) AS INTEGER), created_at
+FROM conversations WHERE kind = 'direct'
+UNION ALL
+SELECT id, CAST(substr(direct_key, instr(direct_key, ':') + 1) AS INTEGER), created_at
+FROM conversations WHERE kind = 'direct';
+
+-- Read position of every member, taken from the per-message read flags.
+UPDATE conversation_members
+SET last_read_message_id = COALESCE((
+	SELECT MAX(pm.id)
+	FROM private_messages pm
+	JOIN conversations c ON c.direct_key = MIN(pm.sender_id, pm.receiver_id) || ':' || MAX(pm.sender_id, pm.receiver_id)
+	WHERE c.id = conversation_members.conversation_id
+		AND pm.receiver_id = conversation_members.user_id
+		AND pm.is_read = TRUE
+), 0);
+
+-- Rebuild private_messages with a conversation_id and an optional receiver_id
+-- (group messages have no single receiver). message_deliveries references it,
+-- so it is set aside first and rebuilt with one row per message and recipient.
+CREATE TABLE message_deliveries_backup AS SELECT * FROM message_deliveries;
+DROP INDEX IF EXISTS idx_message_deliveries_recipient_status;
+DROP TABLE message_deliveries;
+
+CREATE TABLE private_messages_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	conversation_id INTEGER NOT NULL,
+	sender_id INTEGER NOT NULL,
+	receiver_id INTEGER,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	is_read BOOLEAN DEFAULT FALSE,
+	FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+	FOREIGN KEY (sender_id) REFERENCES users (id) ON DELETE CASCADE,
+	FOREIGN KEY (receiver_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+INSERT INTO private_messages_new (id, conversation_id, sender_id, receiver_id, content, created_at, is_read)
+SELECT pm.id, c.id, pm.sender_id, pm.receiver_id, pm.content, pm.created_at, pm.is_read
+FROM private_messages pm
+JOIN conversations c ON c.direct_key = MIN(pm.sender_id, pm.receiver_id) || ':' || MAX(pm.sender_id, pm.receiver_id);
+
+DROP INDEX IF EXISTS idx_private_messages_pair_created_at;
+DROP TABLE private_messages;
+ALTER TABLE private_messages_new RENAME TO private_messages;
+
+CREATE INDEX IF NOT EXISTS idx_private_messages_pair_created_at ON private_messages (sender_id, receiver_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_private_messages_conversation ON private_messages (conversation_id, id);
+
+CREATE TABLE message_deliveries (
+	message_id INTEGER NOT NULL,
+	recipient_id INTEGER NOT NULL,
+	status TEXT NOT NULL DEFAULT 'queued',
+	queued_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	delivered_at DATETIME,
+	read_at DATETIME,
+	PRIMARY KEY (message_id, recipient_id),
+	FOREIGN KEY (message_id) REFERENCES private_messages (id) ON DELETE CASCADE,
+	FOREIGN KEY (recipient_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+INSERT INTO message_deliveries (message_id, recipient_id, status, queued_at, delivered_at, read_at)
+SELECT message_id, recipient_id, status, queued_at, delivered_at, read_at FROM message_deliveries_backup;
+DROP TABLE message_deliveries_backup;
+
+CREATE INDEX IF NOT EXISTS idx_message_deliveries_recipient_status
+	ON message_deliveries (recipient_id, status);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_login_events_user;
+DROP INDEX IF EXISTS idx_login_events_ip;
+DROP INDEX IF EXISTS idx_login_events_identifier;
+DROP TABLE IF EXISTS login_events;
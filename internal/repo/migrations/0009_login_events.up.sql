@@ -0,0 +1,16 @@
+-- Audit trail of sign-in attempts, also used to count recent failures for lockout.
+-- user_id is NULL when the identifier matched no account.
+CREATE TABLE IF NOT EXISTS login_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	identifier TEXT NOT NULL,
+	ip TEXT NOT NULL,
+	user_agent TEXT NOT NULL DEFAULT '',
+	outcome TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_events_identifier ON login_events (identifier, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_events_ip ON login_events (ip, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_events_user ON login_events (user_id, created_at);
@@ -0,0 +1,12 @@
+-- Session metadata for the session list, and the role the token was issued for
+-- so tokens can be rotated when the user's privileges change.
+ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME;
+ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN role TEXT NOT NULL DEFAULT '';
+
+UPDATE sessions SET
+	last_seen_at = created_at,
+	role = COALESCE((SELECT role FROM users WHERE users.id = sessions.user_id), '');
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id);
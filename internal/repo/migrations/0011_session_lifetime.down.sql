@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_expiry;
+ALTER TABLE sessions DROP COLUMN remember;
+ALTER TABLE sessions DROP COLUMN absolute_expiry;
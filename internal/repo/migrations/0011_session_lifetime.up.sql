@@ -0,0 +1,8 @@
+-- Sliding sessions: expiry moves forward on use but never past absolute_expiry.
+-- remember marks "remember me" sessions, which get longer limits and a persistent cookie.
+ALTER TABLE sessions ADD COLUMN absolute_expiry DATETIME;
+ALTER TABLE sessions ADD COLUMN remember BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE sessions SET absolute_expiry = expiry;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_expiry ON sessions (expiry);
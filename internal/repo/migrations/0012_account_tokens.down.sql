@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_account_tokens_user;
+DROP TABLE IF EXISTS account_tokens;
+ALTER TABLE users DROP COLUMN email_verified_at;
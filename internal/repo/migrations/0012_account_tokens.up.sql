@@ -0,0 +1,18 @@
+-- Email verification and password reset.
+-- Accounts that existed before verification was introduced count as verified.
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME;
+UPDATE users SET email_verified_at = created_at;
+
+-- Single-use tokens mailed to users. Only the SHA-256 of a token is stored.
+CREATE TABLE IF NOT EXISTS account_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    purpose TEXT NOT NULL CHECK (purpose IN ('verify_email', 'reset_password')),
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_tokens_user ON account_tokens (user_id, purpose);
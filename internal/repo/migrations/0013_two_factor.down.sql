@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS login_challenges;
+DROP INDEX IF EXISTS idx_recovery_codes_user;
+DROP TABLE IF EXISTS recovery_codes;
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled_at;
+ALTER TABLE users DROP COLUMN totp_secret;
@@ -0,0 +1,32 @@
+-- TOTP two-factor authentication (RFC 6238).
+-- totp_secret is set when enrollment starts; totp_enabled_at once a code confirmed it.
+-- totp_last_step is the time step of the last accepted code, so a code cannot be replayed.
+ALTER TABLE users ADD COLUMN totp_secret TEXT;
+ALTER TABLE users ADD COLUMN totp_enabled_at DATETIME;
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER;
+
+-- One-time recovery codes for a lost authenticator. Only their SHA-256 is stored.
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes (user_id);
+
+-- Logins waiting for their second factor. The pending token given to the
+-- client after the password step is stored as a SHA-256 hash.
+CREATE TABLE IF NOT EXISTS login_challenges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    identifier TEXT NOT NULL,
+    remember BOOLEAN NOT NULL DEFAULT FALSE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+-- Comments are soft-deleted like posts, so replies keep their place in the thread.
+ALTER TABLE comments ADD COLUMN deleted_at DATETIME;
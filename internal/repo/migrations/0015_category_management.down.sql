@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_post_categories_category;
+DROP INDEX IF EXISTS idx_categories_slug;
+ALTER TABLE categories DROP COLUMN archived_at;
+ALTER TABLE categories DROP COLUMN position;
+ALTER TABLE categories DROP COLUMN description;
+ALTER TABLE categories DROP COLUMN slug;
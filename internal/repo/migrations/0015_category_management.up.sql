@@ -0,0 +1,24 @@
+-- Managed categories: URL slugs, descriptions, an explicit display order and archiving.
+-- Archived categories keep their posts but accept no new ones and are left out of the list.
+ALTER TABLE categories ADD COLUMN slug TEXT;
+ALTER TABLE categories ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE categories ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE categories ADD COLUMN archived_at DATETIME;
+
+-- Fresh databases start with the categories the client was built around
+INSERT INTO categories (name)
+SELECT name FROM (
+    SELECT 'TECH' AS name UNION ALL SELECT 'GAMING' UNION ALL SELECT 'LIFESTYLE'
+    UNION ALL SELECT 'SPORT' UNION ALL SELECT 'EDUCATION'
+)
+WHERE NOT EXISTS (SELECT 1 FROM categories);
+
+UPDATE categories SET
+    slug = lower(replace(trim(name), ' ', '-')) || CASE
+        WHEN EXISTS (SELECT 1 FROM categories o WHERE o.id < categories.id
+            AND lower(replace(trim(o.name), ' ', '-')) = lower(replace(trim(categories.name), ' ', '-')))
+        THEN '-' || id ELSE '' END,
+    position = id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
+CREATE INDEX IF NOT EXISTS idx_post_categories_category ON post_categories (category_id, post_id);
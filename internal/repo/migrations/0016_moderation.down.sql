@@ -0,0 +1,9 @@
+ALTER TABLE users DROP COLUMN suspended_until;
+ALTER TABLE private_messages DROP COLUMN hidden_at;
+ALTER TABLE comments DROP COLUMN hidden_at;
+ALTER TABLE posts DROP COLUMN hidden_at;
+
+DROP TRIGGER IF EXISTS moderation_log_no_delete;
+DROP TRIGGER IF EXISTS moderation_log_no_update;
+DROP TABLE IF EXISTS moderation_log;
+DROP TABLE IF EXISTS reports;
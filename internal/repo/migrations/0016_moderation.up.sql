@@ -0,0 +1,62 @@
+-- Reports of posts, comments and private messages, reviewed by moderators.
+-- content is a copy of the target at report time, so edits and hiding do not erase the evidence.
+CREATE TABLE IF NOT EXISTS reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reporter_id INTEGER NOT NULL,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'message')),
+    target_id INTEGER NOT NULL,
+    target_user_id INTEGER NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'actioned', 'dismissed')),
+    created_at DATETIME NOT NULL,
+    resolved_by INTEGER,
+    resolved_at DATETIME,
+    resolution_note TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (target_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports (status, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_reports_target ON reports (target_type, target_id);
+-- A user can have only one open report per target
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open_target
+    ON reports (reporter_id, target_type, target_id) WHERE status = 'open';
+
+-- Every moderation action and report decision. Entries are never changed or removed.
+CREATE TABLE IF NOT EXISTS moderation_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    moderator_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id INTEGER NOT NULL,
+    target_user_id INTEGER,
+    report_id INTEGER,
+    reason TEXT NOT NULL DEFAULT '',
+    expires_at DATETIME,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (moderator_id) REFERENCES users(id),
+    FOREIGN KEY (target_user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_log_created ON moderation_log (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_moderation_log_target_user ON moderation_log (target_user_id, created_at);
+
+CREATE TRIGGER IF NOT EXISTS moderation_log_no_update BEFORE UPDATE ON moderation_log
+BEGIN
+    SELECT RAISE(ABORT, 'moderation log entries cannot be changed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS moderation_log_no_delete BEFORE DELETE ON moderation_log
+BEGIN
+    SELECT RAISE(ABORT, 'moderation log entries cannot be deleted');
+END;
+
+-- Hidden content is kept but shown to nobody; a moderator can restore it.
+ALTER TABLE posts ADD COLUMN hidden_at DATETIME;
+ALTER TABLE comments ADD COLUMN hidden_at DATETIME;
+ALTER TABLE private_messages ADD COLUMN hidden_at DATETIME;
+
+-- Suspended users cannot sign in until this time.
+ALTER TABLE users ADD COLUMN suspended_until DATETIME;
@@ -0,0 +1,22 @@
+-- Blocking stops direct messages both ways, hides the blocked user's posts and
+-- comments from the blocker and hides presence between the two.
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id INTEGER NOT NULL,
+    blocked_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (blocker_id, blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked ON user_blocks (blocked_id);
+
+-- Muting keeps messages coming but without notifications.
+CREATE TABLE IF NOT EXISTS user_mutes (
+    muter_id INTEGER NOT NULL,
+    muted_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (muter_id, muted_id),
+    FOREIGN KEY (muter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN show_gender;
+ALTER TABLE users DROP COLUMN show_age;
+ALTER TABLE users DROP COLUMN show_full_name;
+ALTER TABLE users DROP COLUMN last_seen_at;
@@ -0,0 +1,8 @@
+-- Profiles: when each user was last active, and which personal details other
+-- users can see. Full name, age and gender start private.
+ALTER TABLE users ADD COLUMN last_seen_at DATETIME;
+ALTER TABLE users ADD COLUMN show_full_name INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN show_age INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN show_gender INTEGER NOT NULL DEFAULT 0;
+
+UPDATE users SET last_seen_at = (SELECT MAX(s.last_seen_at) FROM sessions s WHERE s.user_id = users.id);
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN presence_status;
@@ -0,0 +1,6 @@
+-- Presence: the status a user picked for themselves. Idle and offline are
+-- derived by the server; is_online and last_seen_at are kept up to date by the hub.
+ALTER TABLE users ADD COLUMN presence_status TEXT NOT NULL DEFAULT 'online';
+
+-- is_online was never maintained before; nobody is connected during a migration
+UPDATE users SET is_online = FALSE;
@@ -0,0 +1,375 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// ErrReportUnchanged is returned when a report already has the requested status.
+var ErrReportUnchanged = errors.New("report already has this status")
+
+// contentTables maps report and hide targets to their table and author column.
+var contentTables = map[string]struct{ table, author string }{
+	models.TargetPost:    {"posts", "user_id"},
+	models.TargetComment: {"comments", "user_id"},
+	models.TargetMessage: {"private_messages", "sender_id"},
+}
+
+// ReportTarget returns the author and current text of something a user wants to
+// report. Posts and comments must not be deleted, and messages must belong to a
+// conversation the reporter is a member of. It returns ErrNoRows otherwise.
+func ReportTarget(targetType string, targetID, reporterID int) (int, string, error) {
+	var authorID int
+	var content string
+	var err error
+	switch targetType {
+	case models.TargetPost:
+		err = DB.QueryRow(`
+			SELECT user_id, title || char(10) || char(10) || content FROM posts WHERE id = ? AND deleted_at IS NULL
+		`, targetID).Scan(&authorID, &content)
+	case models.TargetComment:
+		err = DB.QueryRow(`SELECT user_id, content FROM comments WHERE id = ? AND deleted_at IS NULL`, targetID).Scan(&authorID, &content)
+	case models.TargetMessage:
+		err = DB.QueryRow(`
+			SELECT pm.sender_id, pm.content
+			FROM private_messages pm
+			JOIN conversation_members cm ON cm.conversation_id = pm.conversation_id AND cm.user_id = ?
+			WHERE pm.id = ?
+		`, reporterID, targetID).Scan(&authorID, &content)
+	default:
+		return 0, "", ErrNoRows
+	}
+	return authorID, content, err
+}
+
+// CreateReport stores a new open report and sets its ID, status and creation time.
+// It returns ErrDuplicateEntry when the reporter already has an open report on the target.
+func CreateReport(report *models.Report) error {
+	report.Status = models.ReportOpen
+	report.CreatedAt = time.Now()
+	res, err := DB.Exec(`
+		INSERT INTO reports (reporter_id, target_type, target_id, target_user_id, content, reason, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, report.ReporterID, report.TargetType, report.TargetID, report.TargetUserID, report.Content, report.Reason,
+		report.Status, report.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicateEntry
+		}
+		log.Printf("[moderation.go:CreateReport] Error creating report: %v", err)
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	report.ID = int(id)
+	return nil
+}
+
+// reportQuery selects reports with the nicknames of the reporter and the reported user.
+const reportQuery = `
+	SELECT r.id, r.reporter_id, ru.nickname, r.target_type, r.target_id, r.target_user_id, tu.nickname,
+		r.content, r.reason, r.status, r.created_at, CAST(r.created_at AS TEXT), r.resolved_by, r.resolved_at, r.resolution_note
+	FROM reports r
+	JOIN users ru ON ru.id = r.reporter_id
+	JOIN users tu ON tu.id = r.target_user_id`
+
+// scanReport reads a row selected with reportQuery and returns it with its raw creation time.
+func scanReport(row interface{ Scan(...interface{}) error }) (*models.Report, string, error) {
+	report := &models.Report{}
+	var rawCreatedAt string
+	err := row.Scan(&report.ID, &report.ReporterID, &report.ReporterNickname, &report.TargetType, &report.TargetID,
+		&report.TargetUserID, &report.TargetNickname, &report.Content, &report.Reason, &report.Status,
+		&report.CreatedAt, &rawCreatedAt, &report.ResolvedBy, &report.ResolvedAt, &report.ResolutionNote)
+	return report, rawCreatedAt, err
+}
+
+// GetReports returns one page of the moderation queue, oldest report first, and
+// the cursor of the next page or "" at the end.
+func GetReports(opts models.ReportListOptions) ([]*models.Report, string, error) {
+	after, args, err := keysetCondition(opts.Cursor, "r.created_at", "r.id", false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := reportQuery + ` WHERE ` + after
+	if opts.Status != "" {
+		query += ` AND r.status = ?`
+		args = append(args, opts.Status)
+	}
+	if opts.TargetType != "" {
+		query += ` AND r.target_type = ?`
+		args = append(args, opts.TargetType)
+	}
+	if opts.TargetUserID != 0 {
+		query += ` AND r.target_user_id = ?`
+		args = append(args, opts.TargetUserID)
+	}
+	query += ` ORDER BY r.created_at ASC, r.id ASC LIMIT ?`
+	args = append(args, opts.Limit+1)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		log.Printf("[moderation.go:GetReports] Error querying reports: %v", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	reports := []*models.Report{}
+	var rawTimes []string
+	for rows.Next() {
+		report, rawCreatedAt, err := scanReport(rows)
+		if err != nil {
+			log.Printf("[moderation.go:GetReports] Error scanning report: %v", err)
+			return nil, "", err
+		}
+		reports = append(reports, report)
+		rawTimes = append(rawTimes, rawCreatedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(reports) > opts.Limit {
+		reports = reports[:opts.Limit]
+		nextCursor = nextTimeCursor(rawTimes[opts.Limit-1], reports[opts.Limit-1].ID)
+	}
+	return reports, nextCursor, nil
+}
+
+// GetReport returns one report, or ErrNoRows.
+func GetReport(id int) (*models.Report, error) {
+	report, _, err := scanReport(DB.QueryRow(reportQuery+` WHERE r.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// SetReportStatus moves a report to a new status and logs the decision.
+// Reopening clears the resolution. It returns ErrNoRows for an unknown report
+// and ErrReportUnchanged when the status is the same.
+func SetReportStatus(reportID, moderatorID int, status, note string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	var targetUserID int
+	err = tx.QueryRow(`SELECT status, target_user_id FROM reports WHERE id = ?`, reportID).Scan(&current, &targetUserID)
+	if err != nil {
+		return err
+	}
+	if current == status {
+		return ErrReportUnchanged
+	}
+
+	now := time.Now()
+	action := models.ActionResolveReport
+	switch status {
+	case models.ReportOpen:
+		action = models.ActionReopenReport
+		_, err = tx.Exec(`
+			UPDATE reports SET status = ?, resolved_by = NULL, resolved_at = NULL, resolution_note = '' WHERE id = ?
+		`, status, reportID)
+	default:
+		if status == models.ReportDismissed {
+			action = models.ActionDismissReport
+		}
+		_, err = tx.Exec(`
+			UPDATE reports SET status = ?, resolved_by = ?, resolved_at = ?, resolution_note = ? WHERE id = ?
+		`, status, moderatorID, now, note, reportID)
+	}
+	if err != nil {
+		log.Printf("[moderation.go:SetReportStatus] Error updating report %d: %v", reportID, err)
+		return err
+	}
+
+	entry := &models.ModerationLogEntry{
+		ModeratorID:  moderatorID,
+		Action:       action,
+		TargetType:   models.TargetReport,
+		TargetID:     reportID,
+		TargetUserID: targetUserID,
+		ReportID:     reportID,
+		Reason:       note,
+		CreatedAt:    now,
+	}
+	if err := insertModerationLog(tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ApplyModerationAction carries out a hide, unhide, warn, suspend or unsuspend
+// action and records it in the moderation log, in one transaction. For hide and
+// unhide, entry.TargetUserID is filled in with the author of the content.
+// Hiding also closes the open reports on the content, and entry.ReportID, when
+// set, is closed as actioned. It returns ErrNoRows when the target does not exist
+// or, for unsuspend, is not suspended.
+func ApplyModerationAction(entry *models.ModerationLogEntry) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	entry.CreatedAt = time.Now()
+	var res sql.Result
+	switch entry.Action {
+	case models.ActionHide, models.ActionUnhide:
+		content, ok := contentTables[entry.TargetType]
+		if !ok {
+			return ErrNoRows
+		}
+		err = tx.QueryRow(`SELECT `+content.author+` FROM `+content.table+` WHERE id = ?`, entry.TargetID).Scan(&entry.TargetUserID)
+		if err != nil {
+			return err
+		}
+		if entry.Action == models.ActionHide {
+			res, err = tx.Exec(`UPDATE `+content.table+` SET hidden_at = COALESCE(hidden_at, ?) WHERE id = ?`, entry.CreatedAt, entry.TargetID)
+		} else {
+			res, err = tx.Exec(`UPDATE `+content.table+` SET hidden_at = NULL WHERE id = ?`, entry.TargetID)
+		}
+	case models.ActionSuspend:
+		res, err = tx.Exec(`UPDATE users SET suspended_until = ? WHERE id = ?`, entry.ExpiresAt, entry.TargetUserID)
+	case models.ActionUnsuspend:
+		res, err = tx.Exec(`UPDATE users SET suspended_until = NULL WHERE id = ? AND suspended_until IS NOT NULL`, entry.TargetUserID)
+	case models.ActionWarn:
+		// Nothing changes; the log entry is the warning
+		var exists bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, entry.TargetUserID).Scan(&exists)
+		if err == nil && !exists {
+			return ErrNoRows
+		}
+	default:
+		return errors.New("unknown moderation action")
+	}
+	if err != nil {
+		log.Printf("[moderation.go:ApplyModerationAction] Error applying %s: %v", entry.Action, err)
+		return err
+	}
+	if res != nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNoRows
+		}
+	}
+
+	if entry.Action == models.ActionHide {
+		_, err = tx.Exec(`
+			UPDATE reports SET status = ?, resolved_by = ?, resolved_at = ?, resolution_note = ?
+			WHERE target_type = ? AND target_id = ? AND status = ?
+		`, models.ReportActioned, entry.ModeratorID, entry.CreatedAt, entry.Reason, entry.TargetType, entry.TargetID, models.ReportOpen)
+		if err != nil {
+			return err
+		}
+	}
+	if entry.ReportID != 0 {
+		_, err = tx.Exec(`
+			UPDATE reports SET status = ?, resolved_by = ?, resolved_at = ?, resolution_note = ?
+			WHERE id = ? AND status = ?
+		`, models.ReportActioned, entry.ModeratorID, entry.CreatedAt, entry.Reason, entry.ReportID, models.ReportOpen)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := insertModerationLog(tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertModerationLog appends an entry to the moderation log and sets its ID.
+func insertModerationLog(tx *sql.Tx, entry *models.ModerationLogEntry) error {
+	var targetUserID, reportID interface{}
+	if entry.TargetUserID != 0 {
+		targetUserID = entry.TargetUserID
+	}
+	if entry.ReportID != 0 {
+		reportID = entry.ReportID
+	}
+	res, err := tx.Exec(`
+		INSERT INTO moderation_log (moderator_id, action, target_type, target_id, target_user_id, report_id, reason, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.ModeratorID, entry.Action, entry.TargetType, entry.TargetID, targetUserID, reportID, entry.Reason, entry.ExpiresAt, entry.CreatedAt)
+	if err != nil {
+		log.Printf("[moderation.go:insertModerationLog] Error writing moderation log: %v", err)
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = int(id)
+	return nil
+}
+
+// GetModerationLog returns one page of the moderation log, newest entry first,
+// and the cursor of the next page or "" at the end.
+func GetModerationLog(opts models.ModerationLogOptions) ([]*models.ModerationLogEntry, string, error) {
+	before, args, err := keysetCondition(opts.Cursor, "l.created_at", "l.id", true)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+		SELECT l.id, l.moderator_id, u.nickname, l.action, l.target_type, l.target_id,
+			COALESCE(l.target_user_id, 0), COALESCE(l.report_id, 0), l.reason, l.expires_at, l.created_at, CAST(l.created_at AS TEXT)
+		FROM moderation_log l
+		JOIN users u ON u.id = l.moderator_id
+		WHERE ` + before
+	if opts.Action != "" {
+		query += ` AND l.action = ?`
+		args = append(args, opts.Action)
+	}
+	if opts.TargetUserID != 0 {
+		query += ` AND l.target_user_id = ?`
+		args = append(args, opts.TargetUserID)
+	}
+	if opts.ModeratorID != 0 {
+		query += ` AND l.moderator_id = ?`
+		args = append(args, opts.ModeratorID)
+	}
+	query += ` ORDER BY l.created_at DESC, l.id DESC LIMIT ?`
+	args = append(args, opts.Limit+1)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		log.Printf("[moderation.go:GetModerationLog] Error querying moderation log: %v", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	entries := []*models.ModerationLogEntry{}
+	var rawTimes []string
+	for rows.Next() {
+		entry := &models.ModerationLogEntry{}
+		var rawCreatedAt string
+		if err := rows.Scan(&entry.ID, &entry.ModeratorID, &entry.ModeratorNickname, &entry.Action, &entry.TargetType,
+			&entry.TargetID, &entry.TargetUserID, &entry.ReportID, &entry.Reason, &entry.ExpiresAt, &entry.CreatedAt, &rawCreatedAt); err != nil {
+			log.Printf("[moderation.go:GetModerationLog] Error scanning moderation log: %v", err)
+			return nil, "", err
+		}
+		entries = append(entries, entry)
+		rawTimes = append(rawTimes, rawCreatedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(entries) > opts.Limit {
+		entries = entries[:opts.Limit]
+		nextCursor = nextTimeCursor(rawTimes[opts.Limit-1], entries[opts.Limit-1].ID)
+	}
+	return entries, nextCursor, nil
+}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"strings"
 	"time"
@@ -64,38 +65,58 @@ func CreatePost(post *models.Post, categoryIDs []int) (int64, error) {
 	return postID, tx.Commit()
 }
 
-// GetAllPosts retrieves all posts from the database for the main feed.
+// GetPosts retrieves one page of the main feed, or of one category's feed, newest first.
+// It uses keyset pagination on (created_at, id) and returns the cursor for the
+// next page, or "" when there are no more posts.
 // It also fetches the author's nickname and the associated categories for each post.
-func GetAllPosts() ([]*models.Post, error) {
+func GetPosts(opts models.PostListOptions) ([]*models.Post, string, error) {
+	if opts.Sort == models.PostSortTop {
+		return getTopPosts(opts)
+	}
+
+	after, args, err := keysetCondition(opts.Cursor, "p.created_at", "p.id", true)
+	if err != nil {
+		return nil, "", err
+	}
+	filter, filterArgs := postFilter(opts)
+	args = append(args, filterArgs...)
+
 	query := `
 		SELECT
-			p.id, p.user_id, p.title, p.content, p.created_at,
+			p.id, p.user_id, p.title, p.content, p.created_at, CAST(p.created_at AS TEXT), p.updated_at,
 			u.nickname,
 			GROUP_CONCAT(c.name)
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
 		LEFT JOIN post_categories pc ON p.id = pc.post_id
 		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE p.deleted_at IS NULL AND p.hidden_at IS NULL AND ` + after + filter + `
 		GROUP BY p.id
-		ORDER BY p.created_at DESC
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT ?
 	`
-	rows, err := DB.Query(query)
+	// Fetch one extra row to know whether there is a next page.
+	args = append(args, opts.Limit+1)
+
+	rows, err := DB.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	var posts []*models.Post
+	posts := []*models.Post{}
+	var rawTimes []string
 	for rows.Next() {
 		post := &models.Post{Author: &models.User{}}
+		var rawCreatedAt string
 		var categories sql.NullString // Use sql.NullString to handle posts with no categories
 
 		if err := rows.Scan(
-			&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt,
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &rawCreatedAt, &post.UpdatedAt,
 			&post.Author.Nickname,
 			&categories,
 		); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		if categories.Valid {
@@ -105,35 +126,141 @@ func GetAllPosts() ([]*models.Post, error) {
 		}
 
 		posts = append(posts, post)
+		rawTimes = append(rawTimes, rawCreatedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(posts) > opts.Limit {
+		posts = posts[:opts.Limit]
+		nextCursor = nextTimeCursor(rawTimes[opts.Limit-1], posts[opts.Limit-1].ID)
+	}
+	if err := attachPostReactions(posts); err != nil {
+		return nil, "", err
 	}
+	return posts, nextCursor, nil
+}
+
+// postFilter returns the conditions limiting a feed query to one category or
+// author and leaving out posts by users the viewer blocked, or nothing when none is set.
+func postFilter(opts models.PostListOptions) (string, []interface{}) {
+	filter := ""
+	var args []interface{}
+	if opts.CategoryID != 0 {
+		filter += " AND p.id IN (SELECT post_id FROM post_categories WHERE category_id = ?)"
+		args = append(args, opts.CategoryID)
+	}
+	if opts.AuthorID != 0 {
+		filter += " AND p.user_id = ?"
+		args = append(args, opts.AuthorID)
+	}
+	if opts.ViewerID != 0 {
+		filter += " AND p.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)"
+		args = append(args, opts.ViewerID)
+	}
+	return filter, args
+}
 
-	return posts, rows.Err()
+// topCursor is the keyset position of the "top" feed. Now freezes the time used to
+// decay scores, so ranks stay stable while a client pages through the feed.
+type topCursor struct {
+	Now  string  `json:"n"`
+	Rank float64 `json:"r"`
+	ID   int     `json:"i"`
 }
 
-// GetAllCategories retrieves all available categories from the database.
-func GetAllCategories() ([]*models.Category, error) {
-	rows, err := DB.Query("SELECT id, name FROM categories ORDER BY name ASC")
+// getTopPosts returns one page of the feed ordered by vote score decayed by age:
+// score / (age in hours + 2)^2, highest first.
+func getTopPosts(opts models.PostListOptions) ([]*models.Post, string, error) {
+	var position topCursor
+	if opts.Cursor != "" {
+		if err := DecodeCursor(opts.Cursor, &position); err != nil {
+			return nil, "", err
+		}
+		if position.Now == "" {
+			return nil, "", ErrInvalidCursor
+		}
+	} else {
+		position.Now = time.Now().UTC().Format(sqliteTimeFormat)
+	}
+
+	filter, filterArgs := postFilter(opts)
+	ageHours := "((julianday(?) - julianday(p.created_at)) * 24 + 2)"
+	score := fmt.Sprintf(scoreExpr, "'post'", "p.id")
+	query := `
+		SELECT id, user_id, title, content, created_at, updated_at, nickname, categories, rank
+		FROM (
+			SELECT
+				p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at,
+				u.nickname,
+				GROUP_CONCAT(c.name) AS categories,
+				` + score + ` * 1.0 / (` + ageHours + ` * ` + ageHours + `) AS rank
+			FROM posts p
+			JOIN users u ON p.user_id = u.id
+			LEFT JOIN post_categories pc ON p.id = pc.post_id
+			LEFT JOIN categories c ON pc.category_id = c.id
+			WHERE p.deleted_at IS NULL AND p.hidden_at IS NULL` + filter + `
+			GROUP BY p.id
+		)`
+	args := append([]interface{}{position.Now, position.Now}, filterArgs...)
+	if opts.Cursor != "" {
+		query += ` WHERE (rank < ? OR (rank = ? AND id < ?))`
+		args = append(args, position.Rank, position.Rank, position.ID)
+	}
+	query += ` ORDER BY rank DESC, id DESC LIMIT ?`
+	args = append(args, opts.Limit+1)
+
+	rows, err := DB.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	var categories []*models.Category
+	posts := []*models.Post{}
+	var ranks []float64
 	for rows.Next() {
-		category := &models.Category{}
-		if err := rows.Scan(&category.ID, &category.Name); err != nil {
-			return nil, err
+		post := &models.Post{Author: &models.User{}}
+		var categories sql.NullString
+		var rank float64
+		if err := rows.Scan(
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.UpdatedAt,
+			&post.Author.Nickname, &categories, &rank,
+		); err != nil {
+			return nil, "", err
+		}
+		if categories.Valid {
+			post.Categories = strings.Split(categories.String, ",")
+		} else {
+			post.Categories = []string{}
 		}
-		categories = append(categories, category)
+		posts = append(posts, post)
+		ranks = append(ranks, rank)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(posts) > opts.Limit {
+		posts = posts[:opts.Limit]
+		last := opts.Limit - 1
+		nextCursor = EncodeCursor(topCursor{Now: position.Now, Rank: ranks[last], ID: posts[last].ID})
+	}
+	if err := attachPostReactions(posts); err != nil {
+		return nil, "", err
 	}
-	return categories, rows.Err()
+	return posts, nextCursor, nil
 }
 
 // GetPostByID retrieves a single post from the database by its ID.
+// Soft-deleted and hidden posts are returned with Deleted or Hidden set and their
+// title and content cleared, so their comment threads can still be shown.
 func GetPostByID(id int64) (*models.Post, error) {
 	query := `
 		SELECT
-			p.id, p.user_id, p.title, p.content, p.created_at,
+			p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.deleted_at, p.hidden_at IS NOT NULL,
 			u.nickname,
 			GROUP_CONCAT(c.name)
 		FROM posts p
@@ -147,9 +274,10 @@ func GetPostByID(id int64) (*models.Post, error) {
 
 	post := &models.Post{Author: &models.User{}}
 	var categories sql.NullString
+	var deletedAt *time.Time
 
 	err := row.Scan(
-		&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt,
+		&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.UpdatedAt, &deletedAt, &post.Hidden,
 		&post.Author.Nickname,
 		&categories,
 	)
@@ -168,5 +296,128 @@ func GetPostByID(id int64) (*models.Post, error) {
 		post.Categories = []string{}
 	}
 
+	if deletedAt != nil {
+		post.Deleted = true
+	}
+	if post.Deleted || post.Hidden {
+		post.Title = ""
+		post.Content = ""
+	}
+
+	if err := attachPostReactions([]*models.Post{post}); err != nil {
+		return nil, err
+	}
 	return post, nil
 }
+
+// UpdatePost changes a post's title and content and records the new version in post_revisions.
+// The first edit also stores the original version as revision 1, so the history is complete.
+// categoryIDs replaces the post's categories when it is not nil.
+func UpdatePost(postID, editorID int, title, content string, categoryIDs []int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	var authorID int
+	var oldTitle, oldContent string
+	var createdAt time.Time
+	var deletedAt, hiddenAt *time.Time
+	err = tx.QueryRow(`
+		SELECT user_id, title, content, created_at, deleted_at, hidden_at FROM posts WHERE id = ?
+	`, postID).Scan(&authorID, &oldTitle, &oldContent, &createdAt, &deletedAt, &hiddenAt)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if deletedAt != nil || hiddenAt != nil {
+		tx.Rollback()
+		return ErrNoRows
+	}
+
+	var lastRevision int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(revision), 0) FROM post_revisions WHERE post_id = ?`, postID).Scan(&lastRevision); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if lastRevision == 0 {
+		_, err = tx.Exec(`
+			INSERT INTO post_revisions (post_id, revision, editor_id, title, content, created_at)
+			VALUES (?, 1, ?, ?, ?, ?)
+		`, postID, authorID, oldTitle, oldContent, createdAt)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		lastRevision = 1
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE posts SET title = ?, content = ?, updated_at = ? WHERE id = ?`, title, content, now, postID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO post_revisions (post_id, revision, editor_id, title, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, postID, lastRevision+1, editorID, title, content, now)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if categoryIDs != nil {
+		if _, err := tx.Exec(`DELETE FROM post_categories WHERE post_id = ?`, postID); err != nil {
+			tx.Rollback()
+			return err
+		}
+		for _, catID := range categoryIDs {
+			if _, err := tx.Exec(`INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)`, postID, catID); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SoftDeletePost marks a post as deleted without removing it, so its comments survive.
+func SoftDeletePost(postID int) error {
+	res, err := DB.Exec(`UPDATE posts SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, time.Now(), postID)
+	if err != nil {
+		log.Printf("[posts.go:SoftDeletePost] Error deleting post %d: %v", postID, err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// GetPostRevisions returns the stored versions of a post, oldest first.
+// A post that was never edited has no revisions.
+func GetPostRevisions(postID int) ([]*models.PostRevision, error) {
+	rows, err := DB.Query(`
+		SELECT r.revision, r.post_id, r.title, r.content, r.created_at, u.id, u.nickname
+		FROM post_revisions r
+		JOIN users u ON u.id = r.editor_id
+		WHERE r.post_id = ?
+		ORDER BY r.revision ASC
+	`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.PostRevision{}
+	for rows.Next() {
+		rev := &models.PostRevision{Editor: &models.User{}}
+		if err := rows.Scan(&rev.Revision, &rev.PostID, &rev.Title, &rev.Content, &rev.CreatedAt, &rev.Editor.ID, &rev.Editor.Nickname); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// GetPresenceStatus returns the presence status a user picked for themselves.
+func GetPresenceStatus(userID int) (string, error) {
+	var status string
+	err := DB.QueryRow(`SELECT presence_status FROM users WHERE id = ?`, userID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", ErrNoRows
+	}
+	if err != nil {
+		log.Printf("[presence.go:GetPresenceStatus] Error loading presence status of user %d: %v", userID, err)
+		return "", err
+	}
+	return status, nil
+}
+
+// UpdatePresenceStatus stores the presence status a user picked, one of
+// online, away and dnd.
+func UpdatePresenceStatus(userID int, status string) error {
+	res, err := DB.Exec(`UPDATE users SET presence_status = ? WHERE id = ?`, status, userID)
+	if err != nil {
+		log.Printf("[presence.go:UpdatePresenceStatus] Error updating presence status of user %d: %v", userID, err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// SetUserOnline records that a user opened their first connection or closed
+// their last one; either way they were last seen at the given time.
+func SetUserOnline(userID int, online bool, at time.Time) error {
+	_, err := DB.Exec(`UPDATE users SET is_online = ?, last_seen_at = ? WHERE id = ?`, online, at, userID)
+	if err != nil {
+		log.Printf("[presence.go:SetUserOnline] Error updating presence of user %d: %v", userID, err)
+	}
+	return err
+}
+
+// ResetOnlineUsers marks every user offline, for a server starting with no connections.
+func ResetOnlineUsers() error {
+	_, err := DB.Exec(`UPDATE users SET is_online = FALSE WHERE is_online`)
+	if err != nil {
+		log.Printf("[presence.go:ResetOnlineUsers] Error resetting online users: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// GetProfile returns the profile details of a user with their visible post and
+// comment counts and their visibility settings, or nil when the user does not exist.
+// Posts and the filtering of private details are left to the caller.
+func GetProfile(userID int) (*models.Profile, *models.ProfileVisibility, error) {
+	profile := &models.Profile{}
+	visibility := &models.ProfileVisibility{}
+	var firstName, lastName, gender sql.NullString
+	var age sql.NullInt64
+	var lastSeen sql.NullTime
+	err := DB.QueryRow(`
+		SELECT u.id, u.nickname, u.email, u.first_name, u.last_name, u.age, u.gender, u.role, u.created_at, u.last_seen_at,
+			u.show_full_name, u.show_age, u.show_gender, u.presence_status,
+			(SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id AND p.deleted_at IS NULL AND p.hidden_at IS NULL),
+			(SELECT COUNT(*) FROM comments c WHERE c.user_id = u.id AND c.deleted_at IS NULL AND c.hidden_at IS NULL)
+		FROM users u
+		WHERE u.id = ?
+	`, userID).Scan(&profile.ID, &profile.Nickname, &profile.Email, &firstName, &lastName, &age, &gender, &profile.Role,
+		&profile.JoinedAt, &lastSeen, &visibility.FullName, &visibility.Age, &visibility.Gender, &profile.PresenceStatus,
+		&profile.PostCount, &profile.CommentCount)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		log.Printf("[profiles.go:GetProfile] Error loading profile of user %d: %v", userID, err)
+		return nil, nil, err
+	}
+
+	profile.FirstName = firstName.String
+	profile.LastName = lastName.String
+	profile.Age = int(age.Int64)
+	profile.Gender = gender.String
+	if lastSeen.Valid {
+		profile.LastSeenAt = &lastSeen.Time
+	}
+	return profile, visibility, nil
+}
+
+// UpdateProfile changes the personal details and visibility settings of a user.
+// Nil fields of the request are left unchanged.
+func UpdateProfile(userID int, req *models.ProfileUpdateRequest) error {
+	query := `UPDATE users SET first_name = COALESCE(?, first_name), last_name = COALESCE(?, last_name),
+		age = COALESCE(?, age), gender = COALESCE(?, gender)`
+	args := []interface{}{req.FirstName, req.LastName, req.Age, req.Gender}
+	if req.Visibility != nil {
+		query += `, show_full_name = ?, show_age = ?, show_gender = ?`
+		args = append(args, req.Visibility.FullName, req.Visibility.Age, req.Visibility.Gender)
+	}
+	query += ` WHERE id = ?`
+	args = append(args, userID)
+
+	res, err := DB.Exec(query, args...)
+	if err != nil {
+		log.Printf("[profiles.go:UpdateProfile] Error updating profile of user %d: %v", userID, err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// UpdateUserEmail changes the address of a user and marks it unverified.
+// Verification and reset links mailed to the old address stop working.
+// It returns ErrDuplicateEntry when another account uses the address.
+func UpdateUserEmail(userID int, email string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET email = ?, email_verified_at = NULL WHERE id = ?`, email, userID); err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicateEntry
+		}
+		log.Printf("[profiles.go:UpdateUserEmail] Error changing email of user %d: %v", userID, err)
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE account_tokens SET used_at = ?
+		WHERE user_id = ? AND used_at IS NULL
+	`, time.Now(), userID); err != nil {
+		log.Printf("[profiles.go:UpdateUserEmail] Error invalidating tokens of user %d: %v", userID, err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateUserPassword sets a new password hash for a user.
+func UpdateUserPassword(userID int, passwordHash string) error {
+	res, err := DB.Exec(`UPDATE users SET password_hash = ? WHERE id = ?`, passwordHash, userID)
+	if err != nil {
+		log.Printf("[profiles.go:UpdateUserPassword] Error changing password of user %d: %v", userID, err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package repo
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// scoreExpr is the SQL expression for the vote score of a target, given its type and ID columns.
+const scoreExpr = `(SELECT COALESCE(SUM(CASE r.reaction WHEN 'up' THEN 1 WHEN 'down' THEN -1 ELSE 0 END), 0)
+	FROM reactions r WHERE r.target_type = %s AND r.target_id = %s)`
+
+// AddReaction records a reaction. Adding a vote replaces the user's opposite vote on the same target.
+// Adding a reaction the user already has is a no-op.
+func AddReaction(userID int, targetType string, targetID int, reaction string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	opposite := ""
+	switch reaction {
+	case models.ReactionUp:
+		opposite = models.ReactionDown
+	case models.ReactionDown:
+		opposite = models.ReactionUp
+	}
+	if opposite != "" {
+		_, err := tx.Exec(`
+			DELETE FROM reactions WHERE user_id = ? AND target_type = ? AND target_id = ? AND reaction = ?
+		`, userID, targetType, targetID, opposite)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT OR IGNORE INTO reactions (user_id, target_type, target_id, reaction, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, targetType, targetID, reaction, time.Now())
+	if err != nil {
+		tx.Rollback()
+		log.Printf("[reactions.go:AddReaction] Error adding reaction: %v", err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveReaction deletes a user's reaction. Removing a reaction that does not exist is a no-op.
+func RemoveReaction(userID int, targetType string, targetID int, reaction string) error {
+	_, err := DB.Exec(`
+		DELETE FROM reactions WHERE user_id = ? AND target_type = ? AND target_id = ? AND reaction = ?
+	`, userID, targetType, targetID, reaction)
+	if err != nil {
+		log.Printf("[reactions.go:RemoveReaction] Error removing reaction: %v", err)
+	}
+	return err
+}
+
+// GetReactions lists who reacted to a target and how, oldest first.
+func GetReactions(targetType string, targetID int) ([]*models.Reaction, error) {
+	rows, err := DB.Query(`
+		SELECT r.user_id, u.nickname, r.target_type, r.target_id, r.reaction, r.created_at
+		FROM reactions r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.target_type = ? AND r.target_id = ?
+		ORDER BY r.created_at ASC
+	`, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := []*models.Reaction{}
+	for rows.Next() {
+		r := &models.Reaction{}
+		if err := rows.Scan(&r.UserID, &r.Nickname, &r.TargetType, &r.TargetID, &r.Reaction, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, r)
+	}
+	return reactions, rows.Err()
+}
+
+// GetReactionCounts returns reaction counts per target ID for the given targets.
+// Targets without reactions are absent from the result.
+func GetReactionCounts(targetType string, targetIDs []int) (map[int]map[string]int, error) {
+	counts := make(map[int]map[string]int)
+	if len(targetIDs) == 0 {
+		return counts, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(targetIDs)), ",")
+	args := []interface{}{targetType}
+	for _, id := range targetIDs {
+		args = append(args, id)
+	}
+
+	rows, err := DB.Query(`
+		SELECT target_id, reaction, COUNT(*)
+		FROM reactions
+		WHERE target_type = ? AND target_id IN (`+placeholders+`)
+		GROUP BY target_id, reaction
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, n int
+		var reaction string
+		if err := rows.Scan(&id, &reaction, &n); err != nil {
+			return nil, err
+		}
+		if counts[id] == nil {
+			counts[id] = make(map[string]int)
+		}
+		counts[id][reaction] = n
+	}
+	return counts, rows.Err()
+}
+
+// attachPostReactions fills the reaction counts and score of each post.
+func attachPostReactions(posts []*models.Post) error {
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	counts, err := GetReactionCounts(models.TargetPost, ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range posts {
+		p.Reactions, p.Score = reactionSummary(counts[p.ID])
+	}
+	return nil
+}
+
+// attachCommentReactions fills the reaction counts and score of each comment.
+func attachCommentReactions(comments []*models.Comment) error {
+	ids := make([]int, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+	counts, err := GetReactionCounts(models.TargetComment, ids)
+	if err != nil {
+		return err
+	}
+	for _, c := range comments {
+		c.Reactions, c.Score = reactionSummary(counts[c.ID])
+	}
+	return nil
+}
+
+// reactionSummary returns a non-nil counts map and the vote score derived from it.
+func reactionSummary(counts map[string]int) (map[string]int, int) {
+	if counts == nil {
+		counts = map[string]int{}
+	}
+	return counts, counts[models.ReactionUp] - counts[models.ReactionDown]
+}
+
+// ReactionTargetExists reports whether a reaction target exists and can still be reacted to.
+func ReactionTargetExists(targetType string, targetID int) (bool, error) {
+	var query string
+	switch targetType {
+	case models.TargetPost:
+		query = `SELECT COUNT(*) FROM posts WHERE id = ? AND deleted_at IS NULL AND hidden_at IS NULL`
+	case models.TargetComment:
+		query = `SELECT COUNT(*) FROM comments WHERE id = ? AND deleted_at IS NULL AND hidden_at IS NULL`
+	default:
+		return false, nil
+	}
+	var n int
+	if err := DB.QueryRow(query, targetID).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// ErrBlocked is returned when a direct message is sent between users where one blocked the other
+var ErrBlocked = errors.New("one of the users blocked the other")
+
+// relationTables maps a relation kind to its table and the columns of the user
+// who set it and the user it applies to.
+var relationTables = map[string]struct{ table, owner, target string }{
+	models.RelationBlock: {"user_blocks", "blocker_id", "blocked_id"},
+	models.RelationMute:  {"user_mutes", "muter_id", "muted_id"},
+}
+
+// relationTable returns the table description of a relation kind
+func relationTable(kind string) (struct{ table, owner, target string }, error) {
+	t, ok := relationTables[kind]
+	if !ok {
+		return t, fmt.Errorf("unknown relation %q", kind)
+	}
+	return t, nil
+}
+
+// AddUserRelation blocks or mutes targetID for userID. Adding a relation that
+// already exists is not an error and keeps its original time.
+func AddUserRelation(kind string, userID, targetID int) error {
+	t, err := relationTable(kind)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`INSERT OR IGNORE INTO `+t.table+` (`+t.owner+`, `+t.target+`, created_at) VALUES (?, ?, ?)`,
+		userID, targetID, time.Now())
+	if err != nil {
+		log.Printf("[relations.go:AddUserRelation] Error adding %s of user %d by user %d: %v", kind, targetID, userID, err)
+	}
+	return err
+}
+
+// RemoveUserRelation lifts a block or mute. It returns ErrNoRows when there was none.
+func RemoveUserRelation(kind string, userID, targetID int) error {
+	t, err := relationTable(kind)
+	if err != nil {
+		return err
+	}
+
+	res, err := DB.Exec(`DELETE FROM `+t.table+` WHERE `+t.owner+` = ? AND `+t.target+` = ?`, userID, targetID)
+	if err != nil {
+		log.Printf("[relations.go:RemoveUserRelation] Error removing %s of user %d by user %d: %v", kind, targetID, userID, err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// GetUserRelations lists the users a user blocked or muted, most recent first
+func GetUserRelations(kind string, userID int) ([]*models.UserRelation, error) {
+	t, err := relationTable(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT u.id, u.nickname, r.created_at
+		FROM `+t.table+` r
+		JOIN users u ON u.id = r.`+t.target+`
+		WHERE r.`+t.owner+` = ?
+		ORDER BY r.created_at DESC, u.id DESC
+	`, userID)
+	if err != nil {
+		log.Printf("[relations.go:GetUserRelations] Error listing %s relations of user %d: %v", kind, userID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	relations := []*models.UserRelation{}
+	for rows.Next() {
+		r := &models.UserRelation{}
+		if err := rows.Scan(&r.UserID, &r.Nickname, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		relations = append(relations, r)
+	}
+	return relations, rows.Err()
+}
+
+// GetRelationIDs returns the IDs of the users a user blocked and muted, for the hub
+func GetRelationIDs(userID int) (blocked []int, muted []int, err error) {
+	if blocked, err = relationIDs(models.RelationBlock, userID); err != nil {
+		return nil, nil, err
+	}
+	if muted, err = relationIDs(models.RelationMute, userID); err != nil {
+		return nil, nil, err
+	}
+	return blocked, muted, nil
+}
+
+// relationIDs returns the targets of one relation kind set by a user
+func relationIDs(kind string, userID int) ([]int, error) {
+	t, err := relationTable(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`SELECT `+t.target+` FROM `+t.table+` WHERE `+t.owner+` = ?`, userID)
+	if err != nil {
+		log.Printf("[relations.go:relationIDs] Error loading %s relations of user %d: %v", kind, userID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// IsBlockedBetween reports whether either user blocked the other
+func IsBlockedBetween(userA, userB int) (bool, error) {
+	return isBlockedBetween(DB, userA, userB)
+}
+
+// isBlockedBetween runs the block check on a database or inside a transaction
+func isBlockedBetween(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, userA, userB int) (bool, error) {
+	var blocked bool
+	err := q.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM user_blocks
+			WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?))
+	`, userA, userB, userB, userA).Scan(&blocked)
+	return blocked, err
+}
@@ -0,0 +1,268 @@
+package repo
+
+import (
+	"html"
+	"log"
+	"strings"
+
+	"real-time-forum/internal/models"
+)
+
+// Markers used by snippet(); they cannot appear in user text once escaped,
+// so matches can be highlighted after HTML-escaping the excerpt.
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+// searchCursor is the keyset position of the last result of a page.
+type searchCursor struct {
+	Score float64 `json:"s"`
+	Type  string  `json:"t"`
+	ID    int     `json:"i"`
+}
+
+// SearchContent runs a ranked full-text search over posts and comments.
+// Results are ordered by bm25 (best first) and paginated with an opaque cursor.
+// Without FTS5 it falls back to substring matching: every word must appear,
+// and posts with all of them in the title rank first.
+// It returns the page of results and the cursor for the next page ("" when there are no more).
+func SearchContent(params models.SearchParams) ([]*models.SearchResult, string, error) {
+	available, err := FullTextSearchAvailable()
+	if err != nil {
+		return nil, "", err
+	}
+
+	match := buildMatchQuery(params.Query)
+	words := strings.Fields(params.Query)
+	if match == "" {
+		return []*models.SearchResult{}, "", nil
+	}
+
+	var arms []string
+	var args []interface{}
+
+	// Filters shared by both arms. For comments, category applies to the parent post.
+	// Content by users the viewer blocked is left out, as in the feeds.
+	filters := func(alias string) string {
+		var where []string
+		if params.CategoryID > 0 {
+			where = append(where, "EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = p.id AND pc.category_id = ?)")
+			args = append(args, params.CategoryID)
+		}
+		if params.Author != "" {
+			where = append(where, "u.nickname = ?")
+			args = append(args, params.Author)
+		}
+		if params.From != nil {
+			where = append(where, alias+".created_at >= ?")
+			args = append(args, *params.From)
+		}
+		if params.To != nil {
+			where = append(where, alias+".created_at < ?")
+			args = append(args, *params.To)
+		}
+		if params.ViewerID != 0 {
+			where = append(where, alias+".user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)")
+			args = append(args, params.ViewerID)
+		}
+		if len(where) == 0 {
+			return ""
+		}
+		return " AND " + strings.Join(where, " AND ")
+	}
+
+	if params.Type == "" || params.Type == "post" {
+		var arm string
+		if available {
+			// Title matches weigh more than body matches.
+			arm = `
+			SELECT 'post' AS type, p.id AS id, p.id AS post_id, p.title AS post_title,
+				snippet(posts_fts, -1, '` + snippetOpen + `', '` + snippetClose + `', '…', 16) AS snippet,
+				bm25(posts_fts, 10.0, 1.0) AS score,
+				p.created_at AS created_at, p.user_id AS user_id, u.nickname AS nickname
+			FROM posts_fts
+			JOIN posts p ON p.id = posts_fts.rowid
+			JOIN users u ON u.id = p.user_id
+			WHERE posts_fts MATCH ? AND p.deleted_at IS NULL AND p.hidden_at IS NULL`
+			args = append(args, match)
+		} else {
+			inTitle := likeAll([]string{"p.title"}, len(words))
+			arm = `
+			SELECT 'post' AS type, p.id AS id, p.id AS post_id, p.title AS post_title,
+				CASE WHEN ` + inTitle + ` THEN p.title ELSE p.content END AS snippet,
+				CASE WHEN ` + inTitle + ` THEN -10.0 ELSE -1.0 END AS score,
+				p.created_at AS created_at, p.user_id AS user_id, u.nickname AS nickname
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			WHERE ` + likeAll([]string{"p.title", "p.content"}, len(words)) + ` AND p.deleted_at IS NULL AND p.hidden_at IS NULL`
+			args = append(args, likeArgs(words, 1)...)
+			args = append(args, likeArgs(words, 1)...)
+			args = append(args, likeArgs(words, 2)...)
+		}
+		arms = append(arms, arm+filters("p"))
+	}
+	if params.Type == "" || params.Type == "comment" {
+		var arm string
+		if available {
+			arm = `
+			SELECT 'comment' AS type, c.id AS id, p.id AS post_id, p.title AS post_title,
+				snippet(comments_fts, 0, '` + snippetOpen + `', '` + snippetClose + `', '…', 16) AS snippet,
+				bm25(comments_fts) AS score,
+				c.created_at AS created_at, c.user_id AS user_id, u.nickname AS nickname
+			FROM comments_fts
+			JOIN comments c ON c.id = comments_fts.rowid
+			JOIN posts p ON p.id = c.post_id
+			JOIN users u ON u.id = c.user_id
+			WHERE comments_fts MATCH ? AND p.deleted_at IS NULL AND p.hidden_at IS NULL
+				AND c.deleted_at IS NULL AND c.hidden_at IS NULL`
+			args = append(args, match)
+		} else {
+			arm = `
+			SELECT 'comment' AS type, c.id AS id, p.id AS post_id, p.title AS post_title,
+				c.content AS snippet, -1.0 AS score,
+				c.created_at AS created_at, c.user_id AS user_id, u.nickname AS nickname
+			FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			JOIN users u ON u.id = c.user_id
+			WHERE ` + likeAll([]string{"c.content"}, len(words)) + ` AND p.deleted_at IS NULL AND p.hidden_at IS NULL
+				AND c.deleted_at IS NULL AND c.hidden_at IS NULL`
+			args = append(args, likeArgs(words, 1)...)
+		}
+		arms = append(arms, arm+filters("c"))
+	}
+
+	query := "SELECT type, id, post_id, post_title, snippet, score, created_at, user_id, nickname FROM (" +
+		strings.Join(arms, " UNION ALL ") + ")"
+
+	if params.Cursor != "" {
+		var after searchCursor
+		if err := DecodeCursor(params.Cursor, &after); err != nil {
+			return nil, "", err
+		}
+		query += " WHERE (score > ? OR (score = ? AND (type > ? OR (type = ? AND id > ?))))"
+		args = append(args, after.Score, after.Score, after.Type, after.Type, after.ID)
+	}
+
+	// Fetch one extra row to know whether there is a next page.
+	query += " ORDER BY score ASC, type ASC, id ASC LIMIT ?"
+	args = append(args, params.Limit+1)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		log.Printf("[search.go:SearchContent] Error running search query: %v", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	results := []*models.SearchResult{}
+	for rows.Next() {
+		r := &models.SearchResult{Author: &models.User{}}
+		if err := rows.Scan(&r.Type, &r.ID, &r.PostID, &r.PostTitle, &r.Snippet, &r.Score, &r.CreatedAt, &r.Author.ID, &r.Author.Nickname); err != nil {
+			return nil, "", err
+		}
+		if !available {
+			r.Snippet = excerpt(r.Snippet, words)
+		}
+		r.Snippet = highlightSnippet(r.Snippet)
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(results) > params.Limit {
+		results = results[:params.Limit]
+		last := results[len(results)-1]
+		nextCursor = EncodeCursor(searchCursor{Score: last.Score, Type: last.Type, ID: last.ID})
+	}
+	return results, nextCursor, nil
+}
+
+// buildMatchQuery turns free text into a safe FTS5 query: every word is quoted
+// (so operators typed by users are treated literally) and the last word is a prefix match.
+func buildMatchQuery(input string) string {
+	words := strings.Fields(input)
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.ReplaceAll(w, `"`, `""`)
+		terms = append(terms, `"`+w+`"`)
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	terms[len(terms)-1] += "*"
+	return strings.Join(terms, " ")
+}
+
+// likeAll returns a condition requiring each of n words to appear in one of the
+// columns, with one LIKE placeholder per word and column, in word order.
+func likeAll(columns []string, n int) string {
+	conds := make([]string, n)
+	for i := range conds {
+		alts := make([]string, len(columns))
+		for j, column := range columns {
+			alts[j] = column + ` LIKE ? ESCAPE '\'`
+		}
+		conds[i] = "(" + strings.Join(alts, " OR ") + ")"
+	}
+	return "(" + strings.Join(conds, " AND ") + ")"
+}
+
+// likeArgs returns the substring patterns for likeAll, each repeated once per column.
+func likeArgs(words []string, columns int) []interface{} {
+	args := make([]interface{}, 0, len(words)*columns)
+	for _, w := range words {
+		pattern := "%" + strings.TrimSuffix(likePrefix(w), "%") + "%"
+		for i := 0; i < columns; i++ {
+			args = append(args, pattern)
+		}
+	}
+	return args
+}
+
+// excerpt cuts about 16 words of text around the first one containing a search word
+// and wraps the matching words in the snippet markers, like snippet() does with FTS5.
+func excerpt(text string, words []string) string {
+	const size = 16
+	tokens := strings.Fields(text)
+	first := -1
+	for i, token := range tokens {
+		lower := strings.ToLower(token)
+		for _, w := range words {
+			if strings.Contains(lower, strings.ToLower(w)) {
+				tokens[i] = snippetOpen + token + snippetClose
+				if first < 0 {
+					first = i
+				}
+				break
+			}
+		}
+	}
+
+	start := 0
+	if first > size/4 {
+		start = first - size/4
+	}
+	if start+size > len(tokens) {
+		start = max(0, len(tokens)-size)
+	}
+	end := min(len(tokens), start+size)
+
+	out := strings.Join(tokens[start:end], " ")
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(tokens) {
+		out += "…"
+	}
+	return out
+}
+
+// highlightSnippet HTML-escapes an excerpt and turns the snippet markers into <mark> tags.
+func highlightSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, snippetOpen, "<mark>")
+	return strings.ReplaceAll(escaped, snippetClose, "</mark>")
+}
@@ -0,0 +1,92 @@
+package repo
+
+import (
+	"testing"
+
+	"real-time-forum/internal/models"
+)
+
+func TestSearchContent(t *testing.T) {
+	openTestDB(t)
+
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	titled, err := CreatePost(&models.Post{UserID: alice.ID, Title: "Zebra care", Content: "Feeding and grooming"}, nil)
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	bodied, err := CreatePost(&models.Post{UserID: bob.ID, Title: "Field notes", Content: "Saw a striped zebra near the river"}, nil)
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	comment, err := CreateComment(&models.Comment{PostID: int(titled), UserID: bob.ID, Content: "My zebra likes carrots"})
+	if err != nil {
+		t.Fatalf("CreateComment: %v", err)
+	}
+	if err := AddUserRelation(models.RelationBlock, alice.ID, bob.ID); err != nil {
+		t.Fatalf("AddUserRelation: %v", err)
+	}
+
+	type hit struct {
+		Type string
+		ID   int
+	}
+	tests := []struct {
+		name   string
+		params models.SearchParams
+		want   []hit
+	}{
+		{"posts and comments", models.SearchParams{Query: "zebra"}, []hit{{"post", int(titled)}, {"post", int(bodied)}, {"comment", int(comment)}}},
+		{"every word must match", models.SearchParams{Query: "zebra river"}, []hit{{"post", int(bodied)}}},
+		{"only comments", models.SearchParams{Query: "zebra", Type: "comment"}, []hit{{"comment", int(comment)}}},
+		{"by author", models.SearchParams{Query: "zebra", Author: "alice"}, []hit{{"post", int(titled)}}},
+		{"blocked authors left out", models.SearchParams{Query: "zebra", ViewerID: alice.ID}, []hit{{"post", int(titled)}}},
+		{"blocks are one way", models.SearchParams{Query: "zebra", ViewerID: bob.ID}, []hit{{"post", int(titled)}, {"post", int(bodied)}, {"comment", int(comment)}}},
+		{"no match", models.SearchParams{Query: "giraffe"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.Limit = 10
+			results, next, err := SearchContent(tt.params)
+			if err != nil {
+				t.Fatalf("SearchContent: %v", err)
+			}
+			if next != "" {
+				t.Errorf("next cursor = %q, want none", next)
+			}
+			got := map[hit]bool{}
+			for _, r := range results {
+				got[hit{r.Type, r.ID}] = true
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("got %d results %v, want %v", len(results), got, tt.want)
+			}
+			for _, h := range tt.want {
+				if !got[h] {
+					t.Errorf("missing %v in %v", h, got)
+				}
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		text  string
+		words []string
+		want  string
+	}{
+		{"Saw a striped Zebra near the river", []string{"zebra"}, "Saw a striped \x02Zebra\x03 near the river"},
+		{"one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty zebra",
+			[]string{"zebra"}, "…six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty \x02zebra\x03"},
+		{"zebra one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen",
+			[]string{"zebra"}, "\x02zebra\x03 one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen…"},
+		{"zebras and river", []string{"river", "zebra"}, "\x02zebras\x03 and \x02river\x03"},
+	}
+	for _, tt := range tests {
+		if got := excerpt(tt.text, tt.words); got != tt.want {
+			t.Errorf("excerpt(%q, %q) = %q, want %q", tt.text, tt.words, got, tt.want)
+		}
+	}
+}
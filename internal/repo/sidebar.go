@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// sidebarQuery lists the conversations of a user followed by the users they
+// never talked to. Each half has a placeholder condition, filled in by querySidebar.
+const sidebarQuery = `
+	SELECT kind, conversation_id, user_id, name, last_message, last_sender_id,
+		strftime('%%Y-%%m-%%d %%H:%%M:%%f', last_at), unread_count, blocked, muted, hide_presence,
+		strftime('%%Y-%%m-%%d %%H:%%M:%%f', active_at)
+	FROM (
+		SELECT c.kind, c.id AS conversation_id, COALESCE(partner.id, 0) AS user_id,
+			CASE WHEN c.kind = 'group' THEN c.name ELSE COALESCE(partner.nickname, '') END AS name,
+			CASE WHEN last.hidden_at IS NULL THEN COALESCE(last.content, '') ELSE '' END AS last_message,
+			COALESCE(last.sender_id, 0) AS last_sender_id,
+			julianday(last.created_at) AS last_at,
+			(SELECT COUNT(*) FROM private_messages m
+				WHERE m.conversation_id = c.id AND m.sender_id != cm.user_id AND m.id > cm.last_read_message_id) AS unread_count,
+			EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = cm.user_id AND blocked_id = partner.id) AS blocked,
+			EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = cm.user_id AND muted_id = partner.id) AS muted,
+			EXISTS (SELECT 1 FROM user_blocks
+				WHERE (blocker_id = cm.user_id AND blocked_id = partner.id) OR (blocker_id = partner.id AND blocked_id = cm.user_id)) AS hide_presence,
+			julianday(COALESCE(c.last_message_at, c.created_at)) AS active_at
+		FROM conversation_members cm
+		JOIN conversations c ON c.id = cm.conversation_id
+		LEFT JOIN conversation_members other
+			ON c.kind = 'direct' AND other.conversation_id = c.id AND other.user_id != cm.user_id
+		LEFT JOIN users partner ON partner.id = other.user_id
+		LEFT JOIN private_messages last
+			ON last.id = (SELECT MAX(id) FROM private_messages WHERE conversation_id = c.id)
+		WHERE cm.user_id = ? AND %s
+
+		UNION ALL
+
+		SELECT 'direct', 0, u.id, u.nickname, '', 0, NULL, 0,
+			EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = ? AND blocked_id = u.id),
+			EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = ? AND muted_id = u.id),
+			EXISTS (SELECT 1 FROM user_blocks
+				WHERE (blocker_id = ? AND blocked_id = u.id) OR (blocker_id = u.id AND blocked_id = ?)),
+			NULL
+		FROM users u
+		WHERE u.id != ? AND %s
+			AND NOT EXISTS (SELECT 1 FROM conversations c WHERE c.direct_key = MIN(?, u.id) || ':' || MAX(?, u.id))
+	)
+	ORDER BY active_at IS NULL, active_at DESC, name COLLATE NOCASE ASC, user_id ASC, conversation_id ASC`
+
+// GetSidebar returns the chat sidebar of a user: their direct and group
+// conversations, most recently active first, with the last message and unread
+// count, followed by every other user alphabetically.
+func GetSidebar(userID int) ([]*models.SidebarEntry, error) {
+	entries, err := querySidebar(userID, "1", "1", nil, nil)
+	if err != nil {
+		log.Printf("[sidebar.go:GetSidebar] Error loading the sidebar of user %d: %v", userID, err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetSidebarEntry returns one entry of a user's sidebar: the conversation with
+// conversationID, or, when it is 0, the user partnerID with their direct
+// conversation if there is one. It returns nil when there is no such entry.
+func GetSidebarEntry(userID, conversationID, partnerID int) (*models.SidebarEntry, error) {
+	var entries []*models.SidebarEntry
+	var err error
+	if conversationID != 0 {
+		entries, err = querySidebar(userID, "c.id = ?", "0", []interface{}{conversationID}, nil)
+	} else {
+		entries, err = querySidebar(userID, "c.kind = 'direct' AND partner.id = ?", "u.id = ?",
+			[]interface{}{partnerID}, []interface{}{partnerID})
+	}
+	if err != nil {
+		log.Printf("[sidebar.go:GetSidebarEntry] Error loading sidebar entry %d/%d of user %d: %v", conversationID, partnerID, userID, err)
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return entries[0], nil
+}
+
+// querySidebar runs sidebarQuery with the given conditions on the conversation
+// and the user halves and their arguments.
+func querySidebar(userID int, conversationCond, userCond string, conversationArgs, userArgs []interface{}) ([]*models.SidebarEntry, error) {
+	query := fmt.Sprintf(sidebarQuery, conversationCond, userCond)
+	args := append([]interface{}{userID}, conversationArgs...)
+	args = append(args, userID, userID, userID, userID, userID)
+	args = append(args, userArgs...)
+	args = append(args, userID, userID)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.SidebarEntry{}
+	for rows.Next() {
+		entry := &models.SidebarEntry{}
+		var lastAt, activeAt sql.NullString
+		if err := rows.Scan(&entry.Kind, &entry.ConversationID, &entry.UserID, &entry.Name,
+			&entry.LastMessage, &entry.LastSenderID, &lastAt, &entry.UnreadCount,
+			&entry.Blocked, &entry.Muted, &entry.HidePresence, &activeAt); err != nil {
+			return nil, err
+		}
+		entry.LastMessageAt = parseSQLiteTime(lastAt)
+		entry.ActiveAt = parseSQLiteTime(activeAt)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// parseSQLiteTime parses a UTC time formatted by SQLite, with or without
+// fractional seconds, nil for NULL.
+func parseSQLiteTime(value sql.NullString) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value.String, time.UTC)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,222 @@
+package repo
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// SetPendingTOTPSecret stores a new TOTP secret for a user who has not enabled
+// two-factor authentication yet, replacing an unfinished enrollment. It
+// returns ErrNoRows when two-factor authentication is already enabled.
+func SetPendingTOTPSecret(userID int, secret string) error {
+	res, err := DB.Exec(`UPDATE users SET totp_secret = ? WHERE id = ? AND totp_enabled_at IS NULL`, secret, userID)
+	if err != nil {
+		log.Printf("[two_factor.go:SetPendingTOTPSecret] Error storing TOTP secret: %v", err)
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// GetTOTPSecret returns the TOTP secret of a user ("" when there is none) and
+// whether two-factor authentication is enabled.
+func GetTOTPSecret(userID int) (string, bool, error) {
+	var secret sql.NullString
+	var enabled bool
+	err := DB.QueryRow(`SELECT totp_secret, totp_enabled_at IS NOT NULL FROM users WHERE id = ?`, userID).Scan(&secret, &enabled)
+	if err != nil {
+		log.Printf("[two_factor.go:GetTOTPSecret] Error reading TOTP secret of user %d: %v", userID, err)
+		return "", false, err
+	}
+	return secret.String, enabled, nil
+}
+
+// UseTOTPStep records that a code of the given time step was accepted. It
+// returns false when a code of this or a later step was already used, which
+// makes every code single-use.
+func UseTOTPStep(userID int, step int64) (bool, error) {
+	res, err := DB.Exec(`
+		UPDATE users SET totp_last_step = ?
+		WHERE id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)
+	`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// EnableTOTP turns two-factor authentication on for a user and replaces their
+// recovery codes with the given hashes.
+func EnableTOTP(userID int, step int64, codeHashes []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE users SET totp_enabled_at = ?, totp_last_step = ? WHERE id = ?
+	`, time.Now(), step, userID); err != nil {
+		log.Printf("[two_factor.go:EnableTOTP] Error enabling TOTP for user %d: %v", userID, err)
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP turns two-factor authentication off for a user and deletes their
+// secret, recovery codes and pending logins.
+func DisableTOTP(userID int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = ?
+	`, userID); err != nil {
+		log.Printf("[two_factor.go:DisableTOTP] Error disabling TOTP for user %d: %v", userID, err)
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM login_challenges WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes deletes the recovery codes of a user and stores the given hashes instead.
+func ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		log.Printf("[two_factor.go:replaceRecoveryCodes] Error deleting recovery codes of user %d: %v", userID, err)
+		return err
+	}
+	now := time.Now()
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`
+			INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)
+		`, userID, hash, now); err != nil {
+			log.Printf("[two_factor.go:replaceRecoveryCodes] Error storing recovery code of user %d: %v", userID, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used. It returns
+// false when the user has no such unused code.
+func UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	res, err := DB.Exec(`
+		UPDATE recovery_codes SET used_at = ?
+		WHERE id = (SELECT id FROM recovery_codes WHERE user_id = ? AND code_hash = ? AND used_at IS NULL LIMIT 1)
+	`, time.Now(), userID, codeHash)
+	if err != nil {
+		log.Printf("[two_factor.go:UseRecoveryCode] Error using recovery code of user %d: %v", userID, err)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left.
+func CountRecoveryCodes(userID int) (int, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// CreateLoginChallenge stores a login waiting for its second factor and sets its ID.
+func CreateLoginChallenge(challenge *models.LoginChallenge) error {
+	if challenge.CreatedAt.IsZero() {
+		challenge.CreatedAt = time.Now()
+	}
+	res, err := DB.Exec(`
+		INSERT INTO login_challenges (user_id, token_hash, identifier, remember, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, challenge.UserID, challenge.TokenHash, challenge.Identifier, challenge.Remember, challenge.ExpiresAt, challenge.CreatedAt)
+	if err != nil {
+		log.Printf("[two_factor.go:CreateLoginChallenge] Error creating login challenge: %v", err)
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	challenge.ID = int(id)
+	return nil
+}
+
+// GetLoginChallenge returns the unexpired login challenge with the given token
+// hash, or nil when there is none.
+func GetLoginChallenge(tokenHash string) (*models.LoginChallenge, error) {
+	c := &models.LoginChallenge{}
+	err := DB.QueryRow(`
+		SELECT id, user_id, token_hash, identifier, remember, attempts, expires_at, created_at
+		FROM login_challenges
+		WHERE token_hash = ? AND expires_at > ?
+	`, tokenHash, time.Now()).Scan(&c.ID, &c.UserID, &c.TokenHash, &c.Identifier, &c.Remember, &c.Attempts, &c.ExpiresAt, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("[two_factor.go:GetLoginChallenge] Error reading login challenge: %v", err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// CountLoginChallengeAttempt adds a failed code to a login challenge and returns the new count.
+func CountLoginChallengeAttempt(id int) (int, error) {
+	if _, err := DB.Exec(`UPDATE login_challenges SET attempts = attempts + 1 WHERE id = ?`, id); err != nil {
+		return 0, err
+	}
+	var attempts int
+	err := DB.QueryRow(`SELECT attempts FROM login_challenges WHERE id = ?`, id).Scan(&attempts)
+	return attempts, err
+}
+
+// DeleteLoginChallenge removes a login challenge once it is completed or exhausted.
+// It returns ErrNoRows when the challenge was already gone, so only one request can complete it.
+func DeleteLoginChallenge(id int) error {
+	res, err := DB.Exec(`DELETE FROM login_challenges WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// PurgeLoginChallenges deletes login challenges that expired before the given time.
+func PurgeLoginChallenges(before time.Time) (int64, error) {
+	res, err := DB.Exec(`DELETE FROM login_challenges WHERE expires_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -3,7 +3,10 @@ package repo
 import (
 	"database/sql"
 	"errors"
+	"log"
 	"real-time-forum/internal/models"
+	"strings"
+	"time"
 
 	"github.com/mattn/go-sqlite3"
 )
@@ -22,7 +25,7 @@ func CreateUser(user *models.User) error {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(user.Nickname, user.Email, user.PasswordHash, user.FirstName, user.LastName, user.Age, user.Gender)
+	res, err := stmt.Exec(user.Nickname, user.Email, user.PasswordHash, user.FirstName, user.LastName, user.Age, user.Gender)
 	if err != nil {
 		// Check if the error is a UNIQUE constraint violation.
 		var sqliteErr sqlite3.Error
@@ -31,6 +34,9 @@ func CreateUser(user *models.User) error {
 		}
 		return err
 	}
+	if id, err := res.LastInsertId(); err == nil {
+		user.ID = int(id)
+	}
 	return nil
 }
 
@@ -84,7 +90,7 @@ func GetUserByNickname(nickname string) (*models.User, error) {
 // This is primarily used for the login process.
 func GetUserByEmailOrNickname(identifier string) (*models.User, error) {
 	stmt, err := DB.Prepare(`
-		SELECT id, nickname, email, password_hash, first_name, last_name, age, gender, created_at, last_login, is_online
+		SELECT id, nickname, email, password_hash, first_name, last_name, age, gender, created_at, last_login, is_online, role, email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL, suspended_until
 		FROM users
 		WHERE email = ? OR nickname = ?
 	`)
@@ -94,7 +100,7 @@ func GetUserByEmailOrNickname(identifier string) (*models.User, error) {
 	defer stmt.Close()
 
 	user := &models.User{}
-	err = stmt.QueryRow(identifier, identifier).Scan(&user.ID, &user.Nickname, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.CreatedAt, &user.LastLogin, &user.IsOnline)
+	err = stmt.QueryRow(identifier, identifier).Scan(&user.ID, &user.Nickname, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.CreatedAt, &user.LastLogin, &user.IsOnline, &user.Role, &user.EmailVerified, &user.TwoFactor, &user.SuspendedUntil)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No user found, which is a valid case for a login attempt
@@ -108,7 +114,7 @@ func GetUserByEmailOrNickname(identifier string) (*models.User, error) {
 // It returns a slice of User models, excluding sensitive information.
 func GetAllUsers() ([]*models.User, error) {
 	rows, err := DB.Query(`
-		SELECT id, nickname, email, first_name, last_name, age, gender
+		SELECT id, nickname, email, first_name, last_name, age, gender, role
 		FROM users
 		ORDER BY nickname ASC
 	`)
@@ -128,6 +134,7 @@ func GetAllUsers() ([]*models.User, error) {
 			&user.LastName,
 			&user.Age,
 			&user.Gender,
+			&user.Role,
 		); err != nil {
 			return nil, err
 		}
@@ -140,7 +147,7 @@ func GetAllUsers() ([]*models.User, error) {
 // GetUserByID retrieves a user by their ID.
 func GetUserByID(id int) (*models.User, error) {
 	stmt, err := DB.Prepare(`
-		SELECT id, nickname, email, password_hash, first_name, last_name, age, gender, created_at, last_login, is_online
+		SELECT id, nickname, email, password_hash, first_name, last_name, age, gender, created_at, last_login, is_online, role, email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL, suspended_until
 		FROM users
 		WHERE id = ?
 	`)
@@ -150,7 +157,7 @@ func GetUserByID(id int) (*models.User, error) {
 	defer stmt.Close()
 
 	user := &models.User{}
-	err = stmt.QueryRow(id).Scan(&user.ID, &user.Nickname, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.CreatedAt, &user.LastLogin, &user.IsOnline)
+	err = stmt.QueryRow(id).Scan(&user.ID, &user.Nickname, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.CreatedAt, &user.LastLogin, &user.IsOnline, &user.Role, &user.EmailVerified, &user.TwoFactor, &user.SuspendedUntil)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No user found
@@ -161,3 +168,113 @@ func GetUserByID(id int) (*models.User, error) {
 }
 
 var ErrNoRows = sql.ErrNoRows
+
+// SetUserRole changes the role of a user. It returns ErrNoRows when the user does not exist.
+func SetUserRole(userID int, role string) error {
+	res, err := DB.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// CountUsersWithRole returns how many users have a role.
+func CountUsersWithRole(role string) (int, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM users WHERE role = ?`, role).Scan(&count)
+	return count, err
+}
+
+// userCursor is the keyset position of the user directory. LastMessage is the
+// julianday of the last direct message with the viewer, nil for users they never talked to.
+type userCursor struct {
+	LastMessage *float64 `json:"l,omitempty"`
+	Nickname    string   `json:"n"`
+	ID          int      `json:"i"`
+}
+
+// GetUserDirectory returns one page of the users other than the viewer: those
+// the viewer exchanged direct messages with first, most recent conversation
+// first, then everyone else alphabetically. It returns the cursor of the next
+// page, or "" when there are no more users.
+func GetUserDirectory(opts models.UserListOptions) ([]*models.DirectoryUser, string, error) {
+	var position userCursor
+	if opts.Cursor != "" {
+		if err := DecodeCursor(opts.Cursor, &position); err != nil {
+			return nil, "", err
+		}
+	}
+
+	query := `
+		SELECT id, nickname, last_message, datetime(last_message), blocked
+		FROM (
+			SELECT u.id, u.nickname,
+				(SELECT julianday(c.last_message_at) FROM conversations c
+					WHERE c.direct_key = MIN(?, u.id) || ':' || MAX(?, u.id)) AS last_message,
+				EXISTS (SELECT 1 FROM user_blocks b
+					WHERE (b.blocker_id = ? AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = ?)) AS blocked
+			FROM users u
+			WHERE u.id != ? AND u.nickname LIKE ? ESCAPE '\'
+		)`
+	args := []interface{}{opts.ViewerID, opts.ViewerID, opts.ViewerID, opts.ViewerID, opts.ViewerID, likePrefix(opts.Prefix)}
+
+	after := `(nickname COLLATE NOCASE > ? OR (nickname COLLATE NOCASE = ? AND id > ?))`
+	switch {
+	case opts.Cursor == "":
+	case position.LastMessage != nil:
+		query += ` WHERE last_message IS NULL OR last_message < ? OR (last_message = ? AND ` + after + `)`
+		args = append(args, *position.LastMessage, *position.LastMessage, position.Nickname, position.Nickname, position.ID)
+	default:
+		query += ` WHERE last_message IS NULL AND ` + after
+		args = append(args, position.Nickname, position.Nickname, position.ID)
+	}
+	query += ` ORDER BY last_message IS NULL, last_message DESC, nickname COLLATE NOCASE ASC, id ASC LIMIT ?`
+	// Fetch one extra row to know whether there is a next page.
+	args = append(args, opts.Limit+1)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		log.Printf("[users.go:GetUserDirectory] Error listing users for user %d: %v", opts.ViewerID, err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	users := []*models.DirectoryUser{}
+	var positions []userCursor
+	for rows.Next() {
+		user := &models.DirectoryUser{}
+		var lastMessage sql.NullFloat64
+		var lastMessageText sql.NullString
+		if err := rows.Scan(&user.ID, &user.Nickname, &lastMessage, &lastMessageText, &user.HidePresence); err != nil {
+			return nil, "", err
+		}
+		p := userCursor{Nickname: user.Nickname, ID: user.ID}
+		if lastMessage.Valid {
+			p.LastMessage = &lastMessage.Float64
+			if t, err := time.ParseInLocation("2006-01-02 15:04:05", lastMessageText.String, time.UTC); err == nil {
+				user.LastMessageAt = &t
+			}
+		}
+		users = append(users, user)
+		positions = append(positions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(users) > opts.Limit {
+		users = users[:opts.Limit]
+		nextCursor = EncodeCursor(positions[opts.Limit-1])
+	}
+	return users, nextCursor, nil
+}
+
+// likePrefix turns a prefix into a LIKE pattern, escaping the wildcards it contains.
+func likePrefix(prefix string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+	return escaped + "%"
+}
@@ -0,0 +1,136 @@
+package ws
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// typingThrottle is the minimum time between two typing_start frames forwarded for the same pair of users
+	typingThrottle = 3 * time.Second
+	// typingTimeout is how long a typing indicator lasts without a new typing_start
+	typingTimeout = 6 * time.Second
+)
+
+// TypingSignal is a typing_start or typing_stop frame routed through the hub
+type TypingSignal struct {
+	FromUserID int
+	ToUserID   int
+	Nickname   string
+	Typing     bool // true for typing_start, false for typing_stop
+}
+
+// ReadReceipt tells the sender of private messages that the recipient read them
+type ReadReceipt struct {
+	ReaderID      int // User who opened the conversation
+	SenderID      int // User whose messages were read
+	LastMessageID int // Highest message ID that is now read
+}
+
+// typingKey identifies who is typing to whom
+type typingKey struct {
+	from, to int
+}
+
+// typingState tracks an active typing indicator
+type typingState struct {
+	nickname  string
+	forwarded time.Time // When typing_start was last sent to the recipient
+	expires   time.Time // When the indicator stops unless refreshed
+}
+
+// NotifyMessagesRead pushes a messages_read event to the sender's connections
+// Safe to call from any goroutine
+func (h *Hub) NotifyMessagesRead(readerID, senderID, lastMessageID int) {
+	if lastMessageID <= 0 {
+		return
+	}
+	h.Receipts <- ReadReceipt{ReaderID: readerID, SenderID: senderID, LastMessageID: lastMessageID}
+}
+
+// handleTyping forwards typing signals to the recipient, throttling repeated typing_start frames
+func (h *Hub) handleTyping(signal TypingSignal) {
+	key := typingKey{from: signal.FromUserID, to: signal.ToUserID}
+	now := time.Now()
+
+	// Users on either side of a block never see each other typing
+	if signal.Typing && h.blocksBetween(signal.FromUserID, signal.ToUserID) {
+		return
+	}
+
+	if !signal.Typing {
+		if _, active := h.typing[key]; active {
+			delete(h.typing, key)
+			h.sendTyping(key, signal.Nickname, TypingStop)
+		}
+		return
+	}
+
+	state, active := h.typing[key]
+	if !active {
+		state = &typingState{nickname: signal.Nickname}
+		h.typing[key] = state
+	}
+	state.expires = now.Add(typingTimeout)
+
+	// Only the expiry is refreshed while the recipient already shows the indicator
+	if active && now.Sub(state.forwarded) < typingThrottle {
+		return
+	}
+	state.forwarded = now
+	h.sendTyping(key, signal.Nickname, TypingStart)
+}
+
+// expireTyping sends typing_stop for every indicator that was not refreshed in time
+func (h *Hub) expireTyping(now time.Time) {
+	for key, state := range h.typing {
+		if now.After(state.expires) {
+			delete(h.typing, key)
+			h.sendTyping(key, state.nickname, TypingStop)
+		}
+	}
+}
+
+// clearTyping drops the indicator of a user without notifying the recipient,
+// used when the message itself arrives and makes the indicator obsolete
+func (h *Hub) clearTyping(fromUserID, toUserID int) {
+	delete(h.typing, typingKey{from: fromUserID, to: toUserID})
+}
+
+// stopTypingFrom ends every indicator of a user who went offline
+func (h *Hub) stopTypingFrom(userID int) {
+	for key, state := range h.typing {
+		if key.from == userID {
+			delete(h.typing, key)
+			h.sendTyping(key, state.nickname, TypingStop)
+		}
+	}
+}
+
+// sendTyping delivers a typing event to all connections of the recipient
+func (h *Hub) sendTyping(key typingKey, nickname string, msgType MessageType) {
+	message := NewMessage(msgType, key.from, key.to, "")
+	message.Nickname = nickname
+	h.sendToUser(key.to, message.ToJSON())
+}
+
+// handleReadReceipt tells every connection of the sender how far the recipient has read
+func (h *Hub) handleReadReceipt(receipt ReadReceipt) {
+	message := NewMessage(MessagesRead, receipt.ReaderID, receipt.SenderID, "")
+	message.MessageID = receipt.LastMessageID
+	h.sendToUser(receipt.SenderID, message.ToJSON())
+	// The reader's unread count for the sender dropped
+	h.updateSidebar(receipt.ReaderID, 0, receipt.SenderID)
+	log.Printf("[activity.go:handleReadReceipt] [DEBUG] User %d read messages of user %d up to %d", receipt.ReaderID, receipt.SenderID, receipt.LastMessageID)
+}
+
+// sendToUser sends data to every connection of a user, skipping connections with a full buffer
+func (h *Hub) sendToUser(userID int, data []byte) {
+	for _, client := range h.Users[userID] {
+		select {
+		case client.send <- data:
+		default:
+			log.Printf("[activity.go:sendToUser] Client channel full for user %d, skipping one connection", userID)
+		}
+	}
+}
@@ -13,26 +13,39 @@ type Client struct {
 	conn *websocket.Conn
 
 	// User information
-	userID   int
-	nickname string
+	userID    int
+	nickname  string
+	sessionID int // Session the connection was opened with
 
 	// Channels for communication with hub
 	send chan []byte // Channel for messages to send to this client
 
+	// Posts whose comment stream this connection follows (owned by the hub goroutine)
+	posts map[int]bool
+
+	// Recent rate limit violations of this connection (owned by the read pump)
+	violations []time.Time
+
+	// Last time a heartbeat reported user activity (owned by the hub goroutine)
+	lastActive time.Time
+
 	// Hub reference for cleanup
 	hub  *Hub
 	idex int
 }
 
 // NewClient creates a new client instance
-func NewClient(hub *Hub, conn *websocket.Conn, userID int, nickname string) *Client {
+func NewClient(hub *Hub, conn *websocket.Conn, userID int, nickname string, sessionID int) *Client {
 	return &Client{
-		conn:     conn,
-		userID:   userID,
-		nickname: nickname,
-		send:     make(chan []byte, 256), // Buffered channel to prevent blocking
-		hub:      hub,
-		idex:     0,
+		conn:       conn,
+		userID:     userID,
+		nickname:   nickname,
+		sessionID:  sessionID,
+		send:       make(chan []byte, 256), // Buffered channel to prevent blocking
+		posts:      make(map[int]bool),
+		lastActive: time.Now(), // Opening the page counts as activity
+		hub:        hub,
+		idex:       0,
 	}
 }
 
@@ -83,9 +96,22 @@ func (c *Client) readPump() {
 		message, err := FromJSON(data)
 		if err != nil {
 			log.Printf("[client.go:readPump] Client: Failed to parse message from user %d: %v", c.userID, err)
+			// Garbage frames count against the default limit so they cannot be used to flood
+			if _, open := c.allowFrame(""); !open {
+				break
+			}
 			continue // Skip invalid messages
 		}
 
+		// Drop frames over the limit of their type; abusive connections are closed
+		allowed, open := c.allowFrame(message.Type)
+		if !open {
+			break
+		}
+		if !allowed {
+			continue
+		}
+
 		// Set sender information
 		message.FromUserID = c.userID
 		message.Nickname = c.nickname
@@ -108,12 +134,44 @@ func (c *Client) readPump() {
 				Message:      *message,
 				SenderClient: c, // Include the sender client to exclude from message_from_me
 			}
+		case TypingStart, TypingStop:
+			c.hub.Typing <- TypingSignal{
+				FromUserID: c.userID,
+				ToUserID:   message.ToUserID,
+				Nickname:   c.nickname,
+				Typing:     message.Type == TypingStart,
+			}
+		case MarkRead:
+			c.markRead(message.ToUserID)
+		case HeartbeatMessage:
+			c.hub.Heartbeats <- Heartbeat{Client: c, Active: message.Active}
+		case SubscribePost, UnsubscribePost:
+			log.Printf("[client.go:readPump][DEBUG] User %d %s %d", c.userID, message.Type, message.PostID)
+			c.hub.Subscription <- PostSubscription{
+				Client:    c,
+				PostID:    message.PostID,
+				Subscribe: message.Type == SubscribePost,
+			}
 		default:
 			log.Printf("[client.go:readPump][DEBUG] Unknown message type from user %d: %s", c.userID, message.Type)
 		}
 	}
 }
 
+// markRead marks the messages from senderID to this user as read and,
+// if anything was unread, sends a read receipt to the sender
+func (c *Client) markRead(senderID int) {
+	if markReadFunc == nil {
+		return
+	}
+	lastID, err := markReadFunc(senderID, c.userID)
+	if err != nil {
+		log.Printf("[client.go:markRead] Failed to mark messages from %d to %d as read: %v", senderID, c.userID, err)
+		return
+	}
+	c.hub.NotifyMessagesRead(c.userID, senderID, lastID)
+}
+
 // [1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20]  k =4
 // []
 
@@ -0,0 +1,28 @@
+package ws
+
+import "log"
+
+// UserEvent is a pre-encoded event that must reach every connection of the given users
+type UserEvent struct {
+	UserIDs []int
+	Data    []byte
+}
+
+// NotifyConversationUpdated tells the given users that a conversation they are
+// (or were) part of was created, renamed or changed members, so they can reload it
+// Safe to call from any goroutine
+func (h *Hub) NotifyConversationUpdated(conversationID int, userIDs []int) {
+	if len(userIDs) == 0 {
+		return
+	}
+	message := Message{Type: ConversationUpdated, ConversationID: conversationID}
+	h.UserEvents <- UserEvent{UserIDs: userIDs, Data: message.ToJSON()}
+}
+
+// deliverUserEvent sends an event to every connection of its users
+func (h *Hub) deliverUserEvent(event UserEvent) {
+	for _, userID := range event.UserIDs {
+		h.sendToUser(userID, event.Data)
+	}
+	log.Printf("[conversations.go:deliverUserEvent] [DEBUG] Event delivered to %d users", len(event.UserIDs))
+}
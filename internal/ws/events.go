@@ -19,29 +19,57 @@ const (
 	LeaveMessage MessageType = "leave" // User leaving the messaging system
 
 	// Private messaging
-	PrivateMessage MessageType = "private_message" // Private message between users
+	PrivateMessage      MessageType = "private_message"      // Message to a user (to_user_id) or a conversation (conversation_id)
+	ConversationUpdated MessageType = "conversation_updated" // A group was created, renamed or changed members
+	SidebarUpdate       MessageType = "sidebar_update"       // Changed chat sidebar entries or presence, see models.SidebarUpdate
 
-	// Status notifications
-	UserOnline  MessageType = "user_online"  // User came online
-	UserOffline MessageType = "user_offline" // User went offline
-	OnlineUsers MessageType = "online_users" // List of online users
+	// Presence
+	PresenceChanged  MessageType = "presence"      // A user's presence changed, see models.Presence
+	PresenceList     MessageType = "presence_list" // Presence of every online user, sent on connect
+	HeartbeatMessage MessageType = "heartbeat"     // Client reports whether the user was active since the last one
 
 	// System messages
 	MessageDelivered MessageType = "message_delivered" // Confirmation of message delivery
+	MessageQueued    MessageType = "message_queued"    // Message stored for a recipient who is offline
 	MessageFailed    MessageType = "message_failed"    // Message delivery failed
 	MessageFromMe    MessageType = "message_from_me"   // Message sent from another connection of the same user
+	RateLimited      MessageType = "rate_limited"      // A frame was dropped; retry_after says when to try again
+
+	// Chat activity
+	TypingStart  MessageType = "typing_start"  // User started typing to to_user_id (expires unless repeated)
+	TypingStop   MessageType = "typing_stop"   // User stopped typing, or the indicator expired
+	MarkRead     MessageType = "mark_read"     // Client read the messages sent by to_user_id
+	MessagesRead MessageType = "messages_read" // Recipient read the sender's messages up to message_id
+
+	// Live forum feed
+	PostCreated     MessageType = "post_created"     // A new post was published (sent to everyone)
+	PostUpdated     MessageType = "post_updated"     // A post was edited or deleted (sent to everyone)
+	CommentCreated  MessageType = "comment_created"  // A new comment (sent to subscribers of its post)
+	CommentUpdated  MessageType = "comment_updated"  // A comment was deleted (sent to subscribers of its post)
+	SubscribePost   MessageType = "subscribe_post"   // Client starts following the comments of a post
+	UnsubscribePost MessageType = "unsubscribe_post" // Client stops following the comments of a post
+
+	// Moderation
+	ModerationNotice MessageType = "moderation_notice" // A moderator warned the user or hid their content
 )
 
 // Message represents a WebSocket message structure
 type Message struct {
-	Type       MessageType `json:"type"`                   // Type of message
-	Content    string      `json:"content,omitempty"`      // Message content (for private messages)
-	FromUserID int         `json:"from_user_id,omitempty"` // Sender user ID
-	ToUserID   int         `json:"to_user_id,omitempty"`   // Recipient user ID
-	Nickname   string      `json:"nickname,omitempty"`     // Sender's nickname
-	Timestamp  string      `json:"timestamp,omitempty"`    // ISO timestamp
-	MessageID  int         `json:"message_id,omitempty"`   // Database message ID
-	Offset     int         `json:"offset,omitempty"`       // For pagination (message history)
+	Type           MessageType     `json:"type"`                      // Type of message
+	Content        string          `json:"content,omitempty"`         // Message content (for private messages)
+	FromUserID     int             `json:"from_user_id,omitempty"`    // Sender user ID
+	ToUserID       int             `json:"to_user_id,omitempty"`      // Recipient user ID
+	Nickname       string          `json:"nickname,omitempty"`        // Sender's nickname
+	Timestamp      string          `json:"timestamp,omitempty"`       // ISO timestamp
+	MessageID      int             `json:"message_id,omitempty"`      // Database message ID
+	ConversationID int             `json:"conversation_id,omitempty"` // Conversation of the message (optional for direct messages)
+	Offset         int             `json:"offset,omitempty"`          // For pagination (message history)
+	TempID         string          `json:"temp_id,omitempty"`         // Client-side ID echoed back in confirmations
+	PostID         int             `json:"post_id,omitempty"`         // Post a feed event or subscription refers to
+	Payload        json.RawMessage `json:"payload,omitempty"`         // Full post or comment for feed events
+	RetryAfter     int             `json:"retry_after,omitempty"`     // Seconds to wait after a rate_limited event
+	Muted          bool            `json:"muted,omitempty"`           // The recipient muted the sender; show no notification
+	Active         bool            `json:"active,omitempty"`          // Heartbeats: the user interacted since the previous heartbeat
 }
 
 // PrivateMessageData is used internally for routing private messages through channels
@@ -50,15 +78,32 @@ type PrivateMessageData struct {
 	Data         []byte  // JSON-encoded message data
 	Message      Message // Parsed message for processing
 	SenderClient *Client // The client that sent the message (to exclude from message_from_me)
+	// Result receives the outcome when the message was sent over HTTP (see SendPrivateMessage)
+	Result chan<- SendResult
+}
+
+// SendResult is the outcome of a private message handled by the hub
+type SendResult struct {
+	Message   Message // The stored message with its database ID and conversation
+	Delivered bool    // At least one recipient connection received it; otherwise it is queued
+	Err       error
 }
 
 // ValidateMessage checks if a message has required fields based on its type
 func (m *Message) ValidateMessage() error {
 	switch m.Type {
 	case PrivateMessage:
-		if m.Content == "" || m.ToUserID == 0 || m.FromUserID == 0 {
+		if m.Content == "" || (m.ToUserID == 0 && m.ConversationID == 0) || m.FromUserID == 0 {
 			return logError("private message missing required fields")
 		}
+	case TypingStart, TypingStop, MarkRead:
+		if m.ToUserID == 0 || m.ToUserID == m.FromUserID {
+			return logError("chat activity missing a valid to_user_id")
+		}
+	case SubscribePost, UnsubscribePost:
+		if m.PostID <= 0 {
+			return logError("subscription missing post_id")
+		}
 	}
 	return nil
 }
@@ -0,0 +1,140 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// maxPostSubscriptions caps how many posts a single connection can follow at once
+const maxPostSubscriptions = 20
+
+// FeedEvent is a forum activity event routed through the hub
+type FeedEvent struct {
+	PostID          int    // Post the event belongs to
+	Data            []byte // JSON-encoded message
+	SubscribersOnly bool   // Deliver only to connections subscribed to PostID
+	AuthorID        int    // Author of the post or comment, skipped for users who blocked them
+}
+
+// PostSubscription asks the hub to start or stop sending a post's events to a client
+type PostSubscription struct {
+	Client    *Client
+	PostID    int
+	Subscribe bool
+}
+
+// PublishPostCreated tells every connected client about a new post
+func (h *Hub) PublishPostCreated(post *models.Post) {
+	h.publish(PostCreated, post.ID, post.UserID, post, false)
+}
+
+// PublishPostUpdated tells every connected client that a post was edited or deleted
+func (h *Hub) PublishPostUpdated(post *models.Post) {
+	h.publish(PostUpdated, post.ID, post.UserID, post, false)
+}
+
+// PublishCommentCreated sends a new comment to the clients viewing its post
+func (h *Hub) PublishCommentCreated(comment *models.Comment) {
+	h.publish(CommentCreated, comment.PostID, comment.UserID, comment, true)
+}
+
+// PublishCommentUpdated tells the clients viewing a post that one of its comments was deleted
+func (h *Hub) PublishCommentUpdated(comment *models.Comment) {
+	h.publish(CommentUpdated, comment.PostID, comment.UserID, comment, true)
+}
+
+// publish encodes a feed event and hands it to the hub goroutine
+func (h *Hub) publish(msgType MessageType, postID, authorID int, payload interface{}, subscribersOnly bool) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[feed.go:publish] Failed to encode %s payload for post %d: %v", msgType, postID, err)
+		return
+	}
+
+	message := Message{
+		Type:      msgType,
+		PostID:    postID,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Payload:   body,
+	}
+
+	h.Feed <- FeedEvent{
+		PostID:          postID,
+		Data:            message.ToJSON(),
+		SubscribersOnly: subscribersOnly,
+		AuthorID:        authorID,
+	}
+}
+
+// deliverFeedEvent sends a feed event to everyone or to the subscribers of its post,
+// leaving out users who blocked its author
+// Connections with a full buffer skip the event; the feed is a best-effort live view
+func (h *Hub) deliverFeedEvent(event FeedEvent) {
+	targets := h.clients
+	if event.SubscribersOnly {
+		targets = h.postSubscribers[event.PostID]
+	}
+
+	sent := 0
+	for client := range targets {
+		if h.blocks[client.userID][event.AuthorID] {
+			continue
+		}
+		select {
+		case client.send <- event.Data:
+			sent++
+		default:
+			log.Printf("[feed.go:deliverFeedEvent] Client channel full for user %d, skipping feed event", client.userID)
+		}
+	}
+	log.Printf("[feed.go:deliverFeedEvent] [DEBUG] Feed event for post %d sent to %d connections", event.PostID, sent)
+}
+
+// handleSubscription adds or removes a client from a post's subscriber set
+func (h *Hub) handleSubscription(sub PostSubscription) {
+	client := sub.Client
+	if _, ok := h.clients[client]; !ok {
+		return // Connection already gone
+	}
+
+	if !sub.Subscribe {
+		h.removeSubscription(client, sub.PostID)
+		return
+	}
+
+	if client.posts[sub.PostID] {
+		return
+	}
+	if len(client.posts) >= maxPostSubscriptions {
+		log.Printf("[feed.go:handleSubscription] User %d reached %d post subscriptions, ignoring post %d", client.userID, maxPostSubscriptions, sub.PostID)
+		return
+	}
+
+	if h.postSubscribers[sub.PostID] == nil {
+		h.postSubscribers[sub.PostID] = make(map[*Client]bool)
+	}
+	h.postSubscribers[sub.PostID][client] = true
+	client.posts[sub.PostID] = true
+	log.Printf("[feed.go:handleSubscription] [DEBUG] User %d subscribed to post %d", client.userID, sub.PostID)
+}
+
+// removeSubscription stops sending a post's events to a client
+func (h *Hub) removeSubscription(client *Client, postID int) {
+	delete(client.posts, postID)
+	if subscribers, ok := h.postSubscribers[postID]; ok {
+		delete(subscribers, client)
+		if len(subscribers) == 0 {
+			delete(h.postSubscribers, postID)
+		}
+	}
+}
+
+// removeAllSubscriptions drops every post subscription of a disconnecting client
+func (h *Hub) removeAllSubscriptions(client *Client) {
+	for postID := range client.posts {
+		h.removeSubscription(client, postID)
+	}
+}
@@ -1,9 +1,11 @@
 package ws
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"real-time-forum/internal/models"
 )
@@ -19,8 +21,30 @@ type Hub struct {
 	Unregister     chan *Client            // Unregister requests from clients
 	Broadcast      chan []byte             // Broadcast messages to all clients
 	PrivateMessage chan PrivateMessageData // Private messages between specific users
+	Feed           chan FeedEvent          // Post and comment events from the HTTP handlers
+	Subscription   chan PostSubscription   // Per-post subscribe/unsubscribe requests from clients
+	Typing         chan TypingSignal       // Typing indicators between users
+	Receipts       chan ReadReceipt        // Read receipts for the senders of private messages
+	UserEvents     chan UserEvent          // Events addressed to a set of users, e.g. conversation changes
+	ClientEvents   chan ClientEvent        // Events addressed to one connection, e.g. rate limit notices
+	Revocations    chan []int              // Session IDs whose connections must be closed
+	Relations      chan int                // Users whose blocks or mutes changed
+	SidebarChanges chan SidebarChange      // Conversations whose sidebar entries changed outside the hub
+	Heartbeats     chan Heartbeat          // Activity reported by connections
+	StatusChoices  chan StatusChoice       // Presence statuses picked by users
 	// User tracking
 	Users map[int][]*Client // userID -> array of clients mapping
+	// Feed tracking, only touched by the hub goroutine
+	postSubscribers map[int]map[*Client]bool // postID -> set of subscribed clients
+	// Active typing indicators, only touched by the hub goroutine
+	typing map[typingKey]*typingState
+	// Blocks and mutes of online users, only touched by the hub goroutine
+	blocks map[int]map[int]bool // userID -> users they blocked
+	mutes  map[int]map[int]bool // userID -> users they muted
+	// Presence of online users, written by the hub goroutine under Mu
+	presence map[int]models.Presence
+	// Statuses online users picked, only touched by the hub goroutine
+	chosenStatus map[int]string
 }
 
 // NewHub creates a new hub instance with initialized channels and data structures
@@ -34,12 +58,37 @@ func NewHub() *Hub {
 		Unregister:     make(chan *Client),            // Channel for client unregistration requests
 		Broadcast:      make(chan []byte),             // Channel for broadcasting messages to all clients
 		PrivateMessage: make(chan PrivateMessageData), // Channel for routing private messages between users
+		Feed:           make(chan FeedEvent),          // Channel for live post and comment events
+		Subscription:   make(chan PostSubscription),   // Channel for per-post subscription changes
+		Typing:         make(chan TypingSignal),       // Channel for typing indicators
+		Receipts:       make(chan ReadReceipt),        // Channel for read receipts
+		UserEvents:     make(chan UserEvent),          // Channel for events addressed to specific users
+		ClientEvents:   make(chan ClientEvent),        // Channel for events addressed to one connection
+		Revocations:    make(chan []int),              // Channel for revoked sessions
+		Relations:      make(chan int),                // Channel for changed blocks and mutes
+		SidebarChanges: make(chan SidebarChange),      // Channel for sidebar entries changed by the HTTP handlers
+		Heartbeats:     make(chan Heartbeat),          // Channel for connection heartbeats
+		StatusChoices:  make(chan StatusChoice),       // Channel for picked presence statuses
 		Users:          make(map[int][]*Client),       // Map for userID -> slice of client connections
+
+		postSubscribers: make(map[int]map[*Client]bool),   // Map for postID -> subscribed clients
+		typing:          make(map[typingKey]*typingState), // Map for active typing indicators
+		blocks:          make(map[int]map[int]bool),       // Map for userID -> blocked users
+		mutes:           make(map[int]map[int]bool),       // Map for userID -> muted users
+		presence:        make(map[int]models.Presence),    // Map for userID -> current presence
+		chosenStatus:    make(map[int]string),             // Map for userID -> picked status
 	}
 }
 
 // Run starts the hub and handles all WebSocket operations
 func (h *Hub) Run() {
+	// Periodically expire typing indicators whose typist went quiet
+	typingSweep := time.NewTicker(time.Second)
+	defer typingSweep.Stop()
+	// Periodically look for users who went idle or whose connections were dropped
+	presenceSweep := time.NewTicker(presenceSweepInterval)
+	defer presenceSweep.Stop()
+
 	for {
 		select {
 		case client := <-h.Register:
@@ -53,14 +102,52 @@ func (h *Hub) Run() {
 
 		case privateMsg := <-h.PrivateMessage:
 			h.handlePrivateMessage(privateMsg)
+
+		case event := <-h.Feed:
+			h.deliverFeedEvent(event)
+
+		case sub := <-h.Subscription:
+			h.handleSubscription(sub)
+
+		case signal := <-h.Typing:
+			h.handleTyping(signal)
+
+		case receipt := <-h.Receipts:
+			h.handleReadReceipt(receipt)
+
+		case event := <-h.UserEvents:
+			h.deliverUserEvent(event)
+
+		case event := <-h.ClientEvents:
+			h.deliverClientEvent(event)
+
+		case sessionIDs := <-h.Revocations:
+			h.closeSessions(sessionIDs)
+
+		case userID := <-h.Relations:
+			h.reloadRelations(userID)
+
+		case change := <-h.SidebarChanges:
+			h.handleSidebarChange(change)
+
+		case heartbeat := <-h.Heartbeats:
+			h.handleHeartbeat(heartbeat)
+
+		case choice := <-h.StatusChoices:
+			h.handleStatusChoice(choice)
+
+		case now := <-typingSweep.C:
+			h.expireTyping(now)
+
+		case <-presenceSweep.C:
+			h.sweepPresence()
 		}
 	}
 }
 
 // registerClient adds a new client to the hub and performs initialization tasks
 // @param client - The WebSocket client to register
-// Registers the client, updates user mappings, broadcasts online status, and sends online users list
-// registerClient registers the client, updates user mappings, broadcasts online status, and sends online users list
+// Registers the client, updates user mappings and presence, and sends the presence of online users
 func (h *Hub) registerClient(client *Client) {
 	log.Printf(
 		"[hub.go:registerClient] Registering client for user %d (%s)",
@@ -74,22 +161,110 @@ func (h *Hub) registerClient(client *Client) {
 	// Add client to the user's connections slice (thread-safe)
 	h.Mu.Lock()
 	h.Users[client.userID] = append(h.Users[client.userID], client)
+	firstConnection := len(h.Users[client.userID]) == 1
 	h.Mu.Unlock()
 
-	// Broadcast user online status to all connected clients
-	log.Printf(
-		"[hub.go:registerClient] [DEBUG] Broadcasting user online: %d (%s)",
-		client.userID,
-		client.nickname,
-	)
-	h.broadcastUserOnline(client.userID, client.nickname)
+	// Blocks decide who sees the user's presence, so they are loaded first
+	if firstConnection {
+		h.loadRelations(client.userID)
+		h.loadPresenceStatus(client.userID)
+	}
+
+	// A new connection brings the user online, or back from idle
+	h.refreshPresence(client.userID)
 
-	// Send the current online users list to the newly connected client
+	// Send the presence of everyone online to the newly connected client
 	log.Printf(
-		"[hub.go:registerClient] Sending online users list to new client %d",
+		"[hub.go:registerClient] Sending presence list to new client %d",
 		client.userID,
 	)
-	h.sendOnlineUsersList(client)
+	h.sendPresenceList(client)
+
+	// Push everything that arrived while the user was away
+	h.flushQueuedMessages(client)
+}
+
+// flushQueuedMessages sends every undelivered private message to a newly registered client, in order
+// Messages that do not fit in the client's send buffer stay queued for the next connection
+func (h *Hub) flushQueuedMessages(client *Client) {
+	if queuedMessagesFunc == nil || markDeliveredFunc == nil {
+		return
+	}
+
+	pending, err := queuedMessagesFunc(client.userID)
+	if err != nil {
+		log.Printf("[hub.go:flushQueuedMessages] Failed to load queued messages for user %d: %v", client.userID, err)
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	sent := make([]Message, 0, len(pending))
+	var hidden []Message
+	for _, pm := range pending {
+		message := Message{
+			Type:       PrivateMessage,
+			Content:    pm.Content,
+			FromUserID: pm.SenderID,
+			ToUserID:   pm.ReceiverID,
+			Nickname:   pm.SenderNickname,
+			Timestamp:  pm.CreatedAt.Format(time.RFC3339),
+			MessageID:  pm.ID,
+
+			ConversationID: pm.ConversationID,
+
+			Muted: h.hasMuted(client.userID, pm.SenderID),
+		}
+		if h.hidesMessage(client.userID, message) {
+			// Blocked while the user was away
+			hidden = append(hidden, message)
+			continue
+		}
+
+		select {
+		case client.send <- message.ToJSON():
+			sent = append(sent, message)
+			continue
+		default:
+		}
+
+		// Stop at the first message that does not fit to keep the order intact
+		log.Printf("[hub.go:flushQueuedMessages] Send buffer full for user %d, %d messages stay queued", client.userID, len(pending)-len(sent))
+		break
+	}
+
+	log.Printf("[hub.go:flushQueuedMessages] Flushed %d/%d queued messages to user %d, %d hidden by blocks", len(sent), len(pending), client.userID, len(hidden))
+	h.markDelivered(client.userID, sent)
+	// Hidden messages are settled without telling their senders, so they are not loaded again
+	h.recordDelivered(client.userID, hidden)
+}
+
+// markDelivered records the given messages as delivered to a recipient and tells their senders
+func (h *Hub) markDelivered(recipientID int, messages []Message) {
+	if !h.recordDelivered(recipientID, messages) {
+		return
+	}
+	for _, m := range messages {
+		h.sendMessageStatus(MessageDelivered, m)
+	}
+}
+
+// recordDelivered stores the delivered state of messages for a recipient and reports whether it succeeded
+func (h *Hub) recordDelivered(recipientID int, messages []Message) bool {
+	if markDeliveredFunc == nil || len(messages) == 0 {
+		return false
+	}
+
+	ids := make([]int, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.MessageID)
+	}
+	if err := markDeliveredFunc(recipientID, ids); err != nil {
+		log.Printf("[hub.go:recordDelivered] Failed to mark %d messages as delivered to user %d: %v", len(ids), recipientID, err)
+		return false
+	}
+	return true
 }
 
 // unregisterClient removes a client from the hub
@@ -101,47 +276,54 @@ func (h *Hub) unregisterClient(client *Client) {
 	)
 
 	h.Mu.Lock()
-	defer h.Mu.Unlock()
-
-	// Remove from global clients set
-	if _, ok := h.clients[client]; ok {
+	// A connection dropped by broadcastFiltered is already removed and closed
+	if h.clients[client] {
 		delete(h.clients, client)
-	}
-
-	// Remove this client from the user's slice
-	clients := h.Users[client.userID]
-	for i, c := range clients {
-		if c == client {
-			h.Users[client.userID] = append(clients[:i], clients[i+1:]...)
-			break
+		h.removeAllSubscriptions(client)
+
+		// Remove this client from the user's slice
+		clients := h.Users[client.userID]
+		for i, c := range clients {
+			if c == client {
+				h.Users[client.userID] = append(clients[:i], clients[i+1:]...)
+				break
+			}
 		}
-	}
 
-	// Close the client's send channel
-	close(client.send)
+		// Close the client's send channel
+		close(client.send)
 
-	// If user has no more active connections, remove user and broadcast offline
-	if len(h.Users[client.userID]) == 0 {
-		delete(h.Users, client.userID)
-		log.Printf(
-			"[hub.go:unregisterClient] [DEBUG] Broadcasting user offline: %d (%s)",
-			client.userID,
-			client.nickname,
-		)
-		h.broadcastUserOffline(client.userID, client.nickname)
+		// If user has no more active connections, remove user
+		if len(h.Users[client.userID]) == 0 {
+			delete(h.Users, client.userID)
+		}
 	}
+	h.Mu.Unlock()
+
+	// The last connection takes the user offline and records when they were last seen;
+	// otherwise the remaining connections may leave them idle
+	h.refreshPresence(client.userID)
 }
 
 // broadcastMessage sends a message to all connected clients
 // @param message - The byte array message to broadcast
 // Iterates through all clients and sends the message, removing unresponsive clients
 func (h *Hub) broadcastMessage(message []byte) {
+	h.broadcastFiltered(message, nil)
+}
+
+// broadcastFiltered sends a message to all connected clients except those skip
+// returns true for, removing unresponsive clients
+func (h *Hub) broadcastFiltered(message []byte, skip func(*Client) bool) {
 	log.Printf(
 		"[hub.go:broadcastMessage] [DEBUG] Broadcasting message to %d clients",
 		len(h.clients),
 	)
 
 	for client := range h.clients {
+		if skip != nil && skip(client) {
+			continue
+		}
 		select {
 		case client.send <- message:
 			log.Printf(
@@ -159,6 +341,7 @@ func (h *Hub) broadcastMessage(message []byte) {
 			// Remove from global clients set
 			close(client.send)
 			delete(h.clients, client)
+			h.removeAllSubscriptions(client)
 
 			// Remove ONLY this client from h.Users[userID]
 			h.Mu.Lock()
@@ -182,126 +365,232 @@ func (h *Hub) broadcastMessage(message []byte) {
 	}
 }
 
-// handlePrivateMessage routes a private message to the target user
+// Errors of SendPrivateMessage
+var (
+	// ErrBlockedPair is returned for direct messages between users where one blocked the other
+	ErrBlockedPair = errors.New("one of the users blocked the other")
+	// ErrSelfMessage is returned for direct messages addressed to the sender
+	ErrSelfMessage = errors.New("cannot message yourself")
+)
+
+// SendPrivateMessage hands a message sent over HTTP to the hub, which stores and
+// routes it like a message from a socket, and waits for the outcome.
+// Safe to call from any goroutine
+func (h *Hub) SendPrivateMessage(fromUserID int, nickname string, toUserID int, content string) SendResult {
+	result := make(chan SendResult, 1)
+	h.PrivateMessage <- PrivateMessageData{
+		ToUserID: toUserID,
+		Message: Message{
+			Type:       PrivateMessage,
+			FromUserID: fromUserID,
+			ToUserID:   toUserID,
+			Nickname:   nickname,
+			Content:    content,
+		},
+		Result: result,
+	}
+	return <-result
+}
+
+// reply reports the outcome of a message to a waiting SendPrivateMessage, if any
+func (data *PrivateMessageData) reply(delivered bool, err error) {
+	if data.Result != nil {
+		data.Result <- SendResult{Message: data.Message, Delivered: delivered, Err: err}
+	}
+}
+
+// handlePrivateMessage persists a message and routes it to every other member of its conversation
 func (h *Hub) handlePrivateMessage(data PrivateMessageData) {
 	log.Printf(
-		"[hub.go:handlePrivateMessage] [DEBUG] Handling private message from %d to %d",
+		"[hub.go:handlePrivateMessage] [DEBUG] Handling private message from %d to user %d / conversation %d",
 		data.Message.FromUserID,
 		data.Message.ToUserID,
+		data.Message.ConversationID,
 	)
 
-	clients, exists := h.Users[data.ToUserID]
-	if !exists || len(clients) == 0 {
-		// Target user is offline
+	// There is no direct conversation with yourself
+	if data.Message.ToUserID == data.Message.FromUserID {
+		log.Printf("[hub.go:handlePrivateMessage] Refusing message from user %d to themselves", data.Message.FromUserID)
+		h.sendMessageFailed(data.Message, "You cannot message yourself")
+		data.reply(false, ErrSelfMessage)
+		return
+	}
+
+	// Direct messages between users where one blocked the other are refused;
+	// the store checks again for conversations addressed by ID
+	if h.isBlockedPair(data.Message.FromUserID, data.Message.ToUserID) {
 		log.Printf(
-			"[hub.go:handlePrivateMessage][DEBUG] Target user %d is offline",
-			data.ToUserID,
+			"[hub.go:handlePrivateMessage] Refusing message from %d to user %d, one of them blocked the other",
+			data.Message.FromUserID,
+			data.Message.ToUserID,
 		)
-		h.sendMessageFailed(data.Message.FromUserID, data.Message.ToUserID)
+		h.sendMessageFailed(data.Message, "You cannot message this user")
+		data.reply(false, ErrBlockedPair)
 		return
 	}
 
-	delivered := false
+	// Persist the message first so every delivery carries the real database ID
+	if err := h.storePrivateMessage(&data); err != nil {
+		log.Printf(
+			"[hub.go:handlePrivateMessage] Failed to persist message from %d to user %d / conversation %d: %v",
+			data.Message.FromUserID,
+			data.Message.ToUserID,
+			data.Message.ConversationID,
+			err,
+		)
+		h.sendMessageFailed(data.Message, "Message could not be sent")
+		data.reply(false, err)
+		return
+	}
 
-	// Send the message to ALL active connections of the target user
-	for _, client := range clients {
-		select {
-		case client.send <- data.Data:
-			delivered = true
-		default:
-			// This specific connection is busy/full, skip it
+	// The message replaces the typing indicator on the recipient's side
+	h.clearTyping(data.Message.FromUserID, data.Message.ToUserID)
+
+	// The sender's other connections see the message whether or not the recipients are online
+	h.sendMessageFromMeToOtherConnections(data.Message.FromUserID, data.Message, data.SenderClient)
+
+	delivered := false
+	recipients := h.messageRecipients(data.Message)
+	for _, recipientID := range recipients {
+		if h.hidesMessage(recipientID, data.Message) {
+			// A group member who blocked the sender; settled so it is not queued for them
+			h.recordDelivered(recipientID, []Message{data.Message})
+			continue
+		}
+		payload := data.Data
+		if h.hasMuted(recipientID, data.Message.FromUserID) {
+			// Delivered all the same, flagged so the client skips the notification
+			muted := data.Message
+			muted.Muted = true
+			payload = muted.ToJSON()
+		}
+		if !h.deliverToUser(recipientID, payload) {
+			// Offline or busy, the message is delivered when they reconnect
 			log.Printf(
-				"[hub.go:handlePrivateMessage][DEBUG] Client channel full for user %d, skipping one connection",
-				data.ToUserID,
+				"[hub.go:handlePrivateMessage][DEBUG] User %d is offline or busy, message %d queued",
+				recipientID,
+				data.Message.MessageID,
 			)
+			continue
+		}
+		if h.recordDelivered(recipientID, []Message{data.Message}) {
+			delivered = true
 		}
 	}
 
 	if delivered {
-		// At least one connection received the message
+		// At least one recipient connection received the message
 		log.Printf(
-			"[hub.go:handlePrivateMessage][DEBUG] Message delivered to user %d, notifying sender %d",
-			data.ToUserID,
+			"[hub.go:handlePrivateMessage][DEBUG] Message %d delivered, notifying sender %d",
+			data.Message.MessageID,
 			data.Message.FromUserID,
 		)
-		h.sendMessageDelivered(data.Message.FromUserID, data.Message.MessageID)
-
-		// Check if sender has multiple connections and send "message_from_me" to other connections
-		h.sendMessageFromMeToOtherConnections(data.Message.FromUserID, data.Message, data.SenderClient)
+		h.sendMessageStatus(MessageDelivered, data.Message)
 	} else {
-		// No connection could receive the message
-		log.Printf(
-			"[hub.go:handlePrivateMessage][DEBUG] Message delivery failed to all connections of user %d",
-			data.ToUserID,
-		)
-		h.sendMessageFailed(data.Message.FromUserID, data.Message.ToUserID)
+		h.sendMessageStatus(MessageQueued, data.Message)
 	}
-}
+	data.reply(delivered, nil)
 
-// broadcastUserOnline notifies all clients that a user came online
-func (h *Hub) broadcastUserOnline(userID int, nickname string) {
-	message := NewMessage(UserOnline, userID, 0, "")
-	message.Nickname = nickname
-	h.broadcastMessage(message.ToJSON())
-}
-
-// broadcastUserOffline notifies all clients that a user went offline
-func (h *Hub) broadcastUserOffline(userID int, nickname string) {
-	message := NewMessage(UserOffline, userID, 0, "")
-	message.Nickname = nickname
-	h.broadcastMessage(message.ToJSON())
+	// The conversation moves to the top of every member's sidebar
+	h.updateSidebar(data.Message.FromUserID, data.Message.ConversationID, 0)
+	for _, recipientID := range recipients {
+		h.updateSidebar(recipientID, data.Message.ConversationID, 0)
+	}
 }
 
-// sendOnlineUsersList sends the current list of online users to a specific client
-func (h *Hub) sendOnlineUsersList(client *Client) {
-	h.Mu.RLock()
+// messageRecipients returns the users a stored message must be routed to, excluding the sender
+func (h *Hub) messageRecipients(message Message) []int {
+	if message.ToUserID != 0 || conversationMembersFunc == nil {
+		return []int{message.ToUserID}
+	}
 
-	// Use map as a set to avoid duplicate nicknames
-	seen := make(map[string]struct{})
-	onlineUsers := make([]string, 0)
+	members, err := conversationMembersFunc(message.ConversationID)
+	if err != nil {
+		log.Printf("[hub.go:messageRecipients] Failed to load members of conversation %d: %v", message.ConversationID, err)
+		return nil
+	}
+	recipients := make([]int, 0, len(members))
+	for _, userID := range members {
+		if userID != message.FromUserID {
+			recipients = append(recipients, userID)
+		}
+	}
+	return recipients
+}
 
-	for c := range h.clients {
-		if _, exists := seen[c.nickname]; exists {
-			continue
+// deliverToUser sends data to all active connections of a user and reports
+// whether at least one connection accepted it
+func (h *Hub) deliverToUser(userID int, data []byte) bool {
+	delivered := false
+	for _, client := range h.Users[userID] {
+		select {
+		case client.send <- data:
+			delivered = true
+		default:
+			// This specific connection is busy/full, skip it
+			log.Printf(
+				"[hub.go:deliverToUser][DEBUG] Client channel full for user %d, skipping one connection",
+				userID,
+			)
 		}
-		seen[c.nickname] = struct{}{}
-		onlineUsers = append(onlineUsers, c.nickname)
 	}
+	return delivered
+}
 
-	h.Mu.RUnlock()
+// storePrivateMessage saves the message through the injected store and
+// updates the routed data with the database ID, conversation and timestamp
+func (h *Hub) storePrivateMessage(data *PrivateMessageData) error {
+	if messageStoreFunc == nil {
+		return fmt.Errorf("message store not configured")
+	}
 
-	// Create message with online users list
-	onlineUsersJSON, _ := json.Marshal(onlineUsers)
-	message := Message{
-		Type:    OnlineUsers,
-		Content: string(onlineUsersJSON),
+	createdAt := time.Now()
+	pm := &models.PrivateMessage{
+		ConversationID: data.Message.ConversationID,
+		SenderID:       data.Message.FromUserID,
+		ReceiverID:     data.Message.ToUserID,
+		Content:        data.Message.Content,
+		CreatedAt:      createdAt,
+		IsRead:         false,
 	}
 
-	select {
-	case client.send <- message.ToJSON():
-		log.Printf(
-			"[hub.go:sendOnlineUsersList][DEBUG] Sent online users list to user %d: %v",
-			client.userID,
-			onlineUsers,
-		)
-	default:
-		log.Printf(
-			"[hub.go:sendOnlineUsersList] Could not send online users list to user %d",
-			client.userID,
-		)
+	id, err := messageStoreFunc(pm)
+	if err != nil {
+		return err
 	}
+
+	data.Message.MessageID = int(id)
+	data.Message.ConversationID = pm.ConversationID
+	data.Message.ToUserID = pm.ReceiverID // 0 for group messages
+	data.Message.Timestamp = createdAt.Format(time.RFC3339)
+	data.ToUserID = pm.ReceiverID
+	data.Data = data.Message.ToJSON()
+	return nil
 }
 
-// sendMessageDelivered notifies the sender that their message was delivered
-func (h *Hub) sendMessageDelivered(senderID int, messageID int) {
-	clients, exists := h.Users[senderID]
+// broadcastPresence sends a presence change of a user to every client not blocked from seeing it
+func (h *Hub) broadcastPresence(userID int, data []byte) {
+	h.broadcastFiltered(data, func(client *Client) bool {
+		return h.blocksBetween(client.userID, userID)
+	})
+}
+
+// sendMessageStatus notifies all connections of the sender about the state
+// of a stored message (delivered or queued), including its database ID
+func (h *Hub) sendMessageStatus(status MessageType, original Message) {
+	clients, exists := h.Users[original.FromUserID]
 	if !exists || len(clients) == 0 {
 		return
 	}
 
 	message := Message{
-		Type:      MessageDelivered,
-		MessageID: messageID,
+		Type:      status,
+		ToUserID:  original.ToUserID,
+		Timestamp: original.Timestamp,
+		MessageID: original.MessageID,
+		TempID:    original.TempID,
+
+		ConversationID: original.ConversationID,
 	}
 
 	data := message.ToJSON()
@@ -312,23 +601,29 @@ func (h *Hub) sendMessageDelivered(senderID int, messageID int) {
 			// sent successfully to this connection
 		default:
 			log.Printf(
-				"[hub.go:sendMessageDelivered] Could not send delivery confirmation to one connection of user %d",
-				senderID,
+				"[hub.go:sendMessageStatus] Could not send %s confirmation to one connection of user %d",
+				status,
+				original.FromUserID,
 			)
 		}
 	}
 }
 
-// sendMessageFailed notifies the sender that their message failed to deliver
-func (h *Hub) sendMessageFailed(senderID int, receiverID int) {
-	clients, exists := h.Users[senderID]
+// sendMessageFailed notifies the sender that their message could not be stored,
+// with the reason in the content
+func (h *Hub) sendMessageFailed(original Message, reason string) {
+	clients, exists := h.Users[original.FromUserID]
 	if !exists || len(clients) == 0 {
 		return
 	}
 
 	message := Message{
 		Type:     MessageFailed,
-		ToUserID: receiverID,
+		Content:  reason,
+		ToUserID: original.ToUserID,
+		TempID:   original.TempID,
+
+		ConversationID: original.ConversationID,
 	}
 
 	data := message.ToJSON()
@@ -340,7 +635,7 @@ func (h *Hub) sendMessageFailed(senderID int, receiverID int) {
 		default:
 			log.Printf(
 				"[hub.go:sendMessageFailed] Could not send failure notification to one connection of user %d",
-				senderID,
+				original.FromUserID,
 			)
 		}
 	}
@@ -351,7 +646,8 @@ func (h *Hub) sendMessageFromMeToOtherConnections(senderID int, originalMessage
 	clients, exists := h.Users[senderID]
 	log.Printf("[hub.go:sendMessageFromMeToOtherConnections] [DEBUG] Checking connections for user %d, exists: %v, client count: %d", senderID, exists, len(clients))
 
-	if !exists || len(clients) <= 1 {
+	// Messages sent over HTTP have no sender client, so every connection gets them
+	if !exists || len(clients) == 0 || (senderClient != nil && len(clients) == 1) {
 		log.Printf("[hub.go:sendMessageFromMeToOtherConnections] [DEBUG] No other connections to send to for user %d", senderID)
 		return
 	}
@@ -363,6 +659,8 @@ func (h *Hub) sendMessageFromMeToOtherConnections(senderID int, originalMessage
 		ToUserID:   originalMessage.ToUserID,
 		Timestamp:  originalMessage.Timestamp,
 		MessageID:  originalMessage.MessageID,
+
+		ConversationID: originalMessage.ConversationID,
 	}
 
 	data := message.ToJSON()
@@ -393,16 +691,56 @@ func (h *Hub) sendMessageFromMeToOtherConnections(senderID int, originalMessage
 	log.Printf("[hub.go:sendMessageFromMeToOtherConnections] [DEBUG] Sent message_from_me to %d connections for user %d", sentCount, senderID)
 }
 
+// messageStoreFunc stores the injected function used to persist private messages
+var messageStoreFunc func(*models.PrivateMessage) (int64, error)
+
+// SetMessageStore sets the function used to persist private messages
+// This allows dependency injection to avoid circular imports
+func SetMessageStore(storeFunc func(*models.PrivateMessage) (int64, error)) {
+	messageStoreFunc = storeFunc
+}
+
+// queuedMessagesFunc and markDeliveredFunc store the injected offline queue functions
+var (
+	queuedMessagesFunc func(int) ([]models.PrivateMessage, error)
+	markDeliveredFunc  func(int, []int) error
+)
+
+// SetDeliveryRepo sets the functions used to read the offline queue of a user
+// and to record messages as delivered to a recipient
+func SetDeliveryRepo(queuedFunc func(int) ([]models.PrivateMessage, error), deliveredFunc func(int, []int) error) {
+	queuedMessagesFunc = queuedFunc
+	markDeliveredFunc = deliveredFunc
+}
+
+// conversationMembersFunc stores the injected function listing the members of a conversation
+var conversationMembersFunc func(int) ([]int, error)
+
+// SetConversationRepo sets the function used to find the members a group message is routed to
+func SetConversationRepo(membersFunc func(int) ([]int, error)) {
+	conversationMembersFunc = membersFunc
+}
+
+// markReadFunc stores the injected function that marks a conversation as read
+var markReadFunc func(int, int) (int, error)
+
+// SetReadReceiptRepo sets the function used to mark the messages of a sender
+// (first argument) to a receiver (second argument) as read; it returns the
+// highest message ID marked, or 0 when nothing was unread
+func SetReadReceiptRepo(markFunc func(int, int) (int, error)) {
+	markReadFunc = markFunc
+}
+
 // messageRepoFunc stores the injected repository function
-var messageRepoFunc func(int, int, int, int) ([]models.PrivateMessage, error)
+var messageRepoFunc func(int, int, string, int) ([]models.PrivateMessage, string, error)
 
 // SetMessageRepo sets the message repository for database operations
 // This allows dependency injection to avoid circular imports
-func SetMessageRepo(repoFunc func(int, int, int, int) ([]models.PrivateMessage, error)) {
+func SetMessageRepo(repoFunc func(int, int, string, int) ([]models.PrivateMessage, string, error)) {
 	messageRepoFunc = repoFunc
 }
 
 // GetMessageRepoFunc returns the injected repository function
-func GetMessageRepoFunc() func(int, int, int, int) ([]models.PrivateMessage, error) {
+func GetMessageRepoFunc() func(int, int, string, int) ([]models.PrivateMessage, string, error) {
 	return messageRepoFunc
 }
@@ -0,0 +1,301 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+// fakeStore stands in for the repository functions the hub is given through the Set* functions.
+type fakeStore struct {
+	messages  []models.PrivateMessage
+	delivered map[int]map[int]bool // recipientID -> delivered message IDs
+	blocked   map[int][]int        // userID -> users they blocked
+	muted     map[int][]int        // userID -> users they muted
+	statuses  map[int]string       // userID -> picked presence status
+	online    map[int]bool         // userID -> recorded as connected
+}
+
+// installFakeStore injects a fresh fakeStore into the hub's repository hooks
+// and removes it when the test ends.
+func installFakeStore(t *testing.T) *fakeStore {
+	t.Helper()
+	s := &fakeStore{
+		delivered: make(map[int]map[int]bool),
+		blocked:   make(map[int][]int),
+		muted:     make(map[int][]int),
+		statuses:  make(map[int]string),
+		online:    make(map[int]bool),
+	}
+	SetMessageStore(s.store)
+	SetDeliveryRepo(s.queued, s.markDelivered)
+	SetRelationRepo(s.relations, s.isBlockedBetween)
+	SetPresenceRepo(s.status, s.setOnline)
+	t.Cleanup(func() {
+		SetMessageStore(nil)
+		SetDeliveryRepo(nil, nil)
+		SetRelationRepo(nil, nil)
+		SetPresenceRepo(nil, nil)
+	})
+	return s
+}
+
+func (s *fakeStore) store(pm *models.PrivateMessage) (int64, error) {
+	pm.ID = len(s.messages) + 1
+	pm.ConversationID = 1
+	s.messages = append(s.messages, *pm)
+	return int64(pm.ID), nil
+}
+
+func (s *fakeStore) queued(userID int) ([]models.PrivateMessage, error) {
+	var pending []models.PrivateMessage
+	for _, pm := range s.messages {
+		if pm.ReceiverID == userID && !s.delivered[userID][pm.ID] {
+			pending = append(pending, pm)
+		}
+	}
+	return pending, nil
+}
+
+func (s *fakeStore) markDelivered(recipientID int, ids []int) error {
+	if s.delivered[recipientID] == nil {
+		s.delivered[recipientID] = make(map[int]bool)
+	}
+	for _, id := range ids {
+		s.delivered[recipientID][id] = true
+	}
+	return nil
+}
+
+func (s *fakeStore) relations(userID int) ([]int, []int, error) {
+	return s.blocked[userID], s.muted[userID], nil
+}
+
+func (s *fakeStore) isBlockedBetween(userA, userB int) (bool, error) {
+	return contains(s.blocked[userA], userB) || contains(s.blocked[userB], userA), nil
+}
+
+func (s *fakeStore) status(userID int) (string, error) {
+	if status, ok := s.statuses[userID]; ok {
+		return status, nil
+	}
+	return models.PresenceOnline, nil
+}
+
+func (s *fakeStore) setOnline(userID int, online bool, _ time.Time) error {
+	s.online[userID] = online
+	return nil
+}
+
+func contains(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// connect registers a new connection of a user, without a socket behind it.
+func connect(h *Hub, userID int, nickname string) *Client {
+	client := NewClient(h, nil, userID, nickname, 0)
+	h.registerClient(client)
+	return client
+}
+
+// frames drains and decodes the frames waiting in a client's send buffer.
+func frames(t *testing.T, client *Client) []Message {
+	t.Helper()
+	var out []Message
+	for {
+		select {
+		case data, ok := <-client.send:
+			if !ok {
+				return out
+			}
+			message, err := FromJSON(data)
+			if err != nil {
+				t.Fatalf("decoding frame %s: %v", data, err)
+			}
+			out = append(out, *message)
+		default:
+			return out
+		}
+	}
+}
+
+// framesOf returns the frames of one type.
+func framesOf(messages []Message, msgType MessageType) []Message {
+	var out []Message
+	for _, m := range messages {
+		if m.Type == msgType {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// send routes a direct message from a connected client like its read pump does.
+func send(h *Hub, from *Client, toUserID int, content string) {
+	h.handlePrivateMessage(PrivateMessageData{
+		ToUserID: toUserID,
+		Message: Message{
+			Type:       PrivateMessage,
+			FromUserID: from.userID,
+			ToUserID:   toUserID,
+			Nickname:   from.nickname,
+			Content:    content,
+			TempID:     "t1",
+		},
+		SenderClient: from,
+	})
+}
+
+func TestQueuedMessagesFlushedOnReconnect(t *testing.T) {
+	store := installFakeStore(t)
+	h := NewHub()
+
+	alice := connect(h, 1, "alice")
+	frames(t, alice)
+	send(h, alice, 2, "are you there?")
+
+	if got := framesOf(frames(t, alice), MessageQueued); len(got) != 1 || got[0].TempID != "t1" {
+		t.Fatalf("sender got message_queued %+v, want one for t1", got)
+	}
+
+	bob := connect(h, 2, "bob")
+	got := framesOf(frames(t, bob), PrivateMessage)
+	if len(got) != 1 || got[0].Content != "are you there?" || got[0].FromUserID != 1 || got[0].MessageID != 1 {
+		t.Fatalf("recipient got %+v, want the queued message", got)
+	}
+	if !store.delivered[2][1] {
+		t.Errorf("flushed message not marked delivered")
+	}
+	if got := framesOf(frames(t, alice), MessageDelivered); len(got) != 1 || got[0].MessageID != 1 {
+		t.Errorf("sender got message_delivered %+v, want one for message 1", got)
+	}
+
+	// A second connection does not get the message again
+	other := connect(h, 2, "bob")
+	if got := framesOf(frames(t, other), PrivateMessage); len(got) != 0 {
+		t.Errorf("second connection got %+v, want no queued messages", got)
+	}
+}
+
+func TestBlockedPairRejected(t *testing.T) {
+	tests := []struct {
+		name         string
+		blocker      int
+		blocked      int
+		bobConnected bool
+	}{
+		{"recipient blocked sender, both online", 2, 1, true},
+		{"sender blocked recipient, both online", 1, 2, true},
+		{"recipient blocked sender, recipient offline", 2, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := installFakeStore(t)
+			store.blocked[tt.blocker] = []int{tt.blocked}
+			h := NewHub()
+
+			alice := connect(h, 1, "alice")
+			var bob *Client
+			if tt.bobConnected {
+				bob = connect(h, 2, "bob")
+				frames(t, bob)
+			}
+			frames(t, alice)
+
+			send(h, alice, 2, "hello")
+
+			failed := framesOf(frames(t, alice), MessageFailed)
+			if len(failed) != 1 || failed[0].TempID != "t1" {
+				t.Fatalf("sender got message_failed %+v, want one for t1", failed)
+			}
+			if len(store.messages) != 0 {
+				t.Errorf("stored %d messages, want none", len(store.messages))
+			}
+			if bob != nil {
+				if got := framesOf(frames(t, bob), PrivateMessage); len(got) != 0 {
+					t.Errorf("recipient got %+v, want nothing", got)
+				}
+			}
+		})
+	}
+}
+
+func TestSendPrivateMessageReportsBlocks(t *testing.T) {
+	store := installFakeStore(t)
+	store.blocked[2] = []int{1}
+	h := NewHub()
+	go h.Run()
+
+	result := h.SendPrivateMessage(1, "alice", 2, "hello")
+	if result.Err != ErrBlockedPair {
+		t.Fatalf("SendPrivateMessage error = %v, want ErrBlockedPair", result.Err)
+	}
+
+	result = h.SendPrivateMessage(1, "alice", 3, "hello")
+	if result.Err != nil || result.Delivered || result.Message.MessageID != 1 {
+		t.Fatalf("SendPrivateMessage to an offline user = %+v, want message 1 queued", result)
+	}
+}
+
+func TestQueuedMessageDroppedAfterBlock(t *testing.T) {
+	store := installFakeStore(t)
+	h := NewHub()
+
+	alice := connect(h, 1, "alice")
+	send(h, alice, 2, "before the block")
+	store.blocked[2] = []int{1}
+
+	bob := connect(h, 2, "bob")
+	if got := framesOf(frames(t, bob), PrivateMessage); len(got) != 0 {
+		t.Fatalf("recipient got %+v, want the message dropped", got)
+	}
+	if !store.delivered[2][1] {
+		t.Errorf("dropped message not marked delivered, it would be loaded again")
+	}
+	if got := framesOf(frames(t, alice), MessageDelivered); len(got) != 0 {
+		t.Errorf("sender got message_delivered %+v for a dropped message", got)
+	}
+}
+
+func TestMutedDelivery(t *testing.T) {
+	store := installFakeStore(t)
+	store.muted[2] = []int{1}
+	h := NewHub()
+
+	alice := connect(h, 1, "alice")
+	bob := connect(h, 2, "bob")
+	carol := connect(h, 3, "carol")
+	frames(t, bob)
+	frames(t, carol)
+
+	send(h, alice, 2, "to bob")
+	send(h, alice, 3, "to carol")
+
+	got := framesOf(frames(t, bob), PrivateMessage)
+	if len(got) != 1 || !got[0].Muted {
+		t.Errorf("muting recipient got %+v, want one message flagged muted", got)
+	}
+	got = framesOf(frames(t, carol), PrivateMessage)
+	if len(got) != 1 || got[0].Muted {
+		t.Errorf("other recipient got %+v, want one message not flagged", got)
+	}
+	if got := framesOf(frames(t, alice), MessageDelivered); len(got) != 2 {
+		t.Errorf("sender got %d message_delivered, want 2: muted messages are still delivered", len(got))
+	}
+
+	// Messages queued while away keep the flag
+	h.unregisterClient(bob)
+	send(h, alice, 2, "while away")
+	bob = connect(h, 2, "bob")
+	got = framesOf(frames(t, bob), PrivateMessage)
+	if len(got) != 1 || !got[0].Muted {
+		t.Errorf("flushed messages %+v, want one flagged muted", got)
+	}
+}
@@ -0,0 +1,25 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// NotifyModeration sends a moderation_notice to every connection of a user.
+// The payload describes the action, e.g. a moderation log entry.
+// Safe to call from any goroutine
+func (h *Hub) NotifyModeration(userID int, notice interface{}) {
+	body, err := json.Marshal(notice)
+	if err != nil {
+		log.Printf("[moderation.go:NotifyModeration] Failed to encode notice for user %d: %v", userID, err)
+		return
+	}
+	message := Message{
+		Type:      ModerationNotice,
+		ToUserID:  userID,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Payload:   body,
+	}
+	h.UserEvents <- UserEvent{UserIDs: []int{userID}, Data: message.ToJSON()}
+}
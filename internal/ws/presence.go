@@ -0,0 +1,237 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+const (
+	// idleAfter is how long all connections of a user may report no activity before they show as idle
+	idleAfter = 5 * time.Minute
+	// presenceSweepInterval is how often the hub looks for users who went idle or lost their connections
+	presenceSweepInterval = 15 * time.Second
+)
+
+// Heartbeat is a heartbeat frame of a connection
+type Heartbeat struct {
+	Client *Client
+	Active bool // The user interacted with the page since the previous heartbeat
+}
+
+// StatusChoice is a presence status a user picked for themselves
+type StatusChoice struct {
+	UserID int
+	Status string // online, away or dnd
+}
+
+var (
+	// presenceStatusFunc loads the status a user picked
+	presenceStatusFunc func(int) (string, error)
+	// userOnlineFunc records a user's first connection (true) or last disconnection (false) and when it happened
+	userOnlineFunc func(int, bool, time.Time) error
+)
+
+// SetPresenceRepo sets the functions used to load picked statuses and to record connections
+func SetPresenceRepo(statusFunc func(int) (string, error), onlineFunc func(int, bool, time.Time) error) {
+	presenceStatusFunc = statusFunc
+	userOnlineFunc = onlineFunc
+}
+
+// SetPresenceStatus applies a status a user picked to their connected presence
+// Safe to call from any goroutine
+func (h *Hub) SetPresenceStatus(userID int, status string) {
+	h.StatusChoices <- StatusChoice{UserID: userID, Status: status}
+}
+
+// PresenceStatus returns the status of a user, offline when they have no connection
+// Safe to call from any goroutine
+func (h *Hub) PresenceStatus(userID int) string {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	if presence, ok := h.presence[userID]; ok {
+		return presence.Status
+	}
+	return models.PresenceOffline
+}
+
+// PresenceStatuses returns the status of every connected user
+// Safe to call from any goroutine
+func (h *Hub) PresenceStatuses() map[int]string {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	statuses := make(map[int]string, len(h.presence))
+	for userID, presence := range h.presence {
+		statuses[userID] = presence.Status
+	}
+	return statuses
+}
+
+// loadPresenceStatus loads the status a user picked when their first connection registers
+func (h *Hub) loadPresenceStatus(userID int) {
+	status := models.PresenceOnline
+	if presenceStatusFunc != nil {
+		picked, err := presenceStatusFunc(userID)
+		if err != nil {
+			log.Printf("[presence.go:loadPresenceStatus] Failed to load presence status of user %d: %v", userID, err)
+		} else {
+			status = picked
+		}
+	}
+	h.chosenStatus[userID] = status
+}
+
+// handleHeartbeat records the activity reported by a connection and updates its user's presence
+func (h *Hub) handleHeartbeat(heartbeat Heartbeat) {
+	if !h.clients[heartbeat.Client] {
+		return // Already unregistered
+	}
+	if heartbeat.Active {
+		heartbeat.Client.lastActive = time.Now()
+	}
+	h.refreshPresence(heartbeat.Client.userID)
+}
+
+// handleStatusChoice applies a picked status; users who are not connected get it when they connect
+func (h *Hub) handleStatusChoice(choice StatusChoice) {
+	if len(h.Users[choice.UserID]) == 0 {
+		return
+	}
+	h.chosenStatus[choice.UserID] = choice.Status
+	h.refreshPresence(choice.UserID)
+}
+
+// computePresence derives the status of a user from their connections and the status they picked
+func (h *Hub) computePresence(userID int, now time.Time) string {
+	clients := h.Users[userID]
+	if len(clients) == 0 {
+		return models.PresenceOffline
+	}
+	if picked := h.chosenStatus[userID]; picked == models.PresenceAway || picked == models.PresenceDND {
+		return picked
+	}
+	for _, client := range clients {
+		if now.Sub(client.lastActive) < idleAfter {
+			return models.PresenceOnline
+		}
+	}
+	return models.PresenceIdle
+}
+
+// refreshPresence recomputes the status of a user and, when it changed,
+// records it and tells every user allowed to see it. The first connection and
+// the last disconnection are written to the database, the latter as last seen.
+func (h *Hub) refreshPresence(userID int) {
+	now := time.Now()
+	status := h.computePresence(userID, now)
+	previous, known := h.presence[userID]
+	if (known && previous.Status == status) || (!known && status == models.PresenceOffline) {
+		return
+	}
+
+	presence := models.Presence{UserID: userID, Nickname: previous.Nickname, Status: status}
+	if clients := h.Users[userID]; len(clients) > 0 {
+		presence.Nickname = clients[0].nickname
+	}
+
+	h.Mu.Lock()
+	if status == models.PresenceOffline {
+		delete(h.presence, userID)
+	} else {
+		h.presence[userID] = presence
+	}
+	h.Mu.Unlock()
+
+	if !known {
+		h.recordConnection(userID, true, now)
+	}
+	if status == models.PresenceOffline {
+		presence.LastSeenAt = &now
+		h.recordConnection(userID, false, now)
+		h.stopTypingFrom(userID)
+	}
+
+	log.Printf("[presence.go:refreshPresence] [DEBUG] User %d (%s) is now %s", userID, presence.Nickname, status)
+	h.broadcastPresence(userID, presenceJSON(presence))
+	h.broadcastPresence(userID, sidebarPresenceJSON(userID, status))
+
+	if status == models.PresenceOffline {
+		// Blocks were needed to filter the broadcast above
+		h.forgetRelations(userID)
+		delete(h.chosenStatus, userID)
+	}
+}
+
+// recordConnection writes a user's first connection or last disconnection through the injected repo
+func (h *Hub) recordConnection(userID int, online bool, at time.Time) {
+	if userOnlineFunc == nil {
+		return
+	}
+	if err := userOnlineFunc(userID, online, at); err != nil {
+		log.Printf("[presence.go:recordConnection] Failed to record presence of user %d: %v", userID, err)
+	}
+}
+
+// sweepPresence turns users idle whose connections stopped reporting activity,
+// and offline when their connections were dropped without unregistering
+func (h *Hub) sweepPresence() {
+	for userID := range h.presence {
+		h.refreshPresence(userID)
+	}
+}
+
+// sendPresenceList sends the presence of every connected user the client may see to a new connection
+func (h *Hub) sendPresenceList(client *Client) {
+	list := make([]models.Presence, 0, len(h.presence))
+	for userID, presence := range h.presence {
+		if h.blocksBetween(client.userID, userID) {
+			continue
+		}
+		list = append(list, presence)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UserID < list[j].UserID })
+
+	body, err := json.Marshal(list)
+	if err != nil {
+		log.Printf("[presence.go:sendPresenceList] Failed to encode presence list: %v", err)
+		return
+	}
+	message := Message{Type: PresenceList, Payload: body}
+
+	select {
+	case client.send <- message.ToJSON():
+		log.Printf("[presence.go:sendPresenceList] [DEBUG] Sent presence of %d users to user %d", len(list), client.userID)
+	default:
+		log.Printf("[presence.go:sendPresenceList] Could not send presence list to user %d", client.userID)
+	}
+}
+
+// presenceFor returns the presence of a user as another user may see it:
+// offline when either blocked the other
+func (h *Hub) presenceFor(viewerID, userID int) models.Presence {
+	presence, ok := h.presence[userID]
+	if !ok || h.blocksBetween(viewerID, userID) {
+		return models.Presence{UserID: userID, Nickname: presence.Nickname, Status: models.PresenceOffline}
+	}
+	return presence
+}
+
+// presenceJSON encodes a presence event
+func presenceJSON(presence models.Presence) []byte {
+	body, err := json.Marshal(presence)
+	if err != nil {
+		log.Printf("[presence.go:presenceJSON] Failed to encode presence of user %d: %v", presence.UserID, err)
+		return nil
+	}
+	message := Message{
+		Type:       PresenceChanged,
+		FromUserID: presence.UserID,
+		Nickname:   presence.Nickname,
+		Timestamp:  time.Now().Format(time.RFC3339),
+		Payload:    body,
+	}
+	return message.ToJSON()
+}
@@ -0,0 +1,102 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"real-time-forum/internal/models"
+)
+
+func TestPresenceTransitions(t *testing.T) {
+	store := installFakeStore(t)
+	h := NewHub()
+
+	alice := connect(h, 1, "alice")
+	bob := connect(h, 2, "bob")
+	frames(t, bob)
+
+	// lastPresence returns the status of alice last announced to bob
+	lastPresence := func() string {
+		t.Helper()
+		got := framesOf(frames(t, bob), PresenceChanged)
+		if len(got) == 0 {
+			return ""
+		}
+		return got[len(got)-1].Nickname + ":" + presenceStatus(t, got[len(got)-1])
+	}
+
+	steps := []struct {
+		name     string
+		apply    func()
+		want     string // status of alice
+		announce bool   // bob is told about the change
+	}{
+		{"connected", func() {}, models.PresenceOnline, false},
+		{"quiet heartbeat before idleAfter", func() {
+			h.handleHeartbeat(Heartbeat{Client: alice})
+		}, models.PresenceOnline, false},
+		{"no activity for idleAfter", func() {
+			alice.lastActive = time.Now().Add(-idleAfter - time.Second)
+			h.handleHeartbeat(Heartbeat{Client: alice})
+		}, models.PresenceIdle, true},
+		{"active again", func() {
+			h.handleHeartbeat(Heartbeat{Client: alice, Active: true})
+		}, models.PresenceOnline, true},
+		{"picked away", func() {
+			h.handleStatusChoice(StatusChoice{UserID: 1, Status: models.PresenceAway})
+		}, models.PresenceAway, true},
+		{"away is kept while active", func() {
+			h.handleHeartbeat(Heartbeat{Client: alice, Active: true})
+		}, models.PresenceAway, false},
+		{"picked online while inactive", func() {
+			alice.lastActive = time.Now().Add(-idleAfter - time.Second)
+			h.handleStatusChoice(StatusChoice{UserID: 1, Status: models.PresenceOnline})
+		}, models.PresenceIdle, true},
+		{"disconnected", func() {
+			h.unregisterClient(alice)
+		}, models.PresenceOffline, true},
+	}
+
+	for _, step := range steps {
+		step.apply()
+		if got := h.PresenceStatus(1); got != step.want {
+			t.Fatalf("%s: status = %s, want %s", step.name, got, step.want)
+		}
+		announced := lastPresence()
+		if step.announce && announced != "alice:"+step.want {
+			t.Errorf("%s: bob was told %q, want alice:%s", step.name, announced, step.want)
+		}
+		if !step.announce && announced != "" {
+			t.Errorf("%s: bob was told %q, want no change", step.name, announced)
+		}
+	}
+
+	if online, ok := store.online[1]; !ok || online {
+		t.Errorf("recorded online = %v (set %v), want the disconnection recorded", online, ok)
+	}
+}
+
+func TestPickedStatusLoadedOnConnect(t *testing.T) {
+	store := installFakeStore(t)
+	store.statuses[1] = models.PresenceAway
+	h := NewHub()
+
+	connect(h, 1, "alice")
+	if got := h.PresenceStatus(1); got != models.PresenceAway {
+		t.Fatalf("status = %s, want the picked away status", got)
+	}
+	if !store.online[1] {
+		t.Errorf("connection not recorded")
+	}
+}
+
+// presenceStatus decodes the status of a presence event.
+func presenceStatus(t *testing.T, m Message) string {
+	t.Helper()
+	var presence models.Presence
+	if err := json.Unmarshal(m.Payload, &presence); err != nil {
+		t.Fatalf("decoding presence %s: %v", m.Payload, err)
+	}
+	return presence.Status
+}
@@ -0,0 +1,80 @@
+package ws
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/ratelimit"
+
+	"github.com/gorilla/websocket"
+)
+
+// Per-user limits on incoming frames, shared by all connections of a user
+var (
+	wsLimits  = ratelimit.DefaultConfig()
+	wsLimiter = ratelimit.NewLimiter()
+)
+
+// SetRateLimits sets the per message type limits applied to incoming frames
+func SetRateLimits(cfg ratelimit.Config) {
+	wsLimits = cfg
+}
+
+// ClientEvent is a pre-encoded event for a single connection
+type ClientEvent struct {
+	Client *Client
+	Data   []byte
+}
+
+// deliverClientEvent sends an event to one connection if it is still registered
+func (h *Hub) deliverClientEvent(event ClientEvent) {
+	if !h.clients[event.Client] {
+		return // Already unregistered, its send channel is closed
+	}
+	select {
+	case event.Client.send <- event.Data:
+	default:
+		log.Printf("[ratelimit.go:deliverClientEvent] Client channel full for user %d, dropping event", event.Client.userID)
+	}
+}
+
+// allowFrame checks the limit of an incoming frame type for this user. Over the
+// limit, allowed is false and the connection gets a rate_limited event. open is
+// false once the connection exceeded the limits too often and was told it is
+// being closed; the caller must then stop reading.
+func (c *Client) allowFrame(msgType MessageType) (allowed, open bool) {
+	// Types without a policy of their own share the default bucket, so inventing
+	// types neither escapes the limit nor grows the limiter
+	bucket := wsLimits.WSBucket(string(msgType))
+	policy := wsLimits.WSPolicy(bucket)
+	key := "user:" + strconv.Itoa(c.userID) + ":" + bucket
+	allowed, wait := wsLimiter.Allow(key, policy)
+	if allowed {
+		return true, true
+	}
+
+	// Only violations within the window count towards closing the connection
+	now := time.Now()
+	cutoff := now.Add(-wsLimits.ViolationWindow())
+	recent := c.violations[:0]
+	for _, t := range c.violations {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	c.violations = append(recent, now)
+
+	if len(c.violations) > wsLimits.WSMaxViolations {
+		log.Printf("[ratelimit.go:allowFrame] Closing abusive connection of user %d (%d violations)", c.userID, len(c.violations))
+		c.closeWithReason(websocket.ClosePolicyViolation, "rate limit exceeded")
+		return false, false
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	log.Printf("[ratelimit.go:allowFrame] Rate limited %q frame from user %d (retry in %ds)", msgType, c.userID, retryAfter)
+	message := Message{Type: RateLimited, Content: string(msgType), RetryAfter: retryAfter}
+	c.hub.ClientEvents <- ClientEvent{Client: c, Data: message.ToJSON()}
+	return false, true
+}
@@ -0,0 +1,148 @@
+package ws
+
+import "log"
+
+// relationRepoFunc and blockCheckFunc store the injected block and mute lookups
+var (
+	relationRepoFunc func(int) ([]int, []int, error)
+	blockCheckFunc   func(int, int) (bool, error)
+)
+
+// SetRelationRepo sets the function loading the users a user blocked and muted,
+// and the function telling whether either of two users blocked the other
+func SetRelationRepo(loadFunc func(int) ([]int, []int, error), blockedFunc func(int, int) (bool, error)) {
+	relationRepoFunc = loadFunc
+	blockCheckFunc = blockedFunc
+}
+
+// RelationsChanged asks the hub to reload the blocks and mutes of a user
+// after they changed them through the API
+func (h *Hub) RelationsChanged(userID int) {
+	h.Relations <- userID
+}
+
+// loadRelations reads the blocks and mutes of a user who just came online
+func (h *Hub) loadRelations(userID int) {
+	if relationRepoFunc == nil {
+		return
+	}
+
+	blocked, muted, err := relationRepoFunc(userID)
+	if err != nil {
+		log.Printf("[relations.go:loadRelations] Failed to load blocks and mutes of user %d: %v", userID, err)
+		return
+	}
+	h.blocks[userID] = idSet(blocked)
+	h.mutes[userID] = idSet(muted)
+}
+
+// forgetRelations drops the blocks and mutes of a user who went offline
+func (h *Hub) forgetRelations(userID int) {
+	delete(h.blocks, userID)
+	delete(h.mutes, userID)
+}
+
+// reloadRelations refreshes the blocks and mutes of an online user, updates
+// the presence shown between them and every online user they blocked or
+// unblocked and refreshes the sidebar entries of every user whose state changed
+func (h *Hub) reloadRelations(userID int) {
+	if len(h.Users[userID]) == 0 {
+		return // Loaded when they connect
+	}
+
+	blocksBefore, mutesBefore := h.blocks[userID], h.mutes[userID]
+	h.loadRelations(userID)
+
+	changedBlocks := changedIDs(blocksBefore, h.blocks[userID])
+	changedEntries := changedIDs(mutesBefore, h.mutes[userID])
+	for otherID := range changedBlocks {
+		changedEntries[otherID] = true
+	}
+	for otherID := range changedEntries {
+		h.updateSidebar(userID, 0, otherID)
+	}
+
+	for otherID := range changedBlocks {
+		if len(h.Users[otherID]) == 0 {
+			continue
+		}
+		h.sendPresence(userID, otherID)
+		h.sendPresence(otherID, userID)
+	}
+}
+
+// changedIDs returns the IDs that are in exactly one of two sets
+func changedIDs(before, after map[int]bool) map[int]bool {
+	changed := make(map[int]bool)
+	for id := range before {
+		if !after[id] {
+			changed[id] = true
+		}
+	}
+	for id := range after {
+		if !before[id] {
+			changed[id] = true
+		}
+	}
+	return changed
+}
+
+// blocksBetween reports whether either of two online users blocked the other
+func (h *Hub) blocksBetween(userA, userB int) bool {
+	return h.blocks[userA][userB] || h.blocks[userB][userA]
+}
+
+// hasBlocked reports whether an online user blocked another user
+func (h *Hub) hasBlocked(userID, otherID int) bool {
+	return h.blocks[userID][otherID]
+}
+
+// hidesMessage reports whether a message must not be shown to a recipient: a
+// direct message across a block, or a group message from someone they blocked
+func (h *Hub) hidesMessage(recipientID int, message Message) bool {
+	if message.ToUserID != 0 {
+		return h.isBlockedPair(recipientID, message.FromUserID)
+	}
+	return h.hasBlocked(recipientID, message.FromUserID)
+}
+
+// hasMuted reports whether an online user muted another user
+func (h *Hub) hasMuted(userID, otherID int) bool {
+	return h.mutes[userID][otherID]
+}
+
+// sendPresence tells every connection of a user the presence of another user as they may see it
+func (h *Hub) sendPresence(toUserID, aboutUserID int) {
+	presence := h.presenceFor(toUserID, aboutUserID)
+	h.sendToUser(toUserID, presenceJSON(presence))
+	h.sendToUser(toUserID, sidebarPresenceJSON(aboutUserID, presence.Status))
+}
+
+// idSet turns a list of user IDs into a set
+func idSet(ids []int) map[int]bool {
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// isBlockedPair reports whether either of two users blocked the other, looking
+// at the hub's state when both are online and asking the database otherwise
+func (h *Hub) isBlockedPair(userA, userB int) bool {
+	if userA == 0 || userB == 0 {
+		return false
+	}
+	if len(h.Users[userA]) > 0 && len(h.Users[userB]) > 0 {
+		return h.blocksBetween(userA, userB)
+	}
+	if blockCheckFunc == nil {
+		return false
+	}
+	blocked, err := blockCheckFunc(userA, userB)
+	if err != nil {
+		log.Printf("[relations.go:isBlockedPair] Failed to check blocks between users %d and %d: %v", userA, userB, err)
+		return false // The message store checks again
+	}
+	return blocked
+}
@@ -0,0 +1,47 @@
+package ws
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// CloseSessions closes every connection opened with one of the given sessions,
+// used when sessions are revoked or logged out
+// Safe to call from any goroutine
+func (h *Hub) CloseSessions(sessionIDs []int) {
+	if len(sessionIDs) == 0 {
+		return
+	}
+	h.Revocations <- sessionIDs
+}
+
+// closeSessions sends a close frame to the connections of the revoked sessions.
+// Their read pumps then fail and unregister them as usual.
+func (h *Hub) closeSessions(sessionIDs []int) {
+	revoked := make(map[int]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		revoked[id] = true
+	}
+
+	for client := range h.clients {
+		if !revoked[client.sessionID] {
+			continue
+		}
+		log.Printf("[sessions.go:closeSessions] Closing connection of user %d, session %d was revoked", client.userID, client.sessionID)
+		// Closing happens outside the hub goroutine so a slow peer cannot stall the hub
+		go client.closeWithReason(websocket.ClosePolicyViolation, "session revoked")
+	}
+}
+
+// closeWithReason sends a close frame and closes the connection.
+// WriteControl and Close may be used concurrently with the write pump.
+func (c *Client) closeWithReason(code int, reason string) {
+	c.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(time.Second),
+	)
+	c.conn.Close()
+}
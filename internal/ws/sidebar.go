@@ -0,0 +1,87 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+
+	"real-time-forum/internal/models"
+)
+
+// SidebarChange asks the hub to push the current sidebar entry of a conversation to some of its members
+type SidebarChange struct {
+	ConversationID int
+	UserIDs        []int
+}
+
+// sidebarEntryFunc loads one entry of a user's sidebar by conversation or, with a 0 conversation, by user
+var sidebarEntryFunc func(userID, conversationID, partnerID int) (*models.SidebarEntry, error)
+
+// SetSidebarRepo sets the function used to load sidebar entries
+func SetSidebarRepo(entryFunc func(int, int, int) (*models.SidebarEntry, error)) {
+	sidebarEntryFunc = entryFunc
+}
+
+// NotifySidebarChanged pushes the sidebar entry of a conversation to the given
+// members, e.g. after a message sent over HTTP or a group marked as read
+// Safe to call from any goroutine
+func (h *Hub) NotifySidebarChanged(conversationID int, userIDs []int) {
+	if len(userIDs) == 0 {
+		return
+	}
+	h.SidebarChanges <- SidebarChange{ConversationID: conversationID, UserIDs: userIDs}
+}
+
+// handleSidebarChange sends the changed entry to every online user of the change
+func (h *Hub) handleSidebarChange(change SidebarChange) {
+	for _, userID := range change.UserIDs {
+		h.updateSidebar(userID, change.ConversationID, 0)
+	}
+}
+
+// updateSidebar sends one entry of a user's sidebar, looked up by conversation
+// or by the other user, to all their connections. Offline users load the whole
+// sidebar when they come back, so nothing is sent to them.
+func (h *Hub) updateSidebar(userID, conversationID, partnerID int) {
+	if sidebarEntryFunc == nil || len(h.Users[userID]) == 0 {
+		return
+	}
+	entry, err := sidebarEntryFunc(userID, conversationID, partnerID)
+	if err != nil {
+		log.Printf("[sidebar.go:updateSidebar] Failed to load sidebar entry %d/%d of user %d: %v", conversationID, partnerID, userID, err)
+		return
+	}
+	if entry == nil {
+		return // No longer a member
+	}
+	if entry.Kind == models.ConversationDirect {
+		entry.Status = h.presenceFor(userID, entry.UserID).Status
+		if entry.HidePresence {
+			entry.Status = models.PresenceOffline
+		}
+		entry.IsOnline = entry.Status != models.PresenceOffline
+	}
+	h.sendSidebarUpdate(userID, models.SidebarUpdate{Entries: []*models.SidebarEntry{entry}})
+}
+
+// sendSidebarUpdate sends a sidebar_update event to every connection of a user
+func (h *Hub) sendSidebarUpdate(userID int, update models.SidebarUpdate) {
+	h.sendToUser(userID, sidebarUpdateJSON(update))
+}
+
+// sidebarPresenceJSON encodes a sidebar_update that only changes the presence of a user
+func sidebarPresenceJSON(userID int, status string) []byte {
+	return sidebarUpdateJSON(models.SidebarUpdate{
+		Presence: []models.SidebarPresence{{UserID: userID, Status: status, IsOnline: status != models.PresenceOffline}},
+	})
+}
+
+// sidebarUpdateJSON encodes a sidebar_update event
+func sidebarUpdateJSON(update models.SidebarUpdate) []byte {
+	body, err := json.Marshal(update)
+	if err != nil {
+		log.Printf("[sidebar.go:sidebarUpdateJSON] Failed to encode sidebar update: %v", err)
+		return nil
+	}
+	message := Message{Type: SidebarUpdate, Payload: body}
+	return message.ToJSON()
+}
@@ -12,6 +12,10 @@ export async function handleCreateComment(event, postId) {
     const commentData = {
         content: content,
     };
+    const parentId = parseInt(formData.get('parent_id'), 10);
+    if (parentId) {
+        commentData.parent_id = parentId;
+    }
 
     try {
         const response = await fetch(`/api/posts/${postId}/comments`, {
@@ -24,6 +28,8 @@ export async function handleCreateComment(event, postId) {
 
         if (response.ok) {
             form.reset(); // Clear the form
+            form.elements['parent_id'].value = '';
+            form.elements['content'].placeholder = 'Write your comment here...';
             // Import loadAndRenderComments dynamically to avoid circular dependency
             import('../ui/postDetail.js').then(module => module.loadAndRenderComments(postId));
         } else {
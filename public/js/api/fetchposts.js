@@ -1,5 +1,7 @@
-export async function fetchPosts() {
-    const response = await fetch('/api/posts/');
+export async function fetchPosts(cursor = '', limit = 6) {
+    const params = new URLSearchParams({ limit });
+    if (cursor) params.set('cursor', cursor);
+    const response = await fetch(`/api/posts/?${params}`);
     if (!response.ok) {
         throw new Error(`HTTP error! status: ${response.status}`);
     }
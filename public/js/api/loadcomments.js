@@ -1,5 +1,7 @@
-export async function fetchComments(postId, page = 1) {
-    const res = await fetch(`/api/posts/${postId}/comments?page=${page}&limit=5`);
+export async function fetchComments(postId, cursor = '') {
+    const params = new URLSearchParams({ limit: 5 });
+    if (cursor) params.set('cursor', cursor);
+    const res = await fetch(`/api/posts/${postId}/comments?${params}`);
 
     if (!res.ok) {
         throw new Error(`Failed to fetch comments: ${res.status}`);
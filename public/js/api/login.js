@@ -1,6 +1,22 @@
 import { showNotification } from "../ui/notification.js";
 import { initializeChatConnection } from "../ui/chat.js";
 import { showMainFeedView } from "../ui/views.js";
+import { showTwoFactorForm } from "../ui/twofactor.js";
+
+// Enter the forum once every login step passed
+function finishLogin(result) {
+    const user = result.user
+    if (result.two_factor_setup_required) {
+        showNotification('Your role needs two-factor authentication: enable it on the /security page.');
+    } else {
+        showNotification('Login successful! Welcome back.', 'success');
+    }
+    // Initialize chat connection after successful login
+    initializeChatConnection(user);
+    // Use router to navigate to the main feed
+    window.history.pushState({}, "", "/");
+    showMainFeedView(user);
+}
 
 // Handle login form submission
 export async function handleLogin(e) {
@@ -12,6 +28,8 @@ export async function handleLogin(e) {
     for (const [key, value] of formData.entries()) {
         loginData[key.toLowerCase()] = value;
     }
+    // Checkboxes are only in the form data when checked, and the backend expects a boolean
+    loginData.remember_me = formData.get('remember_me') === 'on';
 
     try {
         const response = await fetch('/login', {
@@ -21,15 +39,12 @@ export async function handleLogin(e) {
         });
 
         const result = await response.json();
-        if (response.ok) {
-           const user = result.user
+        if (response.ok && result.two_factor_required) {
+            form.reset();
+            showTwoFactorForm(result.pending_token);
+        } else if (response.ok) {
             form.reset();
-            showNotification('Login successful! Welcome back.', 'success');
-            // Initialize chat connection after successful login
-            initializeChatConnection(user);
-            // Use router to navigate to the main feed
-            window.history.pushState({}, "", "/");
-            showMainFeedView(user);
+            finishLogin(result);
         } else {
             // Show error notification with backend message
             showNotification(result.message || 'Login failed. Please check your credentials.');
@@ -39,3 +54,26 @@ export async function handleLogin(e) {
         showNotification('Network error. Please check your connection and try again.');
     }
 }
+
+// Handle the second login step with the pending token from the password step
+export async function handleTwoFactorLogin(e, pendingToken) {
+    e.preventDefault();
+    const code = new FormData(e.target).get('code');
+
+    try {
+        const response = await fetch('/login/2fa', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ pending_token: pendingToken, code })
+        });
+        const result = await response.json();
+        if (response.ok) {
+            finishLogin(result);
+        } else {
+            showNotification(result.message || 'Invalid code.');
+        }
+    } catch (error) {
+        console.error('Two-factor login error:', error);
+        showNotification('Network error. Please check your connection and try again.');
+    }
+}
@@ -0,0 +1,58 @@
+import { showNotification } from "../ui/notification.js";
+
+// Handle the forgot password form: the server answers the same for unknown addresses
+export async function handleForgotPassword(e) {
+    e.preventDefault();
+    const form = e.target;
+    const email = new FormData(form).get('email');
+
+    try {
+        const response = await fetch('/api/auth/password-reset', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ email })
+        });
+        const result = await response.json();
+        if (response.ok) {
+            form.reset();
+            showNotification(result.message, 'success');
+        } else {
+            showNotification(result.message || 'Could not send the reset link.');
+        }
+    } catch (error) {
+        console.error('Password reset request error:', error);
+        showNotification('Network error. Please check your connection and try again.');
+    }
+}
+
+// Handle the new password form opened from a reset link
+export async function handleResetPassword(e) {
+    e.preventDefault();
+    const form = e.target;
+    const formData = new FormData(form);
+    const password = formData.get('password');
+    if (password !== formData.get('confirm')) {
+        showNotification('The passwords do not match.');
+        return;
+    }
+    const token = new URLSearchParams(window.location.search).get('token') || '';
+
+    try {
+        const response = await fetch('/api/auth/password-reset/confirm', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ token, password })
+        });
+        const result = await response.json();
+        if (response.ok) {
+            showNotification(result.message, 'success');
+            window.history.replaceState({}, "", "/login");
+            window.dispatchEvent(new PopStateEvent("popstate"));
+        } else {
+            showNotification(result.message || 'Could not reset the password.');
+        }
+    } catch (error) {
+        console.error('Password reset error:', error);
+        showNotification('Network error. Please check your connection and try again.');
+    }
+}
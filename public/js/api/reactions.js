@@ -0,0 +1,18 @@
+export async function toggleReaction(targetType, targetId, reaction, active) {
+    const options = active
+        ? { method: 'DELETE' }
+        : {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ target_type: targetType, target_id: targetId, reaction: reaction }),
+        };
+    const url = active
+        ? `/api/reactions?target_type=${targetType}&target_id=${targetId}&reaction=${reaction}`
+        : '/api/reactions';
+
+    const response = await fetch(url, options);
+    if (!response.ok) {
+        throw new Error(`Failed to update reaction. Status: ${response.status}`);
+    }
+    return await response.json();
+}
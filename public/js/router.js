@@ -1,15 +1,21 @@
 import { showLoginForm, showRegisterForm} from './ui/auth.js';
 import { showMainFeedView, show404View } from './ui/views.js';
 import { checkSession } from './api/checksession.js';
+import { showForgotPasswordForm, showResetPasswordForm } from './ui/password.js';
+import { showSecurityView } from './ui/twofactor.js';
+import { showNotification } from './ui/notification.js';
 
 // 1. Define Routes: Map paths to view-rendering functions.
 const routes = {
     '/': showMainFeedView,
     '/login': showLoginForm,
     '/register': showRegisterForm,
+    '/forgot-password': showForgotPasswordForm,
+    '/reset-password': showResetPasswordForm,
+    '/security': showSecurityView,
 };
 
-const protectedRoutes = ['/'];
+const protectedRoutes = ['/', '/security'];
 let lastValidPath = "/"
 export function recoverfrom404() {
     window.history.replaceState({}, "", lastValidPath)
@@ -18,6 +24,7 @@ export function recoverfrom404() {
 // 2. Core Router Logic: Handle location changes.
 export async function handleLocation()  {
    const path = window.location.pathname
+   reportEmailVerification()
    const user = await checkSession()
    console.log("__________________for user:", user, "for path", path);
    
@@ -34,7 +41,7 @@ export async function handleLocation()  {
    }
 
    if (!user) {
-    if (path == "/"){
+    if (protectedRoutes.includes(path)){
         window.history.replaceState({}, "", "/login")
         lastValidPath = "/login"
         showLoginForm();
@@ -58,6 +65,23 @@ export async function handleLocation()  {
 
 };
 
+// Shows the outcome of a verification link, which redirects to /?email_verified=1 or 0
+function reportEmailVerification() {
+   const params = new URLSearchParams(window.location.search)
+   const verified = params.get("email_verified")
+   if (verified === null) {
+    return
+   }
+   if (verified === "1") {
+    showNotification("Your email address is verified.", "success")
+   } else {
+    showNotification("This verification link is invalid or has expired.")
+   }
+   params.delete("email_verified")
+   const query = params.toString()
+   window.history.replaceState({}, "", window.location.pathname + (query ? "?" + query : ""))
+}
+
 // 3. Handle Navigation: Intercept link clicks.
 export function navigate(e) {
     // Check if the click was on an anchor tag.
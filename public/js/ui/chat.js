@@ -14,9 +14,19 @@ export function createChatPanel() {
     const chatHeader = document.createElement('div');
     chatHeader.className = 'chat-header';
 
-    // Left placeholder
+    // Left: the status we show to others
     const headerLeft = document.createElement('div');
     headerLeft.className = 'chat-header-left';
+    const presenceSelect = document.createElement('select');
+    presenceSelect.id = 'chat-presence-select';
+    presenceSelect.className = 'chat-presence-select';
+    [['online', 'Online'], ['away', 'Away'], ['dnd', 'Do not disturb']].forEach(([value, label]) => {
+        const option = document.createElement('option');
+        option.value = value;
+        option.textContent = label;
+        presenceSelect.appendChild(option);
+    });
+    headerLeft.appendChild(presenceSelect);
 
     // Center: title only (we'll center via CSS)
     const headerCenter = document.createElement('div');
@@ -140,6 +150,18 @@ export function setupChatEventListeners() {
 
     // No minimized chat bar handling needed for separate conversation bars
 
+    // Status picker
+    const presenceSelect = document.getElementById('chat-presence-select');
+    if (presenceSelect) {
+        // Clone and replace to remove existing listeners
+        const newPresenceSelect = presenceSelect.cloneNode(true);
+        presenceSelect.parentNode.replaceChild(newPresenceSelect, presenceSelect);
+        newPresenceSelect.addEventListener('change', () => {
+            chatWS.setPresenceStatus(newPresenceSelect.value);
+        });
+        chatWS.updatePresenceSelect();
+    }
+
     // Chat form submission
     const chatForm = document.getElementById('chat-form');
     if (chatForm) {
@@ -164,6 +186,15 @@ export function setupChatEventListeners() {
             }
         });
 
+        // Let the conversation partner see that we are typing
+        newChatInput.addEventListener('input', () => {
+            if (newChatInput.value.trim()) {
+                chatWS.notifyTyping();
+            } else if (chatWS.activeConversation) {
+                chatWS.stopTyping(chatWS.activeConversation.userId);
+            }
+        });
+
         // Mark messages as read when clicking on the input
         newChatInput.addEventListener('focus', () => {
             if (chatWS.activeConversation) {
@@ -196,9 +227,7 @@ function handleChatSubmit() {
         // Check if we're in private chat mode
         if (chatWS.activeConversation) {
             chatWS.sendPrivateMessage(message);
-            // Move the conversation user to the top and update the list
-            chatWS.moveUserToTop(chatWS.activeConversation.userId, true);
-            chatWS.updateUsersList();
+            // The server moves the conversation to the top with a sidebar_update
         }
         chatInput.value = ''; // Clear input
         chatInput.focus(); // Keep focus for next message
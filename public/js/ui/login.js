@@ -25,6 +25,16 @@ function createLoginForm() {
         form.appendChild(input);
     });
 
+    // "Remember me" keeps the session across browser restarts
+    const rememberLabel = document.createElement('label');
+    rememberLabel.className = 'remember-me';
+    const rememberInput = document.createElement('input');
+    rememberInput.type = 'checkbox';
+    rememberInput.name = 'remember_me';
+    rememberLabel.appendChild(rememberInput);
+    rememberLabel.appendChild(document.createTextNode(' Remember me'));
+    form.appendChild(rememberLabel);
+
     const button = document.createElement('button');
     button.type = 'submit';
     button.textContent = 'LOGIN';
@@ -40,6 +50,14 @@ function createLoginForm() {
     paragraph.appendChild(link);
     form.appendChild(paragraph);
 
+    const forgot = document.createElement('p');
+    forgot.className = 'subtxt';
+    const forgotLink = document.createElement('a');
+    forgotLink.href = '/forgot-password';
+    forgotLink.textContent = 'Forgot your password?';
+    forgot.appendChild(forgotLink);
+    form.appendChild(forgot);
+
     loginDiv.appendChild(form);
     return loginDiv;
 }
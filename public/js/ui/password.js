@@ -0,0 +1,76 @@
+import { showAuthView } from "./views.js";
+import { handleForgotPassword, handleResetPassword } from "../api/password.js";
+
+// --- Dynamic Form Creation ---
+function createPasswordCard(id, headingText, fields, buttonText) {
+    const card = document.createElement('div');
+    card.id = id;
+    card.className = 'card';
+
+    const heading = document.createElement('h2');
+    heading.textContent = headingText;
+    card.appendChild(heading);
+
+    const form = document.createElement('form');
+    form.id = id + 'Form';
+
+    fields.forEach(field => {
+        const input = document.createElement('input');
+        input.type = field.type;
+        input.name = field.name;
+        input.placeholder = field.placeholder;
+        input.required = true;
+        form.appendChild(input);
+    });
+
+    const button = document.createElement('button');
+    button.type = 'submit';
+    button.textContent = buttonText;
+    form.appendChild(button);
+
+    const paragraph = document.createElement('p');
+    paragraph.className = 'subtxt';
+    const link = document.createElement('a');
+    link.href = '/login';
+    link.textContent = 'Back to login';
+    paragraph.appendChild(link);
+    form.appendChild(paragraph);
+
+    card.appendChild(form);
+    return { card, form };
+}
+
+function showPasswordCard(card) {
+    showAuthView();
+    const container = document.getElementById("auth-container");
+    while (container.firstChild) {
+        container.removeChild(container.firstChild);
+    }
+    const title = document.createElement("h1");
+    title.textContent = "REAL TIME FORUM";
+    container.appendChild(title);
+    container.appendChild(card);
+
+    // Trigger fade-in animation
+    setTimeout(() => {
+        card.classList.add("fade-in");
+    }, 10);
+}
+
+// Asks for the address to mail a reset link to
+export function showForgotPasswordForm() {
+    const { card, form } = createPasswordCard('forgotPassword', 'FORGOT PASSWORD.',
+        [{ type: 'email', name: 'email', placeholder: 'Email' }], 'SEND RESET LINK');
+    form.addEventListener('submit', handleForgotPassword);
+    showPasswordCard(card);
+}
+
+// Sets a new password with the token from the reset link (/reset-password?token=...)
+export function showResetPasswordForm() {
+    const { card, form } = createPasswordCard('resetPassword', 'NEW PASSWORD.', [
+        { type: 'password', name: 'password', placeholder: 'New password' },
+        { type: 'password', name: 'confirm', placeholder: 'Repeat new password' },
+    ], 'SET PASSWORD');
+    form.addEventListener('submit', handleResetPassword);
+    showPasswordCard(card);
+}
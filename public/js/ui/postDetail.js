@@ -1,6 +1,8 @@
 import { fetchComments } from "../api/loadcomments.js";
 import { handleCreateComment } from "../api/createcomment.js";
 import { fetchPostDetails } from "../api/fetchpost.js";
+import { toggleReaction } from "../api/reactions.js";
+import chatWS from "../ws.js";
 
 
 function showMainFeedView() {
@@ -13,6 +15,10 @@ function showMainFeedView() {
     setTimeout(() => {
         mainFeedView.style.opacity = '1';
     }, 10);
+    // Stop the live comment stream of the post we are leaving
+    chatWS.unsubscribePost();
+    currentPostId = null;
+
     // Clear the single post view content
     while (singlePostView.firstChild) {
         singlePostView.removeChild(singlePostView.firstChild);
@@ -29,16 +35,26 @@ function showMainFeedView() {
     }
 }
 
-export function renderCommentsComponent(data, postId) {
+// Cursor of each comments page of the open post, so the arrows can go back and forth.
+// commentPageCursors[n] is the cursor that loads page n + 1.
+let commentPageCursors = [''];
+let currentCommentPage = 1;
+// Post shown in the single post view, used to match live comment events
+let currentPostId = null;
+
+export function renderCommentsComponent(data, postId, page = 1) {
     const section = document.getElementById("comments-section");
     if (!section) return;
 
     section.innerHTML = "";
+    currentCommentPage = page;
 
     const comments = data.comments || [];
     const total = data.total || 0;
-    const page = data.page || 1;
     const limit = data.limit || 5;
+    if (data.next_cursor) {
+        commentPageCursors[page] = data.next_cursor;
+    }
 
     if (!comments || comments.length === 0) {
         section.textContent = "No comments yet. Be the first to comment!";
@@ -74,18 +90,35 @@ export function renderCommentsComponent(data, postId) {
 
         const meta = document.createElement("p");
         meta.className = "comment-meta";
-        meta.innerHTML = `<strong>${comment.author.nickname}</strong> on ${date}`;
+        meta.innerHTML = `<strong>${comment.blocked ? "[blocked user]" : comment.author.nickname}</strong> on ${date}`;
 
         const content = document.createElement("p");
         content.className = "comment-content";
 
-        comment.content.split("\n").forEach((line, i) => {
-            if (i > 0) content.appendChild(document.createElement("br"));
-            content.appendChild(document.createTextNode(line));
-        });
+        if (comment.deleted || comment.hidden || comment.blocked) {
+            content.classList.add("comment-deleted");
+            content.textContent = comment.hidden ? "[hidden by a moderator]"
+                : comment.blocked ? "[from a user you blocked]" : "[deleted]";
+        } else {
+            comment.content.split("\n").forEach((line, i) => {
+                if (i > 0) content.appendChild(document.createElement("br"));
+                content.appendChild(document.createTextNode(line));
+            });
+        }
+
+        // Replies are indented under their parent
+        wrapper.style.marginLeft = `${(comment.depth || 0) * 24}px`;
+
+        const replyButton = document.createElement("button");
+        replyButton.type = "button";
+        replyButton.className = "comment-reply-btn";
+        replyButton.textContent = "Reply";
+        replyButton.onclick = () => startReply(comment);
 
         wrapper.appendChild(meta);
         wrapper.appendChild(content);
+        wrapper.appendChild(renderVoteBar('comment', comment.id, comment.reactions));
+        wrapper.appendChild(replyButton);
         list.appendChild(wrapper);
     });
 
@@ -112,7 +145,7 @@ export function renderCommentsComponent(data, postId) {
         const nextBtn = document.createElement("button");
         nextBtn.textContent = "→";
         nextBtn.className = "pagination-btn"; // Add class
-        nextBtn.disabled = page >= totalPages;
+        nextBtn.disabled = !data.has_more;
         if (!nextBtn.disabled) {
             nextBtn.onclick = () => loadAndRenderComments(postId, page + 1);
         }
@@ -129,7 +162,65 @@ export function renderCommentsComponent(data, postId) {
     }
 }
 
+// Up/down vote buttons with the current score. Clicking a vote you already
+// cast removes it; the server drops the opposite vote when you switch.
+function renderVoteBar(targetType, targetId, reactions) {
+    const bar = document.createElement("span");
+    bar.className = "vote-bar";
+    let counts = reactions || {};
+    let mine = null;
+
+    const upBtn = document.createElement("button");
+    upBtn.type = "button";
+    upBtn.textContent = "▲";
+    const score = document.createElement("span");
+    score.className = "vote-score";
+    const downBtn = document.createElement("button");
+    downBtn.type = "button";
+    downBtn.textContent = "▼";
+
+    const render = () => {
+        score.textContent = ` ${(counts.up || 0) - (counts.down || 0)} `;
+        upBtn.classList.toggle("active", mine === "up");
+        downBtn.classList.toggle("active", mine === "down");
+    };
+
+    const vote = async (reaction) => {
+        try {
+            const result = await toggleReaction(targetType, targetId, reaction, mine === reaction);
+            mine = mine === reaction ? null : reaction;
+            counts = result.counts || {};
+            render();
+        } catch (e) {
+            console.error('[ui/postDetail.js:renderVoteBar] Vote failed:', e);
+        }
+    };
+    upBtn.onclick = () => vote("up");
+    downBtn.onclick = () => vote("down");
+
+    bar.appendChild(upBtn);
+    bar.appendChild(score);
+    bar.appendChild(downBtn);
+    render();
+    return bar;
+}
+
+// Point the comment form at a comment so the next submission is a reply to it
+function startReply(comment) {
+    const form = document.getElementById('create-comment-form');
+    if (!form) return;
+
+    form.elements['parent_id'].value = comment.id;
+    const textarea = form.elements['content'];
+    textarea.placeholder = `Replying to ${comment.author.nickname}...`;
+    textarea.focus();
+}
+
 export async function loadAndRenderComments(postId, page = 1) {
+    if (page === 1) {
+        commentPageCursors = [''];
+    }
+
     const list = document.getElementById("comments-list");
     const section = document.getElementById("comments-section");
 
@@ -144,8 +235,8 @@ export async function loadAndRenderComments(postId, page = 1) {
     }
 
     try {
-        const data = await fetchComments(postId, page);
-        renderCommentsComponent(data, postId);
+        const data = await fetchComments(postId, commentPageCursors[page - 1] || '');
+        renderCommentsComponent(data, postId, page);
     } catch (e) {
         console.error(e);
         if (section) section.innerHTML = `<p class="error-message">Could not load comments.</p>`;
@@ -199,7 +290,7 @@ function renderPostDetails(post, postId) {
     // Post title
     const postTitle = document.createElement('h2');
     postTitle.className = 'post-detail-title';
-    postTitle.textContent = post.title;
+    postTitle.textContent = post.hidden ? '[hidden by a moderator]' : post.title;
     header.appendChild(postTitle);
 
     postDetailContainer.appendChild(header);
@@ -247,6 +338,8 @@ function renderPostDetails(post, postId) {
     }
     postDetailContainer.appendChild(categoriesDiv);
 
+    postDetailContainer.appendChild(renderVoteBar('post', post.id, post.reactions));
+
     // Comments section
     const commentsSection = document.createElement('div');
     commentsSection.id = 'comments-section';
@@ -276,6 +369,12 @@ function renderPostDetails(post, postId) {
     commentTextarea.required = true;
     commentForm.appendChild(commentTextarea);
 
+    // Set by the Reply buttons; empty for a top-level comment
+    const parentInput = document.createElement('input');
+    parentInput.type = 'hidden';
+    parentInput.name = 'parent_id';
+    commentForm.appendChild(parentInput);
+
     const submitButton = document.createElement('button');
     submitButton.type = 'submit';
     submitButton.textContent = 'Submit Comment';
@@ -290,7 +389,9 @@ function renderPostDetails(post, postId) {
 
     document.getElementById('create-comment-form').addEventListener('submit', (e) => handleCreateComment(e, postId));
 
-    // After rendering the post, fetch and render its comments
+    // After rendering the post, fetch and render its comments and follow new ones live
+    currentPostId = Number(postId);
+    chatWS.subscribePost(currentPostId);
     loadAndRenderComments(postId);
 }
 
@@ -303,3 +404,11 @@ export async function showSinglePostComponent(postId) {
         console.error('Could not load the post. Please try again.');
     }
 }
+
+// Live comments: refresh the visible page when a comment on the open post is added or deleted
+['forum:comment_created', 'forum:comment_updated'].forEach((name) => {
+    window.addEventListener(name, (event) => {
+        if (!currentPostId || event.detail.post_id !== currentPostId) return;
+        loadAndRenderComments(currentPostId, currentCommentPage);
+    });
+});
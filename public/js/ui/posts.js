@@ -76,61 +76,55 @@ function createPostComponent(post) {
     return postElement;
 }
 
-// State management for infinite scrolling
-let allPosts = [];
-let currentChunk = 0;
+// State management for cursor-based paging
+let nextCursor = '';
+let isLoadingMore = false;
 const CHUNK_SIZE = 6;
 
-function renderPostsChunk(posts, from, to) {
+function renderPosts(posts) {
     const postFeed = document.getElementById('post-feed');
     if (!postFeed) return;
-    
-    for (let i = from; i <= to && i < posts.length; i++) {
-        const postElement = createPostComponent(posts[i]);
-        postFeed.appendChild(postElement);
-    }
+
+    posts.forEach(post => postFeed.appendChild(createPostComponent(post)));
 }
 
-function renderInitialPosts() {
+function clearPostFeed() {
     const postFeed = document.getElementById('post-feed');
     if (!postFeed) return;
 
-    // Clear previous content
     while (postFeed.firstChild) {
         postFeed.removeChild(postFeed.firstChild);
     }
+}
 
-    if (allPosts.length === 0) {
-        const emptyState = document.createElement('p');
-        emptyState.setAttribute('data-empty-state', '');
-        emptyState.textContent = 'No posts yet. Be the first to create one!';
-        postFeed.appendChild(emptyState);
-        return;
+function updateLoadMoreButton() {
+    const existingButton = document.getElementById('load-more-button');
+    if (existingButton) {
+        existingButton.remove();
     }
+    if (!nextCursor) return;
 
-    // Render first chunk (posts 0-5)
-    currentChunk = 0;
-    renderPostsChunk(allPosts, 0, CHUNK_SIZE - 1);
+    const postFeed = document.getElementById('post-feed');
+    if (postFeed) {
+        const loadMoreButton = createLoadMoreButton();
+        postFeed.parentNode.insertBefore(loadMoreButton, postFeed.nextSibling);
+    }
 }
 
-function loadMorePosts() {
-    currentChunk++;
-    const startIndex = currentChunk * CHUNK_SIZE;
-    const endIndex = startIndex + CHUNK_SIZE - 1;
-    
-    // Check if we have more posts to load
-    if (startIndex >= allPosts.length) {
-        return; // No more posts to load
-    }
-    
-    renderPostsChunk(allPosts, startIndex, endIndex);
-    
-    // Hide load more button if no more posts
-    if (endIndex >= allPosts.length - 1) {
-        const loadMoreButton = document.getElementById('load-more-button');
-        if (loadMoreButton) {
-            loadMoreButton.style.display = 'none';
-        }
+async function loadMorePosts() {
+    // Prevent duplicate requests on rapid clicks
+    if (!nextCursor || isLoadingMore) return;
+    isLoadingMore = true;
+
+    try {
+        const page = await fetchPosts(nextCursor, CHUNK_SIZE);
+        renderPosts(page.posts || []);
+        nextCursor = page.next_cursor || '';
+        updateLoadMoreButton();
+    } catch (error) {
+        console.error('Error loading more posts:', error);
+    } finally {
+        isLoadingMore = false;
     }
 }
 
@@ -155,25 +149,25 @@ function createLoadMoreButton() {
 
 export async function loadPosts() {
     try {
-        // Load all posts once from backend
-        allPosts = await fetchPosts();
-        renderInitialPosts();
+        // Load the first page from the backend
+        const page = await fetchPosts('', CHUNK_SIZE);
+        const posts = page.posts || [];
+        nextCursor = page.next_cursor || '';
 
-        // Show Load More button if there are more posts
-        if (allPosts.length > CHUNK_SIZE) {
+        clearPostFeed();
+        if (posts.length === 0) {
             const postFeed = document.getElementById('post-feed');
             if (postFeed) {
-                // Remove existing load more button if any
-                const existingButton = document.getElementById('load-more-button');
-                if (existingButton) {
-                    existingButton.remove();
-                }
-                
-                // Add new load more button
-                const loadMoreButton = createLoadMoreButton();
-                postFeed.parentNode.insertBefore(loadMoreButton, postFeed.nextSibling);
+                const emptyState = document.createElement('p');
+                emptyState.setAttribute('data-empty-state', '');
+                emptyState.textContent = 'No posts yet. Be the first to create one!';
+                postFeed.appendChild(emptyState);
             }
         }
+        renderPosts(posts);
+
+        // Show Load More button if there are more posts
+        updateLoadMoreButton();
     } catch (error) {
         console.error('Error loading posts:', error);
         const postFeed = document.getElementById('post-feed');
@@ -185,6 +179,27 @@ export async function loadPosts() {
     }
 }
 
+// Live feed: new posts go on top, edited posts are replaced and deleted ones removed
+window.addEventListener('forum:post_created', (event) => {
+    const post = event.detail.payload;
+    const postFeed = document.getElementById('post-feed');
+    if (!postFeed || !post || postFeed.querySelector(`[data-post-id="${post.id}"]`)) return;
 
+    const emptyState = postFeed.querySelector('[data-empty-state]');
+    if (emptyState) emptyState.remove();
+    postFeed.insertBefore(createPostComponent(post), postFeed.firstChild);
+});
 
+window.addEventListener('forum:post_updated', (event) => {
+    const post = event.detail.payload;
+    const postFeed = document.getElementById('post-feed');
+    if (!postFeed || !post) return;
 
+    const existing = postFeed.querySelector(`[data-post-id="${post.id}"]`);
+    if (!existing) return;
+    if (post.deleted || post.hidden) {
+        existing.remove();
+    } else {
+        existing.replaceWith(createPostComponent(post));
+    }
+});
@@ -0,0 +1,160 @@
+import { showAuthView } from "./views.js";
+import { showNotification } from "./notification.js";
+import { handleTwoFactorLogin } from "../api/login.js";
+
+function resetAuthContainer() {
+    showAuthView();
+    const container = document.getElementById("auth-container");
+    while (container.firstChild) {
+        container.removeChild(container.firstChild);
+    }
+    const title = document.createElement("h1");
+    title.textContent = "REAL TIME FORUM";
+    container.appendChild(title);
+    return container;
+}
+
+function createCodeForm(id, placeholder, buttonText) {
+    const form = document.createElement('form');
+    form.id = id;
+    const input = document.createElement('input');
+    input.type = 'text';
+    input.name = 'code';
+    input.placeholder = placeholder;
+    input.autocomplete = 'one-time-code';
+    input.required = true;
+    form.appendChild(input);
+    const button = document.createElement('button');
+    button.type = 'submit';
+    button.textContent = buttonText;
+    form.appendChild(button);
+    return form;
+}
+
+// Second login step: the password was accepted and the server wants a code
+export function showTwoFactorForm(pendingToken) {
+    const container = resetAuthContainer();
+    const card = document.createElement('div');
+    card.id = 'twoFactor';
+    card.className = 'card';
+
+    const heading = document.createElement('h2');
+    heading.textContent = 'TWO-FACTOR CODE.';
+    card.appendChild(heading);
+
+    const form = createCodeForm('twoFactorForm', 'Authenticator or recovery code', 'VERIFY');
+    form.addEventListener('submit', (e) => handleTwoFactorLogin(e, pendingToken));
+    const back = document.createElement('p');
+    back.className = 'subtxt';
+    const link = document.createElement('a');
+    link.href = '/login';
+    link.textContent = 'Back to login';
+    back.appendChild(link);
+    form.appendChild(back);
+
+    card.appendChild(form);
+    container.appendChild(card);
+    setTimeout(() => card.classList.add("fade-in"), 10);
+}
+
+async function postJSON(url, body) {
+    const response = await fetch(url, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body || {})
+    });
+    return { ok: response.ok, result: await response.json() };
+}
+
+function showRecoveryCodes(card, codes) {
+    const note = document.createElement('p');
+    note.textContent = 'Save these recovery codes now. Each works once if you lose your authenticator:';
+    const list = document.createElement('pre');
+    list.textContent = codes.join('\n');
+    card.appendChild(note);
+    card.appendChild(list);
+}
+
+// Two-factor settings of the signed-in user (/security)
+export async function showSecurityView() {
+    const container = resetAuthContainer();
+    const card = document.createElement('div');
+    card.id = 'security';
+    card.className = 'card';
+    const heading = document.createElement('h2');
+    heading.textContent = 'TWO-FACTOR AUTHENTICATION.';
+    card.appendChild(heading);
+    container.appendChild(card);
+
+    const response = await fetch('/api/auth/2fa');
+    const status = await response.json();
+    if (!response.ok) {
+        showNotification(status.message || 'Could not load your security settings.');
+        return;
+    }
+
+    const info = document.createElement('p');
+    info.className = 'subtxt';
+    if (status.enabled) {
+        info.textContent = `Enabled. ${status.recovery_codes_left} recovery codes left.`;
+        card.appendChild(info);
+        const form = createCodeForm('recoveryCodesForm', 'Current code', 'NEW RECOVERY CODES');
+        form.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            const { ok, result } = await postJSON('/api/auth/2fa/recovery-codes', { code: form.code.value });
+            if (!ok) {
+                showNotification(result.message || 'Could not replace the recovery codes.');
+                return;
+            }
+            form.remove();
+            showRecoveryCodes(card, result.recovery_codes);
+        });
+        card.appendChild(form);
+    } else {
+        info.textContent = status.required
+            ? 'Your role needs two-factor authentication before its privileges apply.'
+            : 'Protect your account with an authenticator app.';
+        card.appendChild(info);
+        const start = document.createElement('button');
+        start.textContent = 'SET UP';
+        start.addEventListener('click', async () => {
+            const { ok, result } = await postJSON('/api/auth/2fa/setup');
+            if (!ok) {
+                showNotification(result.message || 'Could not start the setup.');
+                return;
+            }
+            start.remove();
+            const secret = document.createElement('p');
+            secret.textContent = `Add this key to your authenticator app: ${result.secret}`;
+            const uri = document.createElement('a');
+            uri.href = result.provisioning_uri;
+            uri.textContent = 'Open in authenticator';
+            card.appendChild(secret);
+            card.appendChild(uri);
+
+            const form = createCodeForm('enableTwoFactorForm', 'Code from the app', 'ENABLE');
+            form.addEventListener('submit', async (e) => {
+                e.preventDefault();
+                const enabled = await postJSON('/api/auth/2fa/enable', { code: form.code.value });
+                if (!enabled.ok) {
+                    showNotification(enabled.result.message || 'Invalid code.');
+                    return;
+                }
+                form.remove();
+                showNotification(enabled.result.message, 'success');
+                showRecoveryCodes(card, enabled.result.recovery_codes);
+            });
+            card.appendChild(form);
+        });
+        card.appendChild(start);
+    }
+
+    const back = document.createElement('p');
+    back.className = 'subtxt';
+    const link = document.createElement('a');
+    link.href = '/';
+    link.textContent = 'Back to the forum';
+    back.appendChild(link);
+    card.appendChild(back);
+    setTimeout(() => card.classList.add("fade-in"), 10);
+}
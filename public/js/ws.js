@@ -8,8 +8,8 @@ class ChatWebSocket {
         this.isConnected = false;
         this.connectionStatus = 'disconnected';
         this.onlineUsers = [];
-        this.allUsers = []; // All registered users
-        this.conversations = []; // Recent conversations
+        this.presence = {}; // userId -> presence of every connected user we can see
+        this.sidebar = []; // Sidebar entries (conversations, then other users) in the server's order
         this.activeConversation = null; // Currently selected conversation
         this.privateMessages = {}; // userId -> messages array
         this.currentUser = null;
@@ -19,8 +19,14 @@ class ChatWebSocket {
         this.isChatOpen = false;
         this.messageIdCounter = 0;
 
-        this.loadUsersIntervalId = null; // Interval ID for periodic loadAllUsers calls
-        this.SortedUserslist = null
+        this.typingSentAt = 0; // When we last told the server we are typing
+        this.typingStopTimer = null; // Sends typing_stop after a pause in typing
+        this.typingIndicatorTimer = null; // Hides the partner's indicator if typing_stop never arrives
+        this.subscribedPostId = null; // Post whose comment stream we follow, restored after reconnects
+
+        this.heartbeatTimer = null; // Sends a heartbeat every 30 seconds while connected
+        this.activeSinceHeartbeat = true; // The user used the page since the last heartbeat
+        this.activityTracked = false; // Activity listeners are added once per page
     }
 
     // Initialize WebSocket connection
@@ -51,13 +57,6 @@ class ChatWebSocket {
         console.log('[ws.js:connect] [DEBUG] Connecting to WebSocket URL:', wsUrl);
         this.ws = new WebSocket(wsUrl);
 
-        // Load all users list when connecting
-        console.log('[ws.js:connect] [DEBUG] Calling loadAllUsers');
-        this.loadAllUsers();
-
-    // Load conversations when connecting
-        console.log('[ws.js:connect] [DEBUG] Calling loadConversations');
-        this.loadConversations();
         this.ws.onopen = (event) => {
             console.log('[ws.js:connect] [DEBUG] WebSocket connected successfully');
             this.isConnected = true;
@@ -70,11 +69,15 @@ class ChatWebSocket {
             console.log('[ws.js:connect] [DEBUG] Sending join message');
             this.sendJoinMessage();
 
-            // Start periodic loadAllUsers calls every 10 seconds
-            this.loadUsersIntervalId = setInterval(() => {
-                this.loadAllUsers();
-                this.loadConversations();
-            }, 5000); // 10 seconds
+            // Subscriptions live on the connection, so follow the open post again
+            if (this.subscribedPostId) {
+                this.send('subscribe_post', { post_id: this.subscribedPostId });
+            }
+
+            // The server pushes sidebar changes from now on; load what happened before
+            this.loadSidebar();
+
+            this.startHeartbeat();
         };
 
         this.ws.onmessage = (event) => {
@@ -82,9 +85,6 @@ class ChatWebSocket {
             try {
                 const data = JSON.parse(event.data);
                 console.log('[ws.js:connect] [DEBUG] Parsed WebSocket message:', data);
-                if (data.type == 'user_online' && !this.allUsers.includes(data.nickname)) {
-                    this.loadAllUsers();
-                }
                 this.handleMessage(data);
             } catch (error) {
                 console.error('[ws.js:connect] [DEBUG] Error parsing WebSocket message:', error);
@@ -96,12 +96,7 @@ class ChatWebSocket {
             this.isConnected = false;
             this.connectionStatus = 'disconnected';
             this.updateConnectionStatus();
-
-            // Stop periodic loadAllUsers calls
-            if (this.loadUsersIntervalId) {
-                clearInterval(this.loadUsersIntervalId);
-                this.loadUsersIntervalId = null;
-            }
+            this.stopHeartbeat();
 
             // Attempt reconnection if not intentional disconnect
             if (event.code !== 1000) { // 1000 = normal closure
@@ -119,12 +114,7 @@ class ChatWebSocket {
 
     // Disconnect WebSocket
     disconnect() {
-        // Stop periodic loadAllUsers calls
-        if (this.loadUsersIntervalId) {
-            clearInterval(this.loadUsersIntervalId);
-            this.loadUsersIntervalId = null;
-        }
-
+        this.stopHeartbeat();
         if (this.ws) {
             this.ws.close(1000, 'User disconnected');
             this.ws = null;
@@ -144,6 +134,66 @@ class ChatWebSocket {
         }
     }
 
+    // Send a heartbeat now and every 30 seconds; the server shows us as idle
+    // after 5 minutes of heartbeats without activity
+    startHeartbeat() {
+        this.stopHeartbeat();
+        this.trackActivity();
+        this.sendHeartbeat();
+        this.heartbeatTimer = setInterval(() => this.sendHeartbeat(), 30000);
+    }
+
+    stopHeartbeat() {
+        if (this.heartbeatTimer) {
+            clearInterval(this.heartbeatTimer);
+            this.heartbeatTimer = null;
+        }
+    }
+
+    sendHeartbeat() {
+        this.send('heartbeat', { active: this.activeSinceHeartbeat && !document.hidden });
+        this.activeSinceHeartbeat = false;
+    }
+
+    // Watch for input on a visible page; coming back while shown as idle sends a heartbeat right away
+    trackActivity() {
+        if (this.activityTracked) return;
+        this.activityTracked = true;
+
+        const onActivity = () => {
+            if (document.hidden) return;
+            this.activeSinceHeartbeat = true;
+            const own = this.presence[this.currentUser?.id];
+            if (this.heartbeatTimer && own && own.status === 'idle') {
+                this.sendHeartbeat();
+            }
+        };
+        ['keydown', 'mousedown', 'mousemove', 'touchstart', 'scroll'].forEach(type => {
+            document.addEventListener(type, onActivity, { passive: true });
+        });
+        document.addEventListener('visibilitychange', onActivity);
+    }
+
+    // Pick our status (online, away or dnd); the server keeps it across connections
+    async setPresenceStatus(status) {
+        try {
+            const response = await fetch('/api/users/me/status', {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                credentials: 'same-origin',
+                body: JSON.stringify({ status })
+            });
+            if (!response.ok) {
+                console.error('[ws.js:setPresenceStatus] Failed to set status:', response.status);
+                this.showErrorMessage('Could not change your status.');
+            }
+        } catch (error) {
+            console.error('[ws.js:setPresenceStatus] Error setting status:', error);
+        }
+    }
+
     // Send join message
     sendJoinMessage() {
         this.send('join', { username: this.currentUser.nickname });
@@ -154,18 +204,39 @@ class ChatWebSocket {
         this.send('leave');
     }
 
+    // Follow the live comment stream of a post (only one post at a time)
+    subscribePost(postId) {
+        if (this.subscribedPostId && this.subscribedPostId !== postId) {
+            this.unsubscribePost();
+        }
+        this.subscribedPostId = postId;
+        this.send('subscribe_post', { post_id: postId });
+    }
+
+    // Stop following the comment stream of the open post
+    unsubscribePost() {
+        if (!this.subscribedPostId) return;
+        this.send('unsubscribe_post', { post_id: this.subscribedPostId });
+        this.subscribedPostId = null;
+    }
+
+    // Forward a live feed event to the feed and post views as a DOM event
+    handleFeedEvent(data) {
+        window.dispatchEvent(new CustomEvent(`forum:${data.type}`, { detail: data }));
+    }
+
 
     // Handle incoming messages
     handleMessage(data) {
         console.log('[ws.js:handleMessage] [DEBUG] Handling message:', data);
         switch (data.type) {
-            case 'user_online':
-                console.log('[ws.js:handleMessage] [DEBUG] Message type: user_online');
-                this.handleUserOnline(data);
+            case 'presence':
+                console.log('[ws.js:handleMessage] [DEBUG] Message type: presence');
+                this.handlePresence(data.payload || {});
                 break;
-            case 'user_offline':
-                console.log('[ws.js:handleMessage] [DEBUG] Message type: user_offline');
-                this.handleUserOffline(data.nickname);
+            case 'presence_list':
+                console.log('[ws.js:handleMessage] [DEBUG] Message type: presence_list');
+                this.handlePresenceList(data.payload || []);
                 break;
             case 'private_message':
                 console.log('[ws.js:handleMessage] [DEBUG] Message type: private_message');
@@ -173,20 +244,52 @@ class ChatWebSocket {
                 break;
             case 'message_delivered':
                 console.log('[ws.js:handleMessage] [DEBUG] Message type: message_delivered');
-                this.handleMessageDelivered(data.message_id);
+                this.handleMessageDelivered(data);
+                break;
+            case 'message_queued':
+                console.log('[ws.js:handleMessage] [DEBUG] Message type: message_queued');
+                this.handleMessageDelivered(data);
                 break;
             case 'message_failed':
                 console.log('[ws.js:handleMessage] [DEBUG] Message type: message_failed');
-                this.handleMessageFailed(data.to_user_id);
-                break;
-            case 'online_users':
-                console.log('[ws.js:handleMessage] [DEBUG] Message type: online_users');
-                this.handleOnlineUsers(data);
+                this.handleMessageFailed(data);
                 break;
             case 'message_from_me':
                 console.log('[ws.js:handleMessage] [DEBUG] Message type: message_from_me');
                 this.handleMessageFromMe(data);
                 break;
+            case 'typing_start':
+            case 'typing_stop':
+                console.log('[ws.js:handleMessage] [DEBUG] Message type:', data.type);
+                this.handleTyping(data);
+                break;
+            case 'messages_read':
+                console.log('[ws.js:handleMessage] [DEBUG] Message type: messages_read');
+                this.handleMessagesRead(data);
+                break;
+            case 'post_created':
+            case 'post_updated':
+            case 'comment_created':
+            case 'comment_updated':
+                console.log('[ws.js:handleMessage] [DEBUG] Message type:', data.type);
+                this.handleFeedEvent(data);
+                break;
+            case 'rate_limited':
+                console.log('[ws.js:handleMessage] [DEBUG] Message type: rate_limited');
+                this.showErrorMessage(`You are going too fast, try again in ${data.retry_after || 1}s.`);
+                break;
+            case 'conversation_updated':
+                console.log('[ws.js:handleMessage] [DEBUG] Message type: conversation_updated');
+                this.loadSidebar();
+                break;
+            case 'sidebar_update':
+                console.log('[ws.js:handleMessage] [DEBUG] Message type: sidebar_update');
+                this.handleSidebarUpdate(data.payload || {});
+                break;
+            case 'moderation_notice':
+                console.log('[ws.js:handleMessage] [DEBUG] Message type: moderation_notice');
+                this.handleModerationNotice(data.payload || {});
+                break;
 
             default:
                 console.log('[ws.js:handleMessage] [DEBUG] Unknown message type:', data.type);
@@ -195,51 +298,80 @@ class ChatWebSocket {
 
     
 
-    // Handle user online
-    handleUserOnline(data) {
-        console.log('[ws.js:handleUserOnline] [DEBUG] handleUserOnline called with nickname:', data.nickname);
-        if (data.nickname && !this.onlineUsers.includes(data.nickname)) {
-            console.log('[ws.js:handleUserOnline] [DEBUG] Adding user to online list:', data.nickname);
-            this.onlineUsers.push(data.nickname);
-            this.updateUsersList(); // Update the users list to reflect online status
-            // Only show notification if chat is not open and it's not the current user
-            // This prevents spam notifications on page refresh
-            if (data.nickname !== this.currentUser?.nickname && !this.isChatOpen) {
-                this.showOnlineNotification(data.nickname);
-            }
-            // If this user is in our active conversation, update chat mode to show input
-            if (this.activeConversation && data.nickname === this.activeConversation.nickname) {
-                this.updateChatMode('private');
-            }
+    // Handle a presence change of a user, ourselves included
+    handlePresence(presence) {
+        console.log('[ws.js:handlePresence] [DEBUG] handlePresence called with:', presence);
+        if (!presence.user_id) return;
+
+        const wasOnline = this.onlineUsers.includes(presence.nickname);
+        if (presence.status === 'offline') {
+            delete this.presence[presence.user_id];
         } else {
-            console.log('[ws.js:handleUserOnline] [DEBUG] User already online or invalid data.nickname:', data.nickname);
+            this.presence[presence.user_id] = presence;
         }
-    }
+        this.updateOnlineUsers();
 
-    // Handle user offline
-    handleUserOffline(nickname) {
-        console.log('[ws.js:handleUserOffline] [DEBUG] handleUserOffline called with nickname:', nickname);
-        if (nickname) {
-            console.log('[ws.js:handleUserOffline] [DEBUG] Removing user from online list:', nickname);
-            this.onlineUsers = this.onlineUsers.filter(user => user !== nickname);
-            this.updateUsersList(); // Update the users list to reflect offline status
-            // If this user is in our active conversation, update chat mode to hide input
-            if (this.activeConversation && nickname === this.activeConversation.nickname) {
-                this.updateChatMode('private');
-            }
-        } else {
-            console.log('[ws.js:handleUserOffline] [DEBUG] Invalid nickname for offline:', nickname);
+        const entry = this.sidebar.find(e => e.kind === 'direct' && e.user_id === presence.user_id);
+        if (entry) {
+            entry.status = presence.status;
+            entry.is_online = presence.status !== 'offline';
+        }
+        this.updateUsersList();
+        this.updatePresenceSelect();
+
+        // Only show notification if chat is not open and it's not the current user
+        // This prevents spam notifications on page refresh
+        if (!wasOnline && presence.status !== 'offline' && presence.nickname !== this.currentUser?.nickname && !this.isChatOpen) {
+            this.showOnlineNotification(presence.nickname);
+        }
+        // If this user is in our active conversation, show or hide the input
+        if (this.activeConversation && presence.nickname === this.activeConversation.nickname) {
+            this.updateChatMode('private');
         }
     }
 
+    // Handle the presence of every connected user, sent when we connect
+    handlePresenceList(list) {
+        console.log('[ws.js:handlePresenceList] [DEBUG] handlePresenceList called with:', list);
+        this.presence = {};
+        list.forEach(presence => {
+            this.presence[presence.user_id] = presence;
+        });
+        this.updateOnlineUsers();
+        this.updateUsersList();
+        this.updatePresenceSelect();
+    }
+
+    // Nicknames of the users with any status but offline
+    updateOnlineUsers() {
+        this.onlineUsers = Object.values(this.presence).map(presence => presence.nickname);
+    }
+
+    // Show the status we picked in the chat header: away and dnd stick, anything else is online
+    updatePresenceSelect() {
+        const select = document.getElementById('chat-presence-select');
+        const own = this.presence[this.currentUser?.id];
+        if (!select || !own) return;
+        select.value = own.status === 'away' || own.status === 'dnd' ? own.status : 'online';
+    }
+
     // Handle incoming private message
     handlePrivateMessage(data) {
         console.log('[ws.js:handlePrivateMessage] [DEBUG] handlePrivateMessage called with data:', data);
+        if (!data.to_user_id && data.conversation_id) {
+            this.handleGroupMessage(data);
+            return;
+        }
         const fromUserId = data.from_user_id;
         console.log('[ws.js:handlePrivateMessage] [DEBUG] From user ID:', fromUserId);
 
         // Check if this message is already in our local state to prevent duplicates
+        // (queued messages flushed on reconnect may already be in the loaded history)
         if (this.privateMessages[fromUserId]) {
+            if (data.message_id && this.privateMessages[fromUserId].some(msg => msg.id === data.message_id)) {
+                console.log('[ws.js:handlePrivateMessage] [DEBUG] Message already loaded, ignoring');
+                return;
+            }
             const existingMessage = this.privateMessages[fromUserId].find(msg =>
                 msg.content === data.content &&
                 msg.sender_id === fromUserId &&
@@ -257,7 +389,7 @@ class ChatWebSocket {
             content: data.content,
             created_at: data.timestamp || new Date().toISOString(),
             is_read: false,
-            id: data.id // Include database ID if available
+            id: data.message_id // Database ID assigned by the hub
         };
 
         // Store the message
@@ -267,54 +399,148 @@ class ChatWebSocket {
         this.privateMessages[fromUserId].push(message);
         console.log('[ws.js:handlePrivateMessage] [DEBUG] Stored private message');
 
-        // Move user to top and update unread count
-        this.moveUserToTop(fromUserId);
-
-        // Update users list to reflect changes
-        this.updateUsersList();
+        // The server moves the conversation up in the sidebar with a sidebar_update
 
         // If this conversation is active, display it immediately
         if (this.activeConversation && this.activeConversation.userId === fromUserId) {
             console.log('[ws.js:handlePrivateMessage] [DEBUG] Active conversation matches, displaying messages');
+            this.showTypingIndicator(false);
             this.displayPrivateMessages(fromUserId);
+            // The message is on screen, so the sender gets a read receipt right away
+            if (this.isChatOpen) {
+                this.send('mark_read', { to_user_id: fromUserId });
+            }
 
         }
+    }
+
+    // Handle an incoming message of a group conversation
+    handleGroupMessage(data) {
+        const key = this.groupKey(data.conversation_id);
+        if (!this.privateMessages[key]) {
+            this.privateMessages[key] = [];
+        }
+        if (data.message_id && this.privateMessages[key].some(msg => msg.id === data.message_id)) {
+            return; // Already loaded with the history
+        }
+        this.privateMessages[key].push({
+            sender_id: data.from_user_id,
+            sender_nickname: data.nickname,
+            conversation_id: data.conversation_id,
+            content: data.content,
+            created_at: data.timestamp || new Date().toISOString(),
+            id: data.message_id
+        });
 
+        if (this.activeConversation && this.activeConversation.conversationId === data.conversation_id) {
+            this.displayPrivateMessages(key);
+            if (this.isChatOpen) {
+                this.markConversationAsRead(data.conversation_id);
+            }
+        }
+    }
 
+    // Key of a group conversation in privateMessages (direct conversations use the partner's user ID)
+    groupKey(conversationId) {
+        return `g${conversationId}`;
+    }
 
-        // Update conversations list to show new message
-        console.log('[ws.js:handlePrivateMessage] [DEBUG] Loading conversations after private message');
-        this.loadConversations();
+    // Key of the open conversation in privateMessages
+    activeKey() {
+        if (!this.activeConversation) return null;
+        return this.activeConversation.conversationId
+            ? this.groupKey(this.activeConversation.conversationId)
+            : this.activeConversation.userId;
     }
 
     // Handle message delivered confirmation
-    handleMessageDelivered(messageId) {
-        console.log('[ws.js:handleMessageDelivered] Message delivered:', messageId);
-        // Could add visual confirmation here if needed
+    handleMessageDelivered(data) {
+        console.log('[ws.js:handleMessageDelivered] Message stored:', data.message_id, data.type);
+        // Swap the temporary ID for the database ID
+        const key = data.to_user_id || this.groupKey(data.conversation_id);
+        const messages = this.privateMessages[key] || [];
+        const pending = messages.find(msg => data.temp_id && msg.temp_id === data.temp_id);
+        if (pending) {
+            pending.id = data.message_id;
+            pending.created_at = data.timestamp || pending.created_at;
+        }
     }
 
-    // Handle message delivery failure
-    handleMessageFailed(receiverId) {
-        console.error('[ws.js:handleMessageFailed] Message failed to deliver to user:', receiverId);
-        // Could show error notification to user
+    // Handle typing_start / typing_stop from a conversation partner
+    handleTyping(data) {
+        if (!this.activeConversation || this.activeConversation.userId !== data.from_user_id) return;
+        this.showTypingIndicator(data.type === 'typing_start', data.nickname);
     }
 
-    // Handle online users list
-    handleOnlineUsers(data) {
-        console.log('[ws.js:handleOnlineUsers] [DEBUG] handleOnlineUsers called with data:', data);
-        try {
-            // Parse the content as JSON array
-            const onlineUsersList = JSON.parse(data.content || '[]');
-            console.log('[ws.js:handleOnlineUsers] [DEBUG] Parsed online users:', onlineUsersList);
+    // Show or hide "<nickname> is typing..." above the chat input
+    showTypingIndicator(visible, nickname = '') {
+        clearTimeout(this.typingIndicatorTimer);
+        let indicator = document.getElementById('chat-typing-indicator');
+        if (!indicator) {
+            const chatForm = document.getElementById('chat-form');
+            if (!chatForm) return;
+            indicator = document.createElement('div');
+            indicator.id = 'chat-typing-indicator';
+            indicator.className = 'chat-typing-indicator';
+            indicator.style.fontSize = '0.8em';
+            indicator.style.color = '#888';
+            indicator.style.padding = '0 10px';
+            chatForm.parentNode.insertBefore(indicator, chatForm);
+        }
 
-            // Update the online users array
-            this.onlineUsers = onlineUsersList;
-            console.log('[ws.js:handleOnlineUsers] [DEBUG] Updated onlineUsers to:', this.onlineUsers);
+        indicator.textContent = visible ? `${nickname} is typing...` : '';
+        if (visible) {
+            // The server expires indicators too; this only covers a lost typing_stop
+            this.typingIndicatorTimer = setTimeout(() => this.showTypingIndicator(false), 8000);
+        }
+    }
 
-            // Update the UI to reflect the new online users
-            this.updateUsersList();
-        } catch (error) {
-            console.error('[ws.js:handleOnlineUsers] [DEBUG] Error parsing online users data:', error);
+    // Called on every keystroke in the chat input; tells the partner we are typing
+    notifyTyping() {
+        if (!this.activeConversation || !this.activeConversation.userId) return; // Direct conversations only
+        const toUserId = this.activeConversation.userId;
+
+        // The server throttles too, but there is no point sending a frame per keystroke
+        if (Date.now() - this.typingSentAt > 2000) {
+            this.send('typing_start', { to_user_id: toUserId });
+            this.typingSentAt = Date.now();
+        }
+
+        clearTimeout(this.typingStopTimer);
+        this.typingStopTimer = setTimeout(() => this.stopTyping(toUserId), 3000);
+    }
+
+    // Tell the partner we stopped typing
+    stopTyping(toUserId) {
+        clearTimeout(this.typingStopTimer);
+        if (!this.typingSentAt) return;
+        this.typingSentAt = 0;
+        this.send('typing_stop', { to_user_id: toUserId });
+    }
+
+    // Handle messages_read: the partner read our messages up to message_id
+    handleMessagesRead(data) {
+        const messages = this.privateMessages[data.from_user_id] || [];
+        messages.forEach(msg => {
+            const senderId = msg.senderId || msg.sender_id;
+            if (parseInt(senderId) === parseInt(this.currentUser.id) && msg.id && msg.id <= data.message_id) {
+                msg.is_read = true;
+                msg.isRead = true;
+            }
+        });
+
+        if (this.activeConversation && this.activeConversation.userId === data.from_user_id) {
+            const container = document.getElementById('chat-messages');
+            const atBottom = !container || container.scrollHeight - container.scrollTop - container.clientHeight < 20;
+            this.displayPrivateMessages(data.from_user_id, atBottom);
+        }
+    }
+
+    // Handle message delivery failure
+    handleMessageFailed(data) {
+        console.error('[ws.js:handleMessageFailed] Message failed to deliver to user:', data.to_user_id);
+        if (data.content) {
+            this.showErrorMessage(data.content);
         }
     }
 
@@ -322,13 +548,14 @@ class ChatWebSocket {
     handleMessageFromMe(data) {
         console.log('[ws.js:handleMessageFromMe] [DEBUG] ===== MESSAGE_FROM_ME RECEIVED =====');
         console.log('[ws.js:handleMessageFromMe] [DEBUG] Raw data:', JSON.stringify(data, null, 2));
-        const toUserId = data.to_user_id;
+        // Group messages have no recipient user and are keyed by conversation
+        const toUserId = data.to_user_id || this.groupKey(data.conversation_id);
         console.log('[ws.js:handleMessageFromMe] [DEBUG] To user ID:', toUserId);
         console.log('[ws.js:handleMessageFromMe] [DEBUG] Current user ID:', this.currentUser?.id);
         console.log('[ws.js:handleMessageFromMe] [DEBUG] Active conversation:', this.activeConversation);
 
         // Check if we are in conversation with the recipient
-        if (this.activeConversation && this.activeConversation.userId === toUserId) {
+        if (this.activeConversation && this.activeKey() === toUserId) {
             console.log('[ws.js:handleMessageFromMe] [DEBUG] ✓ Active conversation matches, processing message');
 
             const message = {
@@ -337,7 +564,7 @@ class ChatWebSocket {
                 content: data.content,
                 created_at: data.timestamp || new Date().toISOString(),
                 is_read: false,
-                id: data.id, // Include database ID if available
+                id: data.message_id, // Database ID assigned by the hub
                 source: 'message_from_me' // Mark the source for debugging
             };
 
@@ -396,11 +623,12 @@ class ChatWebSocket {
     }
 
   
-    // Load all users from API
-    async loadAllUsers() {
+    // Load the chat sidebar: conversations with their last message and unread
+    // count, most recent first, then every other user alphabetically
+    async loadSidebar() {
         try {
-            console.log('[ws.js:loadAllUsers] [DEBUG] Loading all users from API...');
-            const response = await fetch('/api/users', {
+            console.log('[ws.js:loadSidebar] [DEBUG] Loading sidebar...');
+            const response = await fetch('/api/sidebar', {
                 method: 'GET',
                 headers: {
                     'Content-Type': 'application/json',
@@ -408,35 +636,67 @@ class ChatWebSocket {
                 credentials: 'same-origin' // Include session cookies
             });
 
-            console.log('[ws.js:loadAllUsers] [DEBUG] Users API response status:', response.status);
-            if (response.ok) {
-                const users = await response.json();
-                console.log('[ws.js:loadAllUsers] [DEBUG] Loaded users:', users);
-                this.allUsers = users.filter(user => user && typeof user.id === 'number' && typeof user.nickname === 'string');
-                console.log('[ws.js:loadAllUsers] [DEBUG] allUsers now contains', this.allUsers.length, 'users');
-                console.log('[ws.js:loadAllUsers] [DEBUG] Calling updateUsersList to render users');
-              //  this.updateUsersList(); // Update the UI with online/offline status
-                console.log('[ws.js:loadAllUsers] [DEBUG] updateUsersList completed, users should be clickable now');
-            } else {
-                const errorText = await response.text();
-                console.error('[ws.js:loadAllUsers] [DEBUG] Failed to load users:', response.status, errorText);
+            if (!response.ok) {
+                console.error('[ws.js:loadSidebar] [DEBUG] Failed to load sidebar:', response.status);
+                return;
             }
+            const data = await response.json();
+            this.sidebar = data.entries || [];
+            console.log('[ws.js:loadSidebar] [DEBUG] Sidebar now contains', this.sidebar.length, 'entries');
+            this.updateUsersList();
+            this.updateChatUnreadUI();
         } catch (error) {
-            console.error('[ws.js:loadAllUsers] [DEBUG] Error loading users:', error);
+            console.error('[ws.js:loadSidebar] [DEBUG] Error loading sidebar:', error);
         }
     }
 
-    // Build a map of unread counts per user from conversations
-    getUnreadMap() {
-        const map = {};
-        if (Array.isArray(this.conversations)) {
-            this.conversations.forEach(c => {
-                if (c && typeof c.user_id !== 'undefined') {
-                    map[parseInt(c.user_id)] = c.unread_count || 0;
-                }
-            });
+    // Apply a sidebar_update: changed entries replace ours and move to their
+    // place in the order, presence changes only update status and is_online
+    handleSidebarUpdate(update) {
+        (update.entries || []).forEach(entry => {
+            // A user we talk to for the first time replaces their plain user entry
+            this.sidebar = this.sidebar.filter(e => this.sidebarKey(e) !== this.sidebarKey(entry));
+            this.sidebar.push(entry);
+        });
+        this.sidebar.sort((a, b) => this.compareSidebarEntries(a, b));
+
+        let unknownUser = false;
+        (update.presence || []).forEach(change => {
+            const entry = this.sidebar.find(e => e.kind === 'direct' && e.user_id === change.user_id);
+            if (entry) {
+                entry.status = change.status;
+                entry.is_online = change.is_online;
+            } else if (change.user_id !== this.currentUser?.id) {
+                unknownUser = true;
+            }
+        });
+        if (unknownUser) {
+            this.loadSidebar(); // Someone who signed up after we loaded the sidebar
+            return;
         }
-        return map;
+
+        this.updateUsersList();
+        this.updateChatUnreadUI();
+    }
+
+    // Key identifying a sidebar entry: the other user for direct entries, the conversation for groups
+    sidebarKey(entry) {
+        return entry.kind === 'group' ? this.groupKey(entry.conversation_id) : entry.user_id;
+    }
+
+    // The server's sidebar order: active conversations first, most recent first,
+    // then everything else by name
+    compareSidebarEntries(a, b) {
+        const aTime = a.active_at ? new Date(a.active_at).getTime() : null;
+        const bTime = b.active_at ? new Date(b.active_at).getTime() : null;
+        if (aTime !== bTime) {
+            if (aTime === null) return 1;
+            if (bTime === null) return -1;
+            return bTime - aTime;
+        }
+        const byName = a.name.toLowerCase().localeCompare(b.name.toLowerCase());
+        if (byName !== 0) return byName;
+        return (a.user_id || 0) - (b.user_id || 0) || (a.conversation_id || 0) - (b.conversation_id || 0);
     }
 
     // Update users list in UI
@@ -447,7 +707,7 @@ class ChatWebSocket {
         // Clear existing list
         usersListElement.innerHTML = '';
 
-        if (!this.SortedUserslist || this.SortedUserslist.length === 0) {
+        if (this.sidebar.length === 0) {
             const noUsersElement = document.createElement('div');
             noUsersElement.className = 'no-users';
             noUsersElement.textContent = 'No users found';
@@ -455,51 +715,131 @@ class ChatWebSocket {
             return;
         }
 
-        this.SortedUserslist.forEach(user => {
-            // Skip current user
-            if (user.id === this.currentUser.id) return;
+        this.sidebar.forEach(entry => {
+            if (entry.kind === 'group') {
+                usersListElement.appendChild(this.createGroupElement(entry));
+                return;
+            }
 
         const userElement = document.createElement('div');
-        userElement.className = 'chat-user' + (this.onlineUsers.includes(user.nickname) ? ' online' : '');
-        userElement.setAttribute('data-user-id', user.id);
+        userElement.className = 'chat-user' + (entry.is_online ? ' online' : '');
+        userElement.setAttribute('data-user-id', entry.user_id);
 
         const nicknameSpan = document.createElement('span');
         nicknameSpan.className = 'user-nickname';
-        nicknameSpan.textContent = '👤 '+user.nickname;
+        nicknameSpan.textContent = '👤 '+entry.name;
 
-        if (user.unread_count > 0) {
+        if (entry.unread_count > 0) {
             const userUnread = document.createElement('span');
             userUnread.className = 'user-unread-badge';
-            userUnread.textContent = user.unread_count;
+            userUnread.textContent = entry.unread_count;
             nicknameSpan.appendChild(userUnread);
         }
 
         userElement.appendChild(nicknameSpan);
 
+        if (entry.last_message) {
+            const previewSpan = document.createElement('span');
+            previewSpan.className = 'user-last-message';
+            previewSpan.textContent = entry.last_message;
+            userElement.appendChild(previewSpan);
+        }
+
+        const status = entry.status || (entry.is_online ? 'online' : 'offline');
         const statusSpan = document.createElement('span');
-        statusSpan.className = 'user-status ' + (this.onlineUsers.includes(user.nickname) ? 'online' : 'offline');
-        statusSpan.textContent = this.onlineUsers.includes(user.nickname) ? 'online' : 'offline';
+        statusSpan.className = 'user-status ' + status;
+        statusSpan.textContent = status === 'dnd' ? 'do not disturb' : status;
         userElement.appendChild(statusSpan);
 
             userElement.addEventListener('click', () => {
-                this.startConversation(user.id, user.nickname);
+                this.startConversation(entry.user_id, entry.name);
             });
 
             usersListElement.appendChild(userElement);
         });
     }
 
+    // Build the chat list entry of a group conversation
+    createGroupElement(group) {
+        const groupElement = document.createElement('div');
+        groupElement.className = 'chat-user chat-group';
+        groupElement.setAttribute('data-conversation-id', group.conversation_id);
+
+        const nameSpan = document.createElement('span');
+        nameSpan.className = 'user-nickname';
+        nameSpan.textContent = '👥 ' + group.name;
+
+        if (group.unread_count > 0) {
+            const groupUnread = document.createElement('span');
+            groupUnread.className = 'user-unread-badge';
+            groupUnread.textContent = group.unread_count;
+            nameSpan.appendChild(groupUnread);
+        }
+        groupElement.appendChild(nameSpan);
+
+        if (group.last_message) {
+            const previewSpan = document.createElement('span');
+            previewSpan.className = 'user-last-message';
+            previewSpan.textContent = group.last_message;
+            groupElement.appendChild(previewSpan);
+        }
+
+        groupElement.addEventListener('click', () => {
+            this.startGroupConversation(group.conversation_id, group.name);
+        });
+        return groupElement;
+    }
+
+    // Open a group conversation
+    startGroupConversation(conversationId, name) {
+        console.log(`[ws.js:startGroupConversation] Opening group ${name} (ID: ${conversationId})`);
+
+        if (this.activeConversation && this.activeConversation.userId) {
+            this.stopTyping(this.activeConversation.userId);
+        }
+        this.showTypingIndicator(false);
+
+        this.activeConversation = {
+            userId: null,
+            conversationId: parseInt(conversationId),
+            nickname: name,
+            cursor: '',
+            hasMore: true,
+            isLoading: false
+        };
+
+        const messagesContainer = document.getElementById('chat-messages');
+        if (messagesContainer) {
+            messagesContainer.innerHTML = '';
+        }
+
+        const key = this.activeKey();
+        this.privateMessages[key] = [];
+        this.loadConversationHistory(key);
+        this.updateChatMode('private');
+
+        if (!this.isChatOpen) {
+            this.showChat();
+        }
+    }
+
     // Start conversation with a user
     startConversation(userId, nickname) {
         console.log(`[ws.js:startConversation] Starting conversation with ${nickname} (ID: ${userId})`);
 
         // Allow starting conversations with any user (online or offline) for history viewing
 
+        // Typing state belongs to the previous conversation
+        if (this.activeConversation && this.activeConversation.userId) {
+            this.stopTyping(this.activeConversation.userId);
+        }
+        this.showTypingIndicator(false);
+
         // Set active conversation
         this.activeConversation = {
             userId: parseInt(userId),
             nickname,
-            offset: 0,
+            cursor: '',
             hasMore: true,
             isLoading: false
         };
@@ -534,19 +874,24 @@ class ChatWebSocket {
     }
 
     // Load conversation history from API
-    async loadConversationHistory(userId, offset = 0) {
+    async loadConversationHistory(userId, cursor = '') {
         if (this.activeConversation && this.activeConversation.isLoading) return;
 
-        // If loading more (offset > 0) and we know there's no more, stop.
-        if (offset > 0 && this.activeConversation && !this.activeConversation.hasMore) return;
+        // If loading more (cursor set) and we know there's no more, stop.
+        if (cursor && this.activeConversation && !this.activeConversation.hasMore) return;
 
         try {
             if (this.activeConversation) this.activeConversation.isLoading = true;
 
-            console.log(`[ws.js:loadConversationHistory] [DEBUG] Loading conversation history with user ${userId}, offset: ${offset}`);
+            console.log(`[ws.js:loadConversationHistory] [DEBUG] Loading conversation history with user ${userId}, cursor: ${cursor}`);
             const limit = 10; // Load 10 at a time as requested
             // Add timestamp to prevent caching
-            const response = await fetch(`/api/messages?user_id=${userId}&limit=${limit}&offset=${offset}&_t=${Date.now()}`, {
+            const cursorParam = cursor ? `&cursor=${encodeURIComponent(cursor)}` : '';
+            const conversationId = this.activeConversation && this.activeConversation.conversationId;
+            const url = conversationId
+                ? `/api/conversations/${conversationId}/messages?limit=${limit}${cursorParam}&_t=${Date.now()}`
+                : `/api/messages?user_id=${userId}&limit=${limit}${cursorParam}&_t=${Date.now()}`;
+            const response = await fetch(url, {
                 method: 'GET',
                 headers: {
                     'Content-Type': 'application/json',
@@ -561,8 +906,9 @@ class ChatWebSocket {
                 console.log('[ws.js:loadConversationHistory] [DEBUG] Conversation history data:', data);
                 const loadedMessages = data.messages || [];
 
-                if (loadedMessages.length < limit) {
-                    if (this.activeConversation) this.activeConversation.hasMore = false;
+                if (this.activeConversation) {
+                    this.activeConversation.hasMore = !!data.has_more;
+                    this.activeConversation.cursor = data.next_cursor || '';
                 }
 
                 // Sort messages chronologically (oldest first)
@@ -572,7 +918,7 @@ class ChatWebSocket {
                     return new Date(aTime) - new Date(bTime);
                 });
 
-                if (offset === 0) {
+                if (!cursor) {
                     // Initial load
                     this.privateMessages[userId] = loadedMessages;
                     this.displayPrivateMessages(userId, true); // true = scroll to bottom
@@ -582,8 +928,7 @@ class ChatWebSocket {
                     this.displayPrivateMessages(userId, false, loadedMessages.length); // false = maintain position
                 }
 
-                // Refresh conversations to update unread counts (since server marked messages as read)
-                if (offset === 0) this.loadConversations();
+                // The server marked the messages as read and pushes the new unread count
             } else {
                 const errorText = await response.text();
                 console.error('[ws.js:loadConversationHistory] [DEBUG] Failed to load conversation history:', response.status, errorText);
@@ -643,9 +988,22 @@ class ChatWebSocket {
             const isOwnMessage = this.currentUser && (parseInt(senderId) === parseInt(this.currentUser.id));
             messageElement.className = `chat-message private-message ${isOwnMessage ? 'own-message' : 'other-message'}`;
 
+            // Group messages from others show who wrote them
+            if (!isOwnMessage && conversation && conversation.conversationId) {
+                const senderSpan = document.createElement('span');
+                senderSpan.className = 'message-sender';
+                senderSpan.textContent = (msg.sender_nickname || this.nicknameOf(senderId)) + ': ';
+                messageElement.appendChild(senderSpan);
+            }
+
             const messageSpan = document.createElement('span');
             messageSpan.className = 'message-text';
-            messageSpan.textContent = msg.content;
+            if (msg.hidden) {
+                messageSpan.classList.add('message-hidden');
+                messageSpan.textContent = '[hidden by a moderator]';
+            } else {
+                messageSpan.textContent = msg.content;
+            }
             messageElement.appendChild(messageSpan);
 
             const timeSpan = document.createElement('span');
@@ -655,6 +1013,14 @@ class ChatWebSocket {
             timeSpan.textContent = new Date(timestamp).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
             messageElement.appendChild(timeSpan);
 
+            // Read receipt on our own messages: ✓ sent, ✓✓ read
+            if (isOwnMessage) {
+                const statusSpan = document.createElement('span');
+                statusSpan.className = 'message-status';
+                statusSpan.textContent = (msg.isRead || msg.is_read) ? ' ✓✓' : ' ✓';
+                messageElement.appendChild(statusSpan);
+            }
+
             messagesContainer.appendChild(messageElement);
         });
 
@@ -662,8 +1028,7 @@ class ChatWebSocket {
         if (!messagesContainer.hasAttribute('data-scroll-listener')) {
             messagesContainer.addEventListener('scroll', () => {
                 if (messagesContainer.scrollTop === 0 && this.activeConversation && this.activeConversation.hasMore && !this.activeConversation.isLoading) {
-                    this.activeConversation.offset += 10;
-                    this.loadConversationHistory(this.activeConversation.userId, this.activeConversation.offset);
+                    this.loadConversationHistory(this.activeKey(), this.activeConversation.cursor);
                 }
             });
             messagesContainer.setAttribute('data-scroll-listener', 'true');
@@ -682,55 +1047,43 @@ class ChatWebSocket {
     async sendPrivateMessage(message) {
         if (!this.activeConversation || !message.trim()) return;
 
-        const { userId } = this.activeConversation;
+        const { userId, conversationId } = this.activeConversation;
+        const key = this.activeKey();
 
-        // Allow sending messages - the backend will handle delivery when user comes online
+        // The hub persists the message and confirms it with message_delivered or message_queued
+        if (!this.ws || this.ws.readyState !== WebSocket.OPEN) {
+            this.showErrorMessage('Network error. Please check your connection.');
+            return;
+        }
 
-        try {
-            const response = await fetch('/api/messages/send', {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json',
-                },
-                credentials: 'same-origin',
-                body: JSON.stringify({
-                    receiver_id: userId,
-                    content: message.trim()
-                })
-            });
+        // Add message to local state immediately for better UX
+        const newMessage = {
+            sender_id: this.currentUser.id,
+            receiver_id: userId,
+            content: message.trim(),
+            created_at: new Date().toISOString(),
+            is_read: false,
+            // Generate a temporary ID for local tracking until the server confirms it
+            temp_id: `temp_${Date.now()}_${Math.random()}`
+        };
 
-            if (response.ok) {
-                // Add message to local state immediately for better UX
-                const newMessage = {
-                    sender_id: this.currentUser.id,
-                    receiver_id: userId,
-                    content: message.trim(),
-                    created_at: new Date().toISOString(),
-                    is_read: false,
-                    // Generate a temporary ID for local tracking
-                    temp_id: `temp_${Date.now()}_${Math.random()}`
-                };
-
-                if (!this.privateMessages[userId]) {
-                    this.privateMessages[userId] = [];
-                }
-                this.privateMessages[userId].push(newMessage);
-                this.displayPrivateMessages(userId);
-
-                // Also send via WebSocket for real-time delivery
-                this.send('private_message', {
-                    to_user_id: userId,
-                    content: message.trim(),
-                    temp_id: newMessage.temp_id
-                });
-            } else {
-                console.error('Failed to send private message:', response.status);
-                this.showErrorMessage('Failed to send message. Please try again.');
-            }
-        } catch (error) {
-            console.error('Error sending private message:', error);
-            this.showErrorMessage('Network error. Please check your connection.');
+        if (!this.privateMessages[key]) {
+            this.privateMessages[key] = [];
         }
+        this.privateMessages[key].push(newMessage);
+        this.displayPrivateMessages(key);
+
+        // The message ends the typing indicator on the other side
+        clearTimeout(this.typingStopTimer);
+        this.typingSentAt = 0;
+
+        // Group messages are addressed to the conversation, direct ones to the partner
+        const target = conversationId ? { conversation_id: conversationId } : { to_user_id: userId };
+        this.send('private_message', {
+            ...target,
+            content: message.trim(),
+            temp_id: newMessage.temp_id
+        });
     }
 
     // Update chat mode (public/private)
@@ -753,7 +1106,8 @@ class ChatWebSocket {
         if (chatForm) {
             if (mode === 'private' && this.activeConversation) {
                 // In private mode, show form only if the other user is online
-                const isOtherUserOnline = this.onlineUsers.includes(this.activeConversation.nickname);
+                // Groups can always be written to; members who are offline get the message later
+                const isOtherUserOnline = !!this.activeConversation.conversationId || this.onlineUsers.includes(this.activeConversation.nickname);
                 chatForm.style.display = isOtherUserOnline ? 'flex' : 'none';
                 console.log('[ws.js:updateChatMode] [DEBUG] Set chat form display to:', isOtherUserOnline ? 'flex' : 'none', '(user online:', isOtherUserOnline, ')');
             } else {
@@ -782,103 +1136,6 @@ class ChatWebSocket {
         }
     }
 
-    // Load conversations from API
-    async loadConversations() {
-        try {
-            console.log('[ws.js:loadConversations] Loading conversations...');
-            const response = await fetch('/api/conversations', {
-                method: 'GET',
-                headers: {
-                    'Content-Type': 'application/json',
-                },
-                credentials: 'same-origin'
-            });
-
-            if (response.ok) {
-                const data = await response.json();
-                const list = data.conversations || [];
-                // Deduplicate by user_id (backend may return multiple rows per partner)
-                const byUser = {};
-                for (const conv of list) {
-                    const uid = parseInt(conv.user_id);
-                    if (!byUser[uid]) {
-                        byUser[uid] = conv;
-                    } else {
-                        // Keep the one with latest last_message_time if available
-                        const a = new Date(byUser[uid].last_message_time || 0).getTime();
-                        const b = new Date(conv.last_message_time || 0).getTime();
-                        if (b > a) byUser[uid] = conv;
-                    }
-                }
-                 this.conversations = Object.values(byUser);
-                 console.log(this.conversations.length, this.conversations)
-             //    this.renderConversations();
-                // Update unread badges in users list and chat button
-               // this.updateUsersList();
-               this.getSortedUserList();
-               this.updateUsersList();
-                this.updateChatUnreadUI();
-            } else {
-                console.error('[ws.js:loadConversations] Failed to load conversations:', response.status);
-            }
-        } catch (error) {
-            console.error('[ws.js:loadConversations] Error loading conversations:', error);
-        }
-    }
-
-    // Sort conversations by last message time (most recent first)
-    sortConversationsByLastMessageTime() {
-        this.conversations.sort((a, b) => {
-            const aTime = new Date(a.last_message_time || 0).getTime();
-            const bTime = new Date(b.last_message_time || 0).getTime();
-            return bTime - aTime; // Descending order (most recent first)
-        });
-    }
-
-    // Get sorted list of users based on conversations and all users
-    getSortedUserList() {
-        // Sort conversations by last message time
-        this.sortConversationsByLastMessageTime();
-
-        // Create a set of user IDs that have conversations
-        const conversationUserIds = new Set(this.conversations.map(conv => parseInt(conv.user_id)));
-
-        // Map conversations to user objects
-        const conversationUsers = this.conversations.map(conv => ({
-            id: parseInt(conv.user_id),
-            nickname: conv.nickname,
-            unread_count: conv.unread_count || 0
-        }));
-
-        // Get users from allUsers not in conversations
-        const nonConversationUsers = this.allUsers
-            .filter(user => !conversationUserIds.has(parseInt(user.id)))
-            .sort((a, b) => (a.nickname || '').localeCompare(b.nickname || ''))
-            .map(user => ({
-                id: parseInt(user.id),
-                nickname: user.nickname,
-                unread_count: 0
-            }));
-
-        // Combine: conversation users first, then non-conversation users
-
-        this.SortedUserslist =  [...conversationUsers, ...nonConversationUsers];
-    }
-
-    // Move user to top of sorted list and update unread count
-    moveUserToTop(userId, fromus = false) {
-        const userIndex = this.SortedUserslist.findIndex(user => parseInt(user.id) === parseInt(userId));
-        if (userIndex !== -1) {
-            // Increment unread count
-            if (!fromus) {
-            this.SortedUserslist[userIndex].unread_count = (this.SortedUserslist[userIndex].unread_count || 0) + 1;}
-            // Move user to the front
-            const user = this.SortedUserslist.splice(userIndex, 1)[0];
-            this.SortedUserslist.unshift(user);
-        }
-    }
-
-   
     // Update connection status in UI
     updateConnectionStatus() {
         const statusElement = document.getElementById('chat-connection-status');
@@ -901,9 +1158,7 @@ class ChatWebSocket {
     // Update the floating chat button unread badge and title
     updateChatUnreadUI() {
         try {
-            const totalUnread = Array.isArray(this.conversations)
-                ? this.conversations.reduce((sum, c) => sum + (c.unread_count || 0), 0)
-                : 0;
+            const totalUnread = this.sidebar.reduce((sum, entry) => sum + (entry.muted ? 0 : entry.unread_count || 0), 0);
 
             const btn = document.getElementById('floating-chat-btn');
             if (!btn) return;
@@ -993,7 +1248,8 @@ class ChatWebSocket {
     // Clear messages (on logout)
     clearMessages() {
         this.onlineUsers = [];
-        this.conversations = [];
+        this.presence = {};
+        this.sidebar = [];
         this.activeConversation = null;
         this.privateMessages = {};
 
@@ -1057,6 +1313,12 @@ class ChatWebSocket {
 
     // Mark messages as read for a conversation
     async markMessagesAsRead(userId) {
+        // Over the socket the hub also sends the read receipt to the partner
+        if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+            this.send('mark_read', { to_user_id: userId });
+            return;
+        }
+
         try {
             const response = await fetch(`/api/messages/mark-read?user_id=${userId}`, {
                 method: 'POST',
@@ -1068,8 +1330,6 @@ class ChatWebSocket {
 
             if (response.ok) {
                 console.log('[ws.js:markMessagesAsRead] Messages marked as read for user:', userId);
-                // Refresh conversations to update unread counts
-                this.loadConversations();
             } else {
                 console.error('[ws.js:markMessagesAsRead] Failed to mark messages as read:', response.status);
             }
@@ -1078,7 +1338,37 @@ class ChatWebSocket {
         }
     }
 
+    // Mark every message of a group conversation as read
+    async markConversationAsRead(conversationId) {
+        try {
+            const response = await fetch(`/api/conversations/${conversationId}/read`, {
+                method: 'POST',
+                credentials: 'same-origin'
+            });
+            if (!response.ok) {
+                console.error('[ws.js:markConversationAsRead] Failed to mark conversation as read:', response.status);
+            }
+        } catch (error) {
+            console.error('[ws.js:markConversationAsRead] Error marking conversation as read:', error);
+        }
+    }
+
+    // Nickname of a known user, used to label group messages loaded from the API
+    nicknameOf(userId) {
+        const entry = this.sidebar.find(e => e.kind === 'direct' && e.user_id === parseInt(userId));
+        return entry ? entry.name : 'Unknown';
+    }
+
     // Show error message to user
+    // Tell the user about a moderator's action on their account or content
+    handleModerationNotice(notice) {
+        if (notice.action === 'warn') {
+            this.showErrorMessage(`A moderator warned you: ${notice.reason}`);
+        } else if (notice.action === 'hide') {
+            this.showErrorMessage(`A moderator hid your ${notice.target_type}: ${notice.reason}`);
+        }
+    }
+
     showErrorMessage(message) {
         // Create error message element
         const errorElement = document.createElement('div');